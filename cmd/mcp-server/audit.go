@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"mcp-server/internal/audit"
+)
+
+// runAuditCommand implements the "audit" subcommand and returns the process exit code
+func runAuditCommand(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: mcp-server audit verify [path]")
+		return 2
+	}
+
+	path := os.Getenv("MCP_AUDIT_LOG")
+	if len(args) > 1 {
+		path = args[1]
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "audit verify: no audit log path given and MCP_AUDIT_LOG is not set")
+		return 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit verify: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	count, head, err := audit.Verify(file)
+	if err != nil {
+		var verifyErr *audit.VerifyError
+		if errors.As(err, &verifyErr) {
+			fmt.Printf("FAILED: %s: %v (%d entries verified before it)\n", path, verifyErr, count)
+		} else {
+			fmt.Fprintf(os.Stderr, "audit verify: %v\n", err)
+		}
+		return 1
+	}
+
+	fmt.Printf("OK: %s: %d entries verified, head %s\n", path, count, head)
+	return 0
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"mcp-server/internal/audit"
+	"mcp-server/internal/budget"
 	"mcp-server/internal/config"
+	"mcp-server/internal/logging"
+	"mcp-server/internal/prompts"
 	"mcp-server/internal/server"
 	"mcp-server/internal/tools"
 )
@@ -16,16 +24,41 @@ import (
 var (
 	allowedPathsFlag = flag.String("paths", "", "Colon-separated list of allowed file operation paths")
 	deniedPathsFlag  = flag.String("deny-paths", "", "Colon-separated list of explicitly denied paths")
+	listenFlag       = flag.String("listen", "", "Transport to serve on: \"stdio\" (default) or \"unix:/path/to.sock\"")
+	allowUIDsFlag    = flag.String("allow-uids", "", "Comma-separated list of user IDs allowed to connect to the unix socket")
+	allowGIDsFlag    = flag.String("allow-gids", "", "Comma-separated list of group IDs allowed to connect to the unix socket")
+	logLevelFlag     = flag.String("log-level", "", "Minimum log level: debug, info, warn or error")
+	logFormatFlag    = flag.String("log-format", "", "Log format: text or json")
+	logFileFlag      = flag.String("log-file", "", "Log file path (defaults to $XDG_STATE_HOME/mcp-server/mcp-server.log; \"-\" disables file logging)")
+	logMaxSizeFlag   = flag.Int("log-max-size", 0, "Rotate the log file once it reaches this many megabytes")
+	logStderrFlag    = flag.Bool("log-stderr", false, "Also write logs to stderr")
+	auditLogFlag     = flag.String("audit-log", os.Getenv("MCP_AUDIT_LOG"), "Append a hash-chained JSONL record of every tool call to this file")
+	shutdownFlag     = flag.Duration("shutdown-timeout", 10*time.Second, "How long to wait for in-flight tool calls to finish before aborting them")
+	rateLimitFlag    = flag.Int("rate-limit", 0, "Maximum tool calls per minute for each session (0 means unlimited)")
+	maxOutputFlag    = flag.Int("max-output", 0, "Reject tool results larger than this many bytes (0 means unlimited)")
+	readOnlyFlag     = flag.Bool("read-only", os.Getenv("MCP_READ_ONLY") == "true", "Only offer tools that don't modify anything")
+	noResourcesFlag  = flag.Bool("no-resources", os.Getenv("MCP_NO_RESOURCES") == "true", "Don't expose the files under the allowed paths as MCP resources")
+	budgetsFlag      = flag.String("budgets", "", "Per-session budgets, e.g. \"session:calls_per_minute=120;write_file:bytes_written=10000000\"")
+	rootsFlag        = flag.String("roots", "", "How client-declared roots affect the allowed paths: intersect (default), only or ignore")
+	promptsFlag      = flag.String("prompts", os.Getenv("MCP_PROMPTS"), "JSON file of additional prompt templates")
 )
 
 func main() {
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAuditCommand(os.Args[2:]))
+	}
+
 	// Parse command-line flags
 	flag.Parse()
 
-	// Set up logging to a file so we don't interfere with stdio communication
-	logFile, err := os.OpenFile("mcp-server.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
-		log.SetOutput(logFile)
+	// Set up structured logging; stdout is reserved for stdio communication
+	logger, logCloser, err := logging.Setup(createLogConfig())
+	if logCloser == nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	if err != nil {
+		slog.Warn("Logging to stderr only", "error", err)
 	}
 
 	// Create configuration
@@ -36,24 +69,59 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create MCP server: %v", err)
 	}
+	mcpServer.Transport = createTransportConfig()
+	mcpServer.Budget = budget.NewTracker(createBudgetConfig())
+	mcpServer.ReadOnly = *readOnlyFlag
+	mcpServer.NoResources = *noResourcesFlag
+	mcpServer.Roots = createRootsMode()
+	mcpServer.Prompts = createPrompts()
+
+	// Forward warnings and errors about a tool call to the client that made it
+	slog.SetDefault(slog.New(mcpServer.ClientLogHandler(logger.Handler())))
+
+	// Open the audit log, if requested
+	if *auditLogFlag != "" {
+		auditLog, err := audit.Open(*auditLogFlag)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		mcpServer.Audit = auditLog
+	}
+
+	// Add the optional middleware
+	if *rateLimitFlag > 0 {
+		mcpServer.Use(server.RateLimit(*rateLimitFlag, time.Minute))
+	}
+	if *maxOutputFlag > 0 {
+		mcpServer.Use(server.OutputBudget(*maxOutputFlag))
+	}
 
 	// Register all tools
 	registerTools(mcpServer)
 
 	// Set up signal handling for graceful shutdown
-	setupSignalHandling(mcpServer)
+	signals := setupSignalHandling()
 
 	// Start the server
-	log.Printf("Starting MCP server with stdio transport...")
-	log.Printf("Allowed paths: %v", serverConfig.AllowedPaths)
-	log.Printf("Denied paths: %v", serverConfig.DenyListPaths)
+	slog.Info("Starting MCP server",
+		"transport", mcpServer.Transport.Listen,
+		"allowed_paths", serverConfig.AllowedPaths,
+		"denied_paths", serverConfig.DenyListPaths,
+		"roots", mcpServer.Roots)
 
 	if err := mcpServer.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
-	// Wait forever (handled by signal handling)
-	select {}
+	// Wait for a signal, or for the server to stop on its own (e.g. the client hung up)
+	select {
+	case sig := <-signals:
+		slog.Info("Received signal, shutting down", "signal", sig.String())
+	case <-mcpServer.Done():
+		slog.Info("Server stopped, shutting down")
+	}
+
+	shutdown(mcpServer, logCloser)
 }
 
 // createServerConfig builds the server configuration from environment variables and flags
@@ -73,13 +141,118 @@ func createServerConfig() *config.ServerConfig {
 	return cfg
 }
 
+// createLogConfig builds the logging configuration from environment variables and flags
+func createLogConfig() *config.LogConfig {
+	cfg := config.NewLogConfigFromEnv()
+
+	if *logLevelFlag != "" {
+		cfg.Level = *logLevelFlag
+	}
+
+	if *logFormatFlag != "" {
+		cfg.Format = *logFormatFlag
+	}
+
+	if *logFileFlag == "-" {
+		cfg.Path = ""
+	} else if *logFileFlag != "" {
+		cfg.Path = *logFileFlag
+	}
+
+	if *logMaxSizeFlag > 0 {
+		cfg.MaxSizeMB = *logMaxSizeFlag
+	}
+
+	if *logStderrFlag {
+		cfg.Stderr = true
+	}
+
+	return cfg
+}
+
+// createBudgetConfig builds the budget configuration from environment variables and flags
+func createBudgetConfig() *config.BudgetConfig {
+	if *budgetsFlag == "" {
+		cfg, err := config.NewBudgetConfigFromEnv()
+		if err != nil {
+			log.Fatalf("Invalid MCP_BUDGETS: %v", err)
+		}
+		return cfg
+	}
+
+	cfg, err := config.ParseBudgets(*budgetsFlag)
+	if err != nil {
+		log.Fatalf("Invalid --budgets: %v", err)
+	}
+	return cfg
+}
+
+// createRootsMode reads the roots mode from environment variables and flags
+func createRootsMode() config.RootsMode {
+	if *rootsFlag == "" {
+		return config.NewRootsModeFromEnv()
+	}
+
+	mode, err := config.ParseRootsMode(*rootsFlag)
+	if err != nil {
+		log.Fatalf("Invalid --roots: %v", err)
+	}
+	return mode
+}
+
+// createPrompts returns the built-in prompts plus any defined in the --prompts file
+func createPrompts() []*prompts.Prompt {
+	if *promptsFlag == "" {
+		return prompts.Builtin()
+	}
+
+	custom, err := prompts.Load(*promptsFlag)
+	if err != nil {
+		log.Fatalf("Failed to load prompts: %v", err)
+	}
+	return prompts.Merge(prompts.Builtin(), custom...)
+}
+
+// createTransportConfig builds the transport configuration from environment variables and flags
+func createTransportConfig() *config.TransportConfig {
+	cfg := config.NewTransportConfigFromEnv()
+
+	if *listenFlag != "" {
+		cfg.Listen = *listenFlag
+	}
+
+	if *allowUIDsFlag != "" {
+		uids, err := config.ParseIDList(*allowUIDsFlag)
+		if err != nil {
+			log.Fatalf("Invalid --allow-uids: %v", err)
+		}
+		cfg.AllowedUIDs = uids
+	}
+
+	if *allowGIDsFlag != "" {
+		gids, err := config.ParseIDList(*allowGIDsFlag)
+		if err != nil {
+			log.Fatalf("Invalid --allow-gids: %v", err)
+		}
+		cfg.AllowedGIDs = gids
+	}
+
+	return cfg
+}
+
 // registerTools registers all tools with the server
 func registerTools(mcpServer *server.Server) {
 	// Create tool instances
 	executeShellTool := tools.NewExecuteShellTool()
 	showFileTool := tools.NewShowFileTool()
+	readFilesTool := tools.NewReadFilesTool()
+	listDirectoryTool := tools.NewListDirectoryTool()
+	statFileTool := tools.NewStatFileTool()
 	searchFileTool := tools.NewSearchFileTool()
+	queryDataFileTool := tools.NewQueryDataFileTool()
 	writeFileTool := tools.NewWriteFileTool()
+	usageTool := tools.NewUsageTool(mcpServer.Budget)
+	usageTool.SetPanicCounts(mcpServer.PanicCounts)
 
 	// Register tools with server
 	if err := mcpServer.RegisterTool(executeShellTool); err != nil {
@@ -90,24 +263,57 @@ func registerTools(mcpServer *server.Server) {
 		log.Fatalf("Failed to register show_file tool: %v", err)
 	}
 
+	if err := mcpServer.RegisterTool(readFilesTool); err != nil {
+		log.Fatalf("Failed to register read_files tool: %v", err)
+	}
+
+	if err := mcpServer.RegisterTool(listDirectoryTool); err != nil {
+		log.Fatalf("Failed to register list_directory tool: %v", err)
+	}
+
+	if err := mcpServer.RegisterTool(statFileTool); err != nil {
+		log.Fatalf("Failed to register stat_file tool: %v", err)
+	}
+
 	if err := mcpServer.RegisterTool(searchFileTool); err != nil {
 		log.Fatalf("Failed to register search_in_file tool: %v", err)
 	}
 
+	if err := mcpServer.RegisterTool(queryDataFileTool); err != nil {
+		log.Fatalf("Failed to register query_data_file tool: %v", err)
+	}
+
 	if err := mcpServer.RegisterTool(writeFileTool); err != nil {
 		log.Fatalf("Failed to register write_file tool: %v", err)
 	}
+
+	if err := mcpServer.RegisterTool(usageTool); err != nil {
+		log.Fatalf("Failed to register usage tool: %v", err)
+	}
 }
 
 // setupSignalHandling sets up handlers for OS signals
-func setupSignalHandling(mcpServer *server.Server) {
+func setupSignalHandling() <-chan os.Signal {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	return signals
+}
+
+// shutdown drains in-flight calls, aborts whatever misses the deadline and flushes the log
+func shutdown(mcpServer *server.Server, logCloser io.Closer) {
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownFlag)
+	defer cancel()
+
+	report := mcpServer.Stop(ctx)
+	slog.Info("Shutdown complete", "drained", report.Drained, "aborted", len(report.Aborted))
+
+	if panics := mcpServer.PanicCounts(); len(panics) > 0 {
+		slog.Warn("Tools panicked during this run", "panics", panics)
+	}
+
+	if mcpServer.Audit != nil {
+		mcpServer.Audit.Close()
+	}
 
-	go func() {
-		sig := <-signals
-		log.Printf("Received signal %v, shutting down...", sig)
-		mcpServer.Stop()
-		os.Exit(0)
-	}()
+	logCloser.Close()
 }
@@ -0,0 +1,322 @@
+// Package archive reads the members of zip and tar archives in memory, without
+// extracting them to disk.
+//
+// Members are named by virtual paths with the archive's path, then !/, then the
+// member's path inside it, as Java does for JARs:
+//
+//	release.tar.gz!/bin/tool
+//	lib/app.jar!/META-INF/MANIFEST.MF
+//
+// Zip archives (including JAR, WAR and similar) and tar archives, uncompressed or
+// compressed with gzip or bzip2, are supported.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Separator separates an archive's path from a member's path in a virtual path
+const Separator = "!/"
+
+// MaxMembers is the most members an archive may have for List to list it
+const MaxMembers = 100000
+
+var (
+	// ErrUnsupported means a file is not an archive this package can read
+	ErrUnsupported = errors.New("not a zip or tar archive")
+
+	// ErrTooLarge means a member, or the list of members, is over the limit
+	ErrTooLarge = errors.New("too large")
+
+	// ErrIsDir means a directory was read as if it were a file
+	ErrIsDir = errors.New("is a directory")
+)
+
+// Member describes a file or directory in an archive
+type Member struct {
+	// Name is the member's path in the archive, with no leading or trailing slash
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Mode    fs.FileMode
+}
+
+// IsDir reports whether the member is a directory
+func (m Member) IsDir() bool {
+	return m.Mode.IsDir()
+}
+
+// Split splits a virtual path into the path of the archive and the path of the
+// member inside it. ok is false unless path has a !/ whose left-hand side is a
+// regular file, so a directory that happens to be named with a ! is not mistaken
+// for an archive. member is "" for the archive's top level, which may also be
+// written with just a trailing !, as cleaning archive.zip!/ leaves it.
+func Split(virtual string) (archivePath, member string, ok bool) {
+	if top, found := strings.CutSuffix(virtual, "!"); found && isRegular(top) && !exists(virtual) {
+		return top, "", true
+	}
+
+	for start := 0; ; {
+		i := strings.Index(virtual[start:], Separator)
+		if i < 0 {
+			return "", "", false
+		}
+		i += start
+
+		if isRegular(virtual[:i]) {
+			return virtual[:i], cleanName(virtual[i+len(Separator):]), true
+		}
+		start = i + len(Separator)
+	}
+}
+
+func isRegular(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// List returns the members of the archive at archivePath, sorted by name. Each
+// directory a member is in is listed, whether or not the archive has an entry for it.
+func List(archivePath string) ([]Member, error) {
+	members := make(map[string]Member)
+	add := func(m Member) error {
+		if m.Name == "" {
+			return nil
+		}
+		if _, seen := members[m.Name]; !seen && len(members) == MaxMembers {
+			return fmt.Errorf("archive has more than %d members: %w", MaxMembers, ErrTooLarge)
+		}
+		members[m.Name] = m
+
+		// Add the member's directories, unless the archive has entries of its own
+		for dir := path.Dir(m.Name); dir != "."; dir = path.Dir(dir) {
+			if _, seen := members[dir]; seen {
+				break
+			}
+			members[dir] = Member{Name: dir, Mode: fs.ModeDir | 0755}
+		}
+		return nil
+	}
+
+	err := walk(archivePath, func(m Member, _ io.Reader) (bool, error) {
+		return false, add(m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Member, 0, len(members))
+	for _, m := range members {
+		list = append(list, m)
+	}
+	// Comparing names a directory at a time puts "a/b" before "a.txt", as a walk of
+	// the same tree on disk would
+	sort.Slice(list, func(i, j int) bool {
+		return slices.Compare(strings.Split(list[i].Name, "/"), strings.Split(list[j].Name, "/")) < 0
+	})
+	return list, nil
+}
+
+// ReadMember reads the member name of the archive at archivePath. It fails with
+// ErrTooLarge if the member is larger than limit bytes, with ErrIsDir if it is a
+// directory, and with an error wrapping fs.ErrNotExist if there is no such member.
+func ReadMember(archivePath, name string, limit int64) ([]byte, error) {
+	name = cleanName(name)
+	if name == "" {
+		return nil, fmt.Errorf("%s%s: %w", archivePath, Separator, ErrIsDir)
+	}
+
+	var data []byte
+	found, isDir := false, false
+
+	err := walk(archivePath, func(m Member, content io.Reader) (bool, error) {
+		if m.Name != name {
+			// A directory may only be implied by the members inside it
+			if strings.HasPrefix(m.Name, name+"/") {
+				isDir = true
+			}
+			return false, nil
+		}
+		found = true
+		if m.IsDir() {
+			isDir = true
+			return true, nil
+		}
+		if !m.Mode.IsRegular() {
+			return true, fmt.Errorf("%s is not a regular file", name)
+		}
+		if m.Size > limit {
+			return true, fmt.Errorf("%s is %d bytes, over the limit of %d: %w", name, m.Size, limit, ErrTooLarge)
+		}
+
+		var err error
+		data, err = io.ReadAll(io.LimitReader(content, limit+1))
+		if err == nil && int64(len(data)) > limit {
+			err = fmt.Errorf("%s is over the limit of %d bytes: %w", name, limit, ErrTooLarge)
+		}
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if isDir {
+		return nil, fmt.Errorf("%s%s%s: %w", archivePath, Separator, name, ErrIsDir)
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: archivePath + Separator + name, Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+// visitFunc is called for each member, with a reader for its content. Returning
+// true stops the walk.
+type visitFunc func(m Member, content io.Reader) (stop bool, err error)
+
+// walk calls visit for each member of the archive at archivePath, in the order the
+// archive stores them
+func walk(archivePath string, visit visitFunc) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 512)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return walkZip(file, info.Size(), visit)
+	case bytes.HasPrefix(header, []byte("\x1f\x8b")):
+		gz, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		defer gz.Close()
+		return walkTar(gz, visit, true)
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return walkTar(bzip2.NewReader(bufio.NewReader(file)), visit, true)
+	case isTar(header):
+		return walkTar(file, visit, false)
+	}
+	return ErrUnsupported
+}
+
+// isTar reports whether header, the first block of a file, is a tar header
+func isTar(header []byte) bool {
+	return len(header) >= 263 && string(header[257:262]) == "ustar"
+}
+
+func walkZip(file io.ReaderAt, size int64, visit visitFunc) error {
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	for _, entry := range reader.File {
+		m := Member{
+			Name:    cleanName(entry.Name),
+			Size:    int64(entry.UncompressedSize64),
+			ModTime: entry.Modified,
+			Mode:    entry.Mode(),
+		}
+
+		// Members are only decompressed if they are read
+		content := &lazyReader{open: entry.Open}
+		stop, err := visit(m, content)
+		content.Close()
+		if stop || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lazyReader opens what it reads on the first Read
+type lazyReader struct {
+	open   func() (io.ReadCloser, error)
+	reader io.ReadCloser
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.reader == nil {
+		reader, err := l.open()
+		if err != nil {
+			return 0, err
+		}
+		l.reader = reader
+	}
+	return l.reader.Read(p)
+}
+
+// Close closes what was opened, if anything
+func (l *lazyReader) Close() error {
+	if l.reader == nil {
+		return nil
+	}
+	return l.reader.Close()
+}
+
+// walkTar walks a tar stream. compressed says it came out of a decompressor, where a
+// first header that fails to parse means the file was not a tar archive at all.
+func walkTar(r io.Reader, visit visitFunc, compressed bool) error {
+	reader := tar.NewReader(r)
+	for first := true; ; first = false {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if first && compressed {
+				return fmt.Errorf("%w: %v", ErrUnsupported, err)
+			}
+			return err
+		}
+
+		m := Member{
+			Name:    cleanName(header.Name),
+			Size:    header.Size,
+			ModTime: header.ModTime,
+			Mode:    header.FileInfo().Mode(),
+		}
+		if stop, err := visit(m, reader); stop || err != nil {
+			return err
+		}
+	}
+}
+
+// cleanName normalises a member path: no leading ./ or /, no trailing /
+func cleanName(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testMembers are the files in every test archive; none has an entry for its
+// directories
+var testMembers = map[string]string{
+	"README":         "read me\n",
+	"src/main.go":    "package main\n",
+	"src/pkg/lib.go": "package pkg\n",
+}
+
+func writeZip(t *testing.T, path string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range testMembers {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(t *testing.T, path string, compress bool) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	w := tar.NewWriter(&buf)
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = tar.NewWriter(gz)
+	}
+	for name, content := range testMembers {
+		if err := w.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		gz.Close()
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchives(t *testing.T) {
+	dir := t.TempDir()
+	archives := map[string]func(string){
+		"test.zip":    func(p string) { writeZip(t, p) },
+		"test.tar":    func(p string) { writeTar(t, p, false) },
+		"test.tar.gz": func(p string) { writeTar(t, p, true) },
+	}
+
+	for name, write := range archives {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			write(path)
+
+			members, err := List(path)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			var names []string
+			for _, m := range members {
+				names = append(names, m.Name)
+			}
+			if got := strings.Join(names, ","); got != "README,src,src/main.go,src/pkg,src/pkg/lib.go" {
+				t.Errorf("Unexpected members: %s", got)
+			}
+			if !members[1].IsDir() || members[2].Size != int64(len(testMembers["src/main.go"])) {
+				t.Errorf("Unexpected member details: %+v", members)
+			}
+
+			data, err := ReadMember(path, "src/main.go", 1024)
+			if err != nil || string(data) != testMembers["src/main.go"] {
+				t.Errorf("Expected the member's content, got %q, %v", data, err)
+			}
+			if _, err := ReadMember(path, "missing", 1024); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Expected a missing member to not exist, got %v", err)
+			}
+			if _, err := ReadMember(path, "src/pkg", 1024); !errors.Is(err, ErrIsDir) {
+				t.Errorf("Expected a directory error, got %v", err)
+			}
+			if _, err := ReadMember(path, "README", 3); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("Expected a size limit error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	dir := t.TempDir()
+	writeZip(t, filepath.Join(dir, "a.zip"))
+	if err := os.Mkdir(filepath.Join(dir, "odd!"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		archive string
+		member  string
+		ok      bool
+	}{
+		{"a.zip!/src/main.go", "a.zip", "src/main.go", true},
+		{"a.zip!/", "a.zip", "", true},
+		{"a.zip!/src/", "a.zip", "src", true},
+		{"odd!/a.zip!/x", "", "", false},
+		{"a.zip", "", "", false},
+		{"missing.zip!/x", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			archive, member, ok := Split(filepath.Join(dir, tt.path))
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if ok && (archive != filepath.Join(dir, tt.archive) || member != tt.member) {
+				t.Errorf("Expected %s and %q, got %s and %q", tt.archive, tt.member, archive, member)
+			}
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.txt")
+	if err := os.WriteFile(path, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := List(path); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-server/internal/config"
+)
+
+// ErrLocked is returned by Open when another process is writing to the log
+var ErrLocked = errors.New("audit log is in use by another process")
+
+// genesisHash is the previous hash of the first entry in a log
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Entry is a single audited tool invocation. Entries are hash-chained: each
+// entry's Hash covers its own content and the Hash of the entry before it, so
+// editing, removing or reordering entries breaks the chain.
+type Entry struct {
+	Seq          uint64                 `json:"seq"`
+	Time         time.Time              `json:"time"`
+	Session      string                 `json:"session"`
+	RequestID    string                 `json:"request_id"`
+	Tool         string                 `json:"tool"`
+	Args         map[string]interface{} `json:"args,omitempty"`
+	Decisions    []config.PathDecision  `json:"decisions,omitempty"`
+	Status       string                 `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	BytesRead    int64                  `json:"bytes_read"`
+	BytesWritten int64                  `json:"bytes_written"`
+	PrevHash     string                 `json:"prev_hash"`
+	Hash         string                 `json:"hash,omitempty"`
+}
+
+// computeHash returns the chain hash of the entry, ignoring its current Hash field
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(e.PrevHash), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only, hash-chained JSONL audit log
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	seq      uint64
+	lastHash string
+}
+
+// Open opens the audit log at path for appending, continuing the chain from its last
+// entry. It locks the file for as long as the log is open, and returns ErrLocked if
+// another process has it open, since two writers would break the chain.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock audit log %s: %w", path, err)
+	}
+
+	l := &Log{file: file, lastHash: genesisHash}
+
+	// Pick up where the previous run left off
+	last, end, err := lastEntry(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read existing audit log %s: %w", path, err)
+	}
+
+	// A crash while an entry was being written leaves part of a line at the end.
+	// It was never a complete entry, so drop it rather than chaining onto it.
+	if info, err := file.Stat(); err == nil && info.Size() > end {
+		slog.Warn("Truncating a partly written entry at the end of the audit log", "path", path, "bytes", info.Size()-end)
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate partly written entry in audit log %s: %w", path, err)
+		}
+	}
+
+	if last != nil {
+		l.seq = last.Seq
+		l.lastHash = last.Hash
+	}
+
+	return l, nil
+}
+
+// Record appends an entry to the log, filling in its sequence number and hashes
+func (l *Log) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+
+	entry.Seq = l.seq + 1
+	entry.PrevHash = l.lastHash
+	entry.Time = entry.Time.UTC()
+
+	hash, err := entry.computeHash()
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	l.seq = entry.Seq
+	l.lastHash = entry.Hash
+	return nil
+}
+
+// Close flushes and closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	l.file.Sync()
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// lastEntry returns the last entry in the log, or nil if it is empty, and the
+// offset just past the last line that ends in a newline. Anything after that offset
+// is a partly written entry.
+func lastEntry(r io.Reader) (*Entry, int64, error) {
+	var last *Entry
+	var end int64
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return last, end, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(line) > maxLineSize {
+			return nil, 0, bufio.ErrTooLong
+		}
+		end += int64(len(line))
+
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, 0, err
+		}
+		last = &entry
+	}
+}
+
+// maxLineSize bounds a single audit entry; arguments are summarized well below this
+const maxLineSize = 4 * 1024 * 1024
+
+// VerifyError describes the first entry at which the hash chain is broken
+type VerifyError struct {
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// Verify walks the log and checks every entry's hash and its link to the previous entry.
+// It returns the number of valid entries and the hash at the head of the valid chain,
+// and a *VerifyError for the first entry that is not valid.
+//
+// A hash chain cannot show that entries were cut off the end of the log; record the
+// head hash somewhere else to detect that.
+func Verify(r io.Reader) (int, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	prevHash := genesisHash
+	var prevSeq uint64
+	count := 0
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, prevHash, &VerifyError{Line: line, Reason: fmt.Sprintf("malformed entry: %v", err)}
+		}
+
+		if entry.Seq != prevSeq+1 {
+			return count, prevHash, &VerifyError{Line: line, Reason: fmt.Sprintf("sequence %d follows %d", entry.Seq, prevSeq)}
+		}
+
+		if entry.PrevHash != prevHash {
+			return count, prevHash, &VerifyError{Line: line, Reason: "previous hash does not match the preceding entry"}
+		}
+
+		hash, err := entry.computeHash()
+		if err != nil {
+			return count, prevHash, &VerifyError{Line: line, Reason: err.Error()}
+		}
+		if hash != entry.Hash {
+			return count, prevHash, &VerifyError{Line: line, Reason: "entry hash does not match its contents"}
+		}
+
+		prevHash = entry.Hash
+		prevSeq = entry.Seq
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, prevHash, err
+	}
+
+	return count, prevHash, nil
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeEntries(t *testing.T, path string, tools ...string) {
+	t.Helper()
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer log.Close()
+
+	for _, tool := range tools {
+		err := log.Record(Entry{
+			Time:   time.Now(),
+			Tool:   tool,
+			Args:   SanitizeArgs(map[string]interface{}{"file_path": "/tmp/x", "content": "secret data"}),
+			Status: "ok",
+		})
+		if err != nil {
+			t.Fatalf("Failed to record entry: %v", err)
+		}
+	}
+}
+
+func verifyFile(t *testing.T, path string) (int, error) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	count, _, err := Verify(file)
+	return count, err
+}
+
+func TestAuditLog_ChainSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	writeEntries(t, path, "show_file", "write_file")
+	writeEntries(t, path, "execute_shell_command")
+
+	count, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatalf("Expected log to verify, got: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 entries, got %d", count)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret data") {
+		t.Error("Expected file content to be summarized, not stored")
+	}
+}
+
+func TestAuditLog_DetectsEdits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEntries(t, path, "show_file", "write_file", "search_in_file")
+
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), `"tool":"write_file"`, `"tool":"show_file"`, 1)
+	os.WriteFile(path, []byte(tampered), 0600)
+
+	count, err := verifyFile(t, path)
+	verifyErr, ok := err.(*VerifyError)
+	if !ok {
+		t.Fatalf("Expected a VerifyError, got: %v", err)
+	}
+	if verifyErr.Line != 2 || count != 1 {
+		t.Errorf("Expected failure at line 2 after 1 valid entry, got line %d after %d", verifyErr.Line, count)
+	}
+}
+
+func TestAuditLog_DetectsRemovedEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEntries(t, path, "show_file", "write_file", "search_in_file")
+
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+	os.WriteFile(path, []byte(lines[0]+lines[2]), 0600)
+
+	if _, err := verifyFile(t, path); err == nil {
+		t.Error("Expected removing an entry to break the chain")
+	}
+}
+
+func TestAuditLog_DropsTornLastEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEntries(t, path, "show_file", "write_file")
+
+	// Simulate a crash partway through writing a third entry
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":3,"time":"2024-`)
+	file.Close()
+
+	writeEntries(t, path, "search_in_file")
+
+	count, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatalf("Expected the log to verify after the torn entry was dropped, got: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 entries, got %d", count)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package audit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog_RefusesALogInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	first, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	if _, err := Open(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected a second open to fail with ErrLocked, got %v", err)
+	}
+
+	// Closing the log releases it
+	first.Close()
+	writeEntries(t, path, "show_file")
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxArgLength is the longest string argument recorded verbatim
+const maxArgLength = 256
+
+// summarizedArgs are always recorded as a length and digest rather than their value
+var summarizedArgs = map[string]bool{
+	"content": true,
+}
+
+// secretArgMarkers identify argument names whose values must never be recorded
+var secretArgMarkers = []string{"password", "secret", "token", "api_key", "apikey", "credential"}
+
+// SanitizeArgs converts tool arguments into a form that is safe to keep in the audit log.
+// Long strings and file contents are replaced by their length and SHA-256 digest, so the
+// log proves what was written without storing it, and secret-looking values are redacted.
+func SanitizeArgs(args interface{}) map[string]interface{} {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return map[string]interface{}{"_error": fmt.Sprintf("unable to encode arguments: %v", err)}
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return map[string]interface{}{"_error": fmt.Sprintf("unable to encode arguments: %v", err)}
+	}
+
+	for key, value := range decoded {
+		decoded[key] = sanitizeValue(key, value)
+	}
+	return decoded
+}
+
+// sanitizeValue sanitizes a single (possibly nested) argument value
+func sanitizeValue(key string, value interface{}) interface{} {
+	lowerKey := strings.ToLower(key)
+	for _, marker := range secretArgMarkers {
+		if strings.Contains(lowerKey, marker) {
+			return "[redacted]"
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if summarizedArgs[lowerKey] || len(v) > maxArgLength {
+			return summarize(v)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = sanitizeValue(key, v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = sanitizeValue(k, v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// summarize describes a string by its length and digest
+func summarize(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("[%d bytes sha256:%s]", len(s), hex.EncodeToString(sum[:]))
+}
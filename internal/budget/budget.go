@@ -0,0 +1,278 @@
+// Package budget tracks what each session has used and enforces the limits in
+// config.BudgetConfig, so that an agent stuck in a loop cannot run commands or
+// rewrite files without end.
+package budget
+
+import (
+	"sync"
+	"time"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// window is the period CallsPerMinute applies to
+const window = time.Minute
+
+// Usage is what calls in one scope have used so far
+type Usage struct {
+	CallsLastMinute int     `json:"calls_last_minute"`
+	Concurrent      int     `json:"concurrent"`
+	BytesWritten    int64   `json:"bytes_written"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	OutputBytes     int64   `json:"output_bytes"`
+}
+
+// Consumed is what a single call used, reported when it ends
+type Consumed struct {
+	BytesWritten int64
+	CPUTime      time.Duration
+	OutputBytes  int64
+}
+
+// ScopeReport describes the budget of one scope
+type ScopeReport struct {
+	Limits config.BudgetLimits `json:"limits"`
+	Used   Usage               `json:"used"`
+
+	// Remaining holds what is left of each limited budget, keyed like the limits
+	Remaining map[string]interface{} `json:"remaining,omitempty"`
+}
+
+// Report describes a session's budgets
+type Report struct {
+	Session ScopeReport            `json:"session"`
+	Tools   map[string]ScopeReport `json:"tools,omitempty"`
+}
+
+// scope holds the usage counters for the session as a whole or for one tool
+type scope struct {
+	calls        []time.Time
+	concurrent   int
+	bytesWritten int64
+	cpuTime      time.Duration
+	outputBytes  int64
+}
+
+// sessionUsage holds a session's counters
+type sessionUsage struct {
+	total scope
+	tools map[string]*scope
+}
+
+// Tracker enforces budgets for every session
+type Tracker struct {
+	config *config.BudgetConfig
+
+	mu       sync.Mutex
+	sessions map[string]*sessionUsage
+}
+
+// NewTracker creates a tracker enforcing cfg
+func NewTracker(cfg *config.BudgetConfig) *Tracker {
+	if cfg == nil {
+		cfg = config.DefaultBudgetConfig()
+	}
+	return &Tracker{
+		config:   cfg,
+		sessions: make(map[string]*sessionUsage),
+	}
+}
+
+// Reservation is a call that was admitted and must be ended with End
+type Reservation struct {
+	tracker *Tracker
+	session string
+	tool    string
+	once    sync.Once
+}
+
+// Begin admits a call to tool in session, or returns a BUDGET_EXCEEDED error if the
+// session or the tool has exhausted one of its budgets. A call that is admitted may
+// take its budgets past their limits; the next call is then refused.
+func (t *Tracker) Begin(session, tool string) (*Reservation, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.sessionLocked(session)
+	toolUsage := usage.tools[tool]
+	if toolUsage == nil {
+		toolUsage = &scope{}
+		usage.tools[tool] = toolUsage
+	}
+
+	if err := usage.total.check(t.config.Session, config.BudgetScopeSession, now); err != nil {
+		return nil, err
+	}
+	if err := toolUsage.check(t.config.Tools[tool], tool, now); err != nil {
+		return nil, err
+	}
+
+	for _, s := range []*scope{&usage.total, toolUsage} {
+		s.calls = append(s.calls, now)
+		s.concurrent++
+	}
+
+	return &Reservation{tracker: t, session: session, tool: tool}, nil
+}
+
+// End releases the call's concurrency slot and charges what it used
+func (r *Reservation) End(consumed Consumed) {
+	r.once.Do(func() {
+		r.tracker.mu.Lock()
+		defer r.tracker.mu.Unlock()
+
+		usage, ok := r.tracker.sessions[r.session]
+		if !ok {
+			// The session ended while the call was running
+			return
+		}
+
+		for _, s := range []*scope{&usage.total, usage.tools[r.tool]} {
+			if s == nil {
+				continue
+			}
+			s.concurrent--
+			s.bytesWritten += consumed.BytesWritten
+			s.cpuTime += consumed.CPUTime
+			s.outputBytes += consumed.OutputBytes
+		}
+	})
+}
+
+// Report returns the budgets of a session and what remains of them
+func (t *Tracker) Report(session string) Report {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Report on a session that hasn't made a call without starting to track it
+	usage := t.sessions[session]
+	if usage == nil {
+		usage = &sessionUsage{}
+	}
+	report := Report{
+		Session: usage.total.report(t.config.Session, now),
+		Tools:   make(map[string]ScopeReport),
+	}
+
+	// Cover tools that have limits even if they haven't been called yet
+	for tool, limits := range t.config.Tools {
+		s := usage.tools[tool]
+		if s == nil {
+			s = &scope{}
+		}
+		report.Tools[tool] = s.report(limits, now)
+	}
+	for tool, s := range usage.tools {
+		if _, ok := report.Tools[tool]; !ok {
+			report.Tools[tool] = s.report(t.config.Tools[tool], now)
+		}
+	}
+
+	return report
+}
+
+// EndSession forgets a session's usage
+func (t *Tracker) EndSession(session string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, session)
+}
+
+// sessionLocked returns the usage of a session, creating it if needed
+func (t *Tracker) sessionLocked(session string) *sessionUsage {
+	usage := t.sessions[session]
+	if usage == nil {
+		usage = &sessionUsage{tools: make(map[string]*scope)}
+		t.sessions[session] = usage
+	}
+	return usage
+}
+
+// prune drops calls that have left the rate window
+func (s *scope) prune(now time.Time) {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(s.calls) && !s.calls[i].After(cutoff) {
+		i++
+	}
+	s.calls = s.calls[i:]
+}
+
+// check returns a BUDGET_EXCEEDED error if another call would break limits
+func (s *scope) check(limits config.BudgetLimits, name string, now time.Time) error {
+	s.prune(now)
+
+	exceeded := func(budget string, limit, used interface{}) *utils.ToolError {
+		return utils.NewToolError(utils.ErrBudgetExceeded, "The %s budget for %s is exhausted (limit %v, used %v)", budget, name, limit, used).
+			WithDetails(map[string]interface{}{
+				"scope":  name,
+				"budget": budget,
+				"limit":  limit,
+				"used":   used,
+			})
+	}
+
+	if limits.Concurrent > 0 && s.concurrent >= limits.Concurrent {
+		return exceeded("concurrent", limits.Concurrent, s.concurrent)
+	}
+	if limits.CallsPerMinute > 0 && len(s.calls) >= limits.CallsPerMinute {
+		err := exceeded("calls_per_minute", limits.CallsPerMinute, len(s.calls))
+		err.Details.(map[string]interface{})["retry_after_ms"] = s.calls[0].Add(window).Sub(now).Milliseconds()
+		return err
+	}
+	if limits.BytesWritten > 0 && s.bytesWritten >= limits.BytesWritten {
+		return exceeded("bytes_written", limits.BytesWritten, s.bytesWritten)
+	}
+	if limits.CPUSeconds > 0 && s.cpuTime.Seconds() >= limits.CPUSeconds {
+		return exceeded("cpu_seconds", limits.CPUSeconds, s.cpuTime.Seconds())
+	}
+	if limits.OutputBytes > 0 && s.outputBytes >= limits.OutputBytes {
+		return exceeded("output_bytes", limits.OutputBytes, s.outputBytes)
+	}
+
+	return nil
+}
+
+// report summarizes the scope against limits
+func (s *scope) report(limits config.BudgetLimits, now time.Time) ScopeReport {
+	s.prune(now)
+
+	report := ScopeReport{
+		Limits: limits,
+		Used: Usage{
+			CallsLastMinute: len(s.calls),
+			Concurrent:      s.concurrent,
+			BytesWritten:    s.bytesWritten,
+			CPUSeconds:      s.cpuTime.Seconds(),
+			OutputBytes:     s.outputBytes,
+		},
+	}
+
+	if limits.IsZero() {
+		return report
+	}
+
+	report.Remaining = make(map[string]interface{})
+	if limits.CallsPerMinute > 0 {
+		report.Remaining["calls_per_minute"] = max(limits.CallsPerMinute-len(s.calls), 0)
+	}
+	if limits.Concurrent > 0 {
+		report.Remaining["concurrent"] = max(limits.Concurrent-s.concurrent, 0)
+	}
+	if limits.BytesWritten > 0 {
+		report.Remaining["bytes_written"] = max(limits.BytesWritten-s.bytesWritten, 0)
+	}
+	if limits.CPUSeconds > 0 {
+		report.Remaining["cpu_seconds"] = max(limits.CPUSeconds-s.cpuTime.Seconds(), 0)
+	}
+	if limits.OutputBytes > 0 {
+		report.Remaining["output_bytes"] = max(limits.OutputBytes-s.outputBytes, 0)
+	}
+
+	return report
+}
@@ -0,0 +1,143 @@
+package budget
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// expectExceeded fails the test unless err is BUDGET_EXCEEDED for the given budget
+func expectExceeded(t *testing.T, err error, budget string) {
+	t.Helper()
+
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrBudgetExceeded {
+		t.Fatalf("Expected BUDGET_EXCEEDED, got: %v", err)
+	}
+	if details := toolErr.Details.(map[string]interface{}); details["budget"] != budget {
+		t.Fatalf("Expected the %s budget to be exceeded, got %v", budget, details["budget"])
+	}
+}
+
+func TestTracker_Concurrent(t *testing.T) {
+	cfg, err := config.ParseBudgets("session:concurrent=1")
+	if err != nil {
+		t.Fatalf("Failed to parse budgets: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	first, err := tracker.Begin("s1", "show_file")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = tracker.Begin("s1", "write_file")
+	expectExceeded(t, err, "concurrent")
+
+	// Other sessions have their own budgets
+	if _, err := tracker.Begin("s2", "show_file"); err != nil {
+		t.Fatalf("Expected another session to be admitted, got: %v", err)
+	}
+
+	first.End(Consumed{})
+	first.End(Consumed{}) // ending twice must not release a second slot
+	if _, err := tracker.Begin("s1", "write_file"); err != nil {
+		t.Fatalf("Expected a call after the first ended, got: %v", err)
+	}
+}
+
+func TestTracker_ToolBudgetsAreCumulative(t *testing.T) {
+	cfg, err := config.ParseBudgets("write_file:bytes_written=100;execute_shell_command:cpu_seconds=1")
+	if err != nil {
+		t.Fatalf("Failed to parse budgets: %v", err)
+	}
+	tracker := NewTracker(cfg)
+
+	// The call that crosses the limit is allowed to finish
+	reservation, err := tracker.Begin("s1", "write_file")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reservation.End(Consumed{BytesWritten: 150})
+
+	_, err = tracker.Begin("s1", "write_file")
+	expectExceeded(t, err, "bytes_written")
+
+	// Other tools are unaffected by a tool's budget
+	reservation, err = tracker.Begin("s1", "execute_shell_command")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reservation.End(Consumed{CPUTime: 2 * time.Second})
+
+	_, err = tracker.Begin("s1", "execute_shell_command")
+	expectExceeded(t, err, "cpu_seconds")
+
+	report := tracker.Report("s1")
+	if got := report.Tools["write_file"].Remaining["bytes_written"]; got != int64(0) {
+		t.Errorf("Expected no bytes remaining, got %v", got)
+	}
+	if got := report.Session.Used.BytesWritten; got != 150 {
+		t.Errorf("Expected the session to have written 150 bytes, got %d", got)
+	}
+
+	// Ending the session forgets its usage
+	tracker.EndSession("s1")
+	if _, err := tracker.Begin("s1", "write_file"); err != nil {
+		t.Errorf("Expected a new session to start with a fresh budget, got: %v", err)
+	}
+}
+
+func TestTracker_CallsPerMinute(t *testing.T) {
+	tracker := NewTracker(&config.BudgetConfig{Session: config.BudgetLimits{CallsPerMinute: 2}})
+
+	for i := 0; i < 2; i++ {
+		reservation, err := tracker.Begin("s1", "show_file")
+		if err != nil {
+			t.Fatalf("Unexpected error on call %d: %v", i+1, err)
+		}
+		reservation.End(Consumed{})
+	}
+
+	_, err := tracker.Begin("s1", "show_file")
+	expectExceeded(t, err, "calls_per_minute")
+
+	if remaining := tracker.Report("s1").Session.Remaining["calls_per_minute"]; remaining != 0 {
+		t.Errorf("Expected no calls remaining, got %v", remaining)
+	}
+}
+
+func TestParseBudgets_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"session",
+		"session:calls=5",
+		"session:concurrent=-1",
+		"write_file:bytes_written=lots",
+	} {
+		if _, err := config.ParseBudgets(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestTracker_ReportUnknownSession(t *testing.T) {
+	tracker := NewTracker(&config.BudgetConfig{Session: config.BudgetLimits{Concurrent: 1}})
+
+	report := tracker.Report("unknown")
+	if report.Session.Remaining["concurrent"] != 1 {
+		t.Errorf("Expected the full budget to remain, got %v", report.Session.Remaining)
+	}
+	if len(tracker.sessions) != 0 {
+		t.Errorf("Expected reporting not to track the session, got %d sessions", len(tracker.sessions))
+	}
+}
+
+func TestNewBudgetConfigFromEnv_Invalid(t *testing.T) {
+	t.Setenv("MCP_BUDGETS", "session:calls=5")
+	if _, err := config.NewBudgetConfigFromEnv(); err == nil {
+		t.Error("Expected an invalid MCP_BUDGETS to be rejected")
+	}
+}
@@ -0,0 +1,374 @@
+// Package charset detects the character encoding of text files and converts them
+// to and from UTF-8. It knows UTF-8 and UTF-16, with or without a byte order mark,
+// and the single-byte ISO-8859-1 (Latin-1) and Windows-1252 encodings that legacy
+// files tend to use.
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding names
+const (
+	UTF8        = "utf-8"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Latin1      = "iso-8859-1"
+	Windows1252 = "windows-1252"
+)
+
+// Line endings
+const (
+	LF   = "lf"
+	CRLF = "crlf"
+)
+
+// maxInvalidUTF8 is the fraction of bytes that may be invalid UTF-8 before a sample
+// that does not look like single-byte text is taken not to be text at all
+const maxInvalidUTF8 = 0.1
+
+// Encoding is a character encoding, and whether the file starts with a byte order
+// mark
+type Encoding struct {
+	Name string `json:"encoding"`
+	BOM  bool   `json:"bom,omitempty"`
+}
+
+// Default is plain UTF-8, which needs no conversion
+var Default = Encoding{Name: UTF8}
+
+// IsDefault reports whether text in e can be used as UTF-8 as it is
+func (e Encoding) IsDefault() bool {
+	return e == Default
+}
+
+var boms = []struct {
+	bom      string
+	encoding string
+}{
+	{"\xef\xbb\xbf", UTF8},
+	{"\xff\xfe", UTF16LE},
+	{"\xfe\xff", UTF16BE},
+}
+
+// Detect guesses the encoding of sample, the start of a file. A byte order mark
+// settles it; otherwise UTF-16 is recognised by its pattern of zero bytes, valid
+// UTF-8 is taken as UTF-8, and anything else that reads as single-byte text as
+// Windows-1252, or Latin-1 if it uses none of the characters the two differ in.
+// truncated says the file continues past sample, so a character cut off at the end
+// is not held against it. ok is false if sample does not look like text.
+func Detect(sample []byte, truncated bool) (enc Encoding, ok bool) {
+	for _, b := range boms {
+		if bytes.HasPrefix(sample, []byte(b.bom)) {
+			return Encoding{Name: b.encoding, BOM: true}, true
+		}
+	}
+	if name := detectUTF16(sample); name != "" {
+		return Encoding{Name: name}, true
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return Encoding{}, false
+	}
+
+	invalid := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size <= 1 {
+			if truncated && !utf8.FullRune(sample[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += max(size, 1)
+	}
+	if invalid == 0 {
+		return Default, true
+	}
+
+	if singleByte := detectSingleByte(sample); singleByte != "" {
+		return Encoding{Name: singleByte}, true
+	}
+	if float64(invalid)/float64(len(sample)) > maxInvalidUTF8 {
+		return Encoding{}, false
+	}
+	return Default, true
+}
+
+// detectUTF16 recognises UTF-16 without a byte order mark by mostly-ASCII text
+// having a zero in every other byte
+func detectUTF16(sample []byte) string {
+	pairs := len(sample) / 2
+	if pairs < 2 {
+		return ""
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenZeros++
+		}
+		if sample[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	name := ""
+	switch {
+	case oddZeros*10 >= pairs*4 && evenZeros*20 < pairs:
+		name = UTF16LE
+	case evenZeros*10 >= pairs*4 && oddZeros*20 < pairs:
+		name = UTF16BE
+	default:
+		return ""
+	}
+
+	// Binary data can have the same pattern, but decodes to control characters
+	for _, r := range string(decodeAppend(nil, sample[:pairs*2], name)) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' {
+			return ""
+		}
+	}
+	return name
+}
+
+// detectSingleByte returns Windows1252 or Latin1 if sample reads as text in a
+// single-byte encoding: no control characters other than whitespace and escape,
+// and not so many non-ASCII bytes that it is more likely binary
+func detectSingleByte(sample []byte) string {
+	high, c1 := 0, false
+	for _, b := range sample {
+		switch {
+		case b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1b:
+			return ""
+		case b >= 0x80:
+			high++
+			if b < 0xa0 {
+				c1 = true
+			}
+		}
+	}
+	if high*10 > len(sample)*3 {
+		return ""
+	}
+	if c1 {
+		return Windows1252
+	}
+	return Latin1
+}
+
+// windows1252 maps bytes 0x80-0x9f to the characters Windows-1252 puts there; the
+// five bytes it leaves undefined map to the same code points, as in Latin-1
+var windows1252 = [32]rune{
+	0x20ac, 0x0081, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008d, 0x017d, 0x008f,
+	0x0090, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x009d, 0x017e, 0x0178,
+}
+
+// Decode converts data in encoding e to UTF-8, dropping any byte order mark
+func Decode(data []byte, e Encoding) []byte {
+	if e.BOM {
+		data = trimBOM(data, e)
+	}
+	return decodeAppend(nil, data, e.Name)
+}
+
+// Encode converts UTF-8 text to encoding e, adding a byte order mark if e has one.
+// It fails if text has characters the encoding cannot represent.
+func Encode(text string, e Encoding) ([]byte, error) {
+	var out []byte
+	if e.BOM {
+		for _, b := range boms {
+			if b.encoding == e.Name {
+				out = append(out, b.bom...)
+			}
+		}
+	}
+
+	switch e.Name {
+	case UTF8:
+		return append(out, text...), nil
+	case UTF16LE, UTF16BE:
+		for _, unit := range utf16.Encode([]rune(text)) {
+			if e.Name == UTF16LE {
+				out = append(out, byte(unit), byte(unit>>8))
+			} else {
+				out = append(out, byte(unit>>8), byte(unit))
+			}
+		}
+		return out, nil
+	case Latin1, Windows1252:
+		for i, r := range text {
+			b, ok := encodeSingleByte(r, e.Name)
+			if !ok {
+				return nil, fmt.Errorf("character %q at byte %d cannot be written in %s", r, i, e.Name)
+			}
+			out = append(out, b)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %s", e.Name)
+}
+
+// encodeSingleByte returns the byte for r in Latin-1 or Windows-1252
+func encodeSingleByte(r rune, name string) (byte, bool) {
+	if name == Windows1252 {
+		for i, mapped := range windows1252 {
+			if mapped == r {
+				return byte(0x80 + i), true
+			}
+		}
+		if r >= 0x80 && r < 0xa0 {
+			return 0, false
+		}
+	}
+	if r > 0xff {
+		return 0, false
+	}
+	return byte(r), true
+}
+
+// trimBOM removes e's byte order mark from the start of data
+func trimBOM(data []byte, e Encoding) []byte {
+	for _, b := range boms {
+		if b.encoding == e.Name {
+			return bytes.TrimPrefix(data, []byte(b.bom))
+		}
+	}
+	return data
+}
+
+// decodeAppend appends the UTF-8 for data, in the named encoding, to dst
+func decodeAppend(dst, data []byte, name string) []byte {
+	switch name {
+	case UTF16LE, UTF16BE:
+		for i := 0; i+1 < len(data); i += 2 {
+			r := rune(unit16(data[i:], name))
+			if utf16.IsSurrogate(r) && i+3 < len(data) {
+				if pair := utf16.DecodeRune(r, rune(unit16(data[i+2:], name))); pair != utf8.RuneError {
+					r = pair
+					i += 2
+				}
+			}
+			dst = utf8.AppendRune(dst, r)
+		}
+		if len(data)%2 == 1 {
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		}
+		return dst
+	case Latin1, Windows1252:
+		for _, b := range data {
+			r := rune(b)
+			if name == Windows1252 && b >= 0x80 && b < 0xa0 {
+				r = windows1252[b-0x80]
+			}
+			dst = utf8.AppendRune(dst, r)
+		}
+		return dst
+	}
+	return append(dst, data...)
+}
+
+// unit16 reads one UTF-16 code unit
+func unit16(b []byte, name string) uint16 {
+	if name == UTF16LE {
+		return uint16(b[0]) | uint16(b[1])<<8
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// incomplete returns how many bytes at the end of data may be the start of a
+// character that continues in the next chunk
+func incomplete(data []byte, name string) int {
+	if name != UTF16LE && name != UTF16BE {
+		return 0
+	}
+	n := len(data) % 2
+	if len(data)-n >= 2 {
+		last := unit16(data[len(data)-n-2:], name)
+		if utf16.IsSurrogate(rune(last)) && last < 0xdc00 {
+			n += 2
+		}
+	}
+	return n
+}
+
+// NewReader returns a reader that converts what r reads from encoding e to UTF-8,
+// dropping any byte order mark
+func NewReader(r io.Reader, e Encoding) io.Reader {
+	if e.IsDefault() {
+		return r
+	}
+	return &reader{r: r, enc: e, in: make([]byte, 32<<10), atStart: e.BOM}
+}
+
+// reader converts a stream to UTF-8
+type reader struct {
+	r   io.Reader
+	enc Encoding
+	err error
+
+	// in holds pending bytes of input in its first pending bytes
+	in      []byte
+	pending int
+	// out holds converted text not yet returned
+	out []byte
+	// atStart is set until the byte order mark has been dropped
+	atStart bool
+}
+
+// Read reads converted text
+func (d *reader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 && d.err == nil {
+		n, err := d.r.Read(d.in[d.pending:])
+		data := d.in[:d.pending+n]
+		d.err = err
+
+		if d.atStart {
+			if len(data) < 3 && err == nil {
+				d.pending = len(data)
+				continue
+			}
+			data = trimBOM(data, d.enc)
+			d.atStart = false
+		}
+
+		keep := 0
+		if err == nil {
+			keep = incomplete(data, d.enc.Name)
+		}
+		d.out = decodeAppend(d.out[:0], data[:len(data)-keep], d.enc.Name)
+		d.pending = copy(d.in, data[len(data)-keep:])
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	if n > 0 {
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// DetectLineEnding returns CRLF if most lines in text end in "\r\n", and LF
+// otherwise
+func DetectLineEnding(text []byte) string {
+	crlf := bytes.Count(text, []byte("\r\n"))
+	if crlf > 0 && crlf*2 >= bytes.Count(text, []byte("\n")) {
+		return CRLF
+	}
+	return LF
+}
+
+// ConvertLineEndings makes every line in text end the way ending says
+func ConvertLineEndings(text, ending string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if ending == CRLF {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	return text
+}
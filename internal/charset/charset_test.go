@@ -0,0 +1,97 @@
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   string
+		expected Encoding
+		ok       bool
+	}{
+		{"ascii", "hello\n", Default, true},
+		{"utf-8", "naïve café\n", Default, true},
+		{"utf-8 bom", "\xef\xbb\xbfhello", Encoding{Name: UTF8, BOM: true}, true},
+		{"utf-16le bom", "\xff\xfeh\x00i\x00", Encoding{Name: UTF16LE, BOM: true}, true},
+		{"utf-16be bom", "\xfe\xff\x00h\x00i", Encoding{Name: UTF16BE, BOM: true}, true},
+		{"utf-16le", "h\x00e\x00l\x00l\x00o\x00\n\x00", Encoding{Name: UTF16LE}, true},
+		{"utf-16be", "\x00h\x00e\x00l\x00l\x00o\x00\n", Encoding{Name: UTF16BE}, true},
+		{"latin-1", "caf\xe9 cr\xe8me\n", Encoding{Name: Latin1}, true},
+		{"windows-1252", "\x93quoted\x94 \x80 5\n", Encoding{Name: Windows1252}, true},
+		{"nul bytes", "abc\x00\x01\x02def", Encoding{}, false},
+		{"binary", "\x01\x02\xff\xfe\xfd\xfc\x8a\x9b", Encoding{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, ok := Detect([]byte(tt.sample), false)
+			if enc != tt.expected || ok != tt.ok {
+				t.Errorf("Expected %v, %v; got %v, %v", tt.expected, tt.ok, enc, ok)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		encoding Encoding
+		text     string
+	}{
+		{Encoding{Name: UTF8, BOM: true}, "héllo\n"},
+		{Encoding{Name: UTF16LE, BOM: true}, "héllo 🙂\r\n"},
+		{Encoding{Name: UTF16BE}, "héllo 🙂\n"},
+		{Encoding{Name: Latin1}, "café crème\n"},
+		{Encoding{Name: Windows1252}, "“quoted” €5 – ok\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s bom=%v", tt.encoding.Name, tt.encoding.BOM), func(t *testing.T) {
+			encoded, err := Encode(tt.text, tt.encoding)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if decoded := string(Decode(encoded, tt.encoding)); decoded != tt.text {
+				t.Errorf("Expected %q, got %q", tt.text, decoded)
+			}
+			if enc, ok := Detect(encoded, false); !ok || enc != tt.encoding {
+				t.Errorf("Expected the encoded text to be detected as %v, got %v", tt.encoding, enc)
+			}
+
+			// Reading a byte at a time splits characters across reads
+			decoded, err := io.ReadAll(NewReader(iotest.OneByteReader(bytes.NewReader(encoded)), tt.encoding))
+			if err != nil || string(decoded) != tt.text {
+				t.Errorf("Expected the reader to give %q, got %q, %v", tt.text, decoded, err)
+			}
+		})
+	}
+}
+
+func TestEncode_Unrepresentable(t *testing.T) {
+	if _, err := Encode("€", Encoding{Name: Latin1}); err == nil {
+		t.Error("Expected the euro sign not to be representable in Latin-1")
+	}
+	if _, err := Encode("🙂", Encoding{Name: Windows1252}); err == nil {
+		t.Error("Expected an emoji not to be representable in Windows-1252")
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	if ending := DetectLineEnding([]byte("a\r\nb\r\nc\n")); ending != CRLF {
+		t.Errorf("Expected CRLF, got %s", ending)
+	}
+	if ending := DetectLineEnding([]byte("a\nb\nc\r\n")); ending != LF {
+		t.Errorf("Expected LF, got %s", ending)
+	}
+	if converted := ConvertLineEndings("a\nb\r\nc", CRLF); converted != "a\r\nb\r\nc" {
+		t.Errorf("Expected CRLF line endings, got %q", converted)
+	}
+	if converted := ConvertLineEndings("a\r\nb\nc", LF); converted != "a\nb\nc" {
+		t.Errorf("Expected LF line endings, got %q", converted)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BudgetScopeSession names the budget that covers every call in a session
+const BudgetScopeSession = "session"
+
+// BudgetLimits caps what calls may do. A zero field means unlimited.
+type BudgetLimits struct {
+	// CallsPerMinute caps calls in any sliding one-minute window
+	CallsPerMinute int `json:"calls_per_minute,omitempty"`
+
+	// Concurrent caps calls running at the same time
+	Concurrent int `json:"concurrent,omitempty"`
+
+	// BytesWritten caps the total bytes written to disk
+	BytesWritten int64 `json:"bytes_written,omitempty"`
+
+	// CPUSeconds caps the total CPU time used by commands
+	CPUSeconds float64 `json:"cpu_seconds,omitempty"`
+
+	// OutputBytes caps the total size of results sent to the client
+	OutputBytes int64 `json:"output_bytes,omitempty"`
+}
+
+// IsZero reports whether no limit is set
+func (l BudgetLimits) IsZero() bool {
+	return l == BudgetLimits{}
+}
+
+// BudgetConfig holds the budgets applied to each session
+type BudgetConfig struct {
+	// Session limits all calls in a session together
+	Session BudgetLimits
+
+	// Tools limits the calls to a single tool within a session, by tool name
+	Tools map[string]BudgetLimits
+}
+
+// DefaultBudgetConfig returns a configuration with no limits
+func DefaultBudgetConfig() *BudgetConfig {
+	return &BudgetConfig{
+		Tools: make(map[string]BudgetLimits),
+	}
+}
+
+// NewBudgetConfigFromEnv creates a budget configuration from MCP_BUDGETS, or
+// returns an error if it is set but invalid
+func NewBudgetConfigFromEnv() (*BudgetConfig, error) {
+	if spec := os.Getenv("MCP_BUDGETS"); spec != "" {
+		return ParseBudgets(spec)
+	}
+	return DefaultBudgetConfig(), nil
+}
+
+// ParseBudgets parses a budget specification. Scopes are separated by semicolons;
+// each is "session" or a tool name, followed by a colon and comma-separated limits:
+//
+//	session:calls_per_minute=120,concurrent=4;execute_shell_command:cpu_seconds=300
+func ParseBudgets(spec string) (*BudgetConfig, error) {
+	cfg := DefaultBudgetConfig()
+
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		scope, limitsSpec, found := strings.Cut(part, ":")
+		scope = strings.TrimSpace(scope)
+		if !found || scope == "" {
+			return nil, fmt.Errorf("invalid budget %q (expected scope:name=value,...)", part)
+		}
+
+		var limits BudgetLimits
+		if scope == BudgetScopeSession {
+			limits = cfg.Session
+		} else {
+			limits = cfg.Tools[scope]
+		}
+
+		for _, limit := range strings.Split(limitsSpec, ",") {
+			name, value, found := strings.Cut(strings.TrimSpace(limit), "=")
+			if !found {
+				return nil, fmt.Errorf("invalid limit %q in budget for %s", limit, scope)
+			}
+			if err := limits.set(strings.TrimSpace(name), strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("budget for %s: %w", scope, err)
+			}
+		}
+
+		if scope == BudgetScopeSession {
+			cfg.Session = limits
+		} else {
+			cfg.Tools[scope] = limits
+		}
+	}
+
+	return cfg, nil
+}
+
+// set parses a single limit into l
+func (l *BudgetLimits) set(name, value string) error {
+	var err error
+	switch name {
+	case "calls_per_minute":
+		l.CallsPerMinute, err = strconv.Atoi(value)
+	case "concurrent":
+		l.Concurrent, err = strconv.Atoi(value)
+	case "bytes_written":
+		l.BytesWritten, err = strconv.ParseInt(value, 10, 64)
+	case "cpu_seconds":
+		l.CPUSeconds, err = strconv.ParseFloat(value, 64)
+	case "output_bytes":
+		l.OutputBytes, err = strconv.ParseInt(value, 10, 64)
+	default:
+		return fmt.Errorf("unknown limit %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %q", name, value)
+	}
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("%s must not be negative", name)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// LogConfig controls where and how the server writes its logs
+type LogConfig struct {
+	// Level is the minimum level to log: "debug", "info", "warn" or "error"
+	Level string
+
+	// Format is either "text" or "json"
+	Format string
+
+	// Path is the log file location; an empty path disables file logging
+	Path string
+
+	// MaxSizeMB is the size at which the log file is rotated
+	MaxSizeMB int
+
+	// MaxBackups is the number of rotated files to keep
+	MaxBackups int
+
+	// Stderr additionally writes logs to standard error
+	Stderr bool
+}
+
+// DefaultLogConfig returns the default logging configuration
+func DefaultLogConfig() *LogConfig {
+	return &LogConfig{
+		Level:      "info",
+		Format:     "text",
+		Path:       DefaultLogPath(),
+		MaxSizeMB:  10,
+		MaxBackups: 3,
+	}
+}
+
+// NewLogConfigFromEnv creates a logging configuration from environment variables
+func NewLogConfigFromEnv() *LogConfig {
+	cfg := DefaultLogConfig()
+
+	if level := os.Getenv("MCP_LOG_LEVEL"); level != "" {
+		cfg.Level = level
+	}
+
+	if format := os.Getenv("MCP_LOG_FORMAT"); format != "" {
+		cfg.Format = format
+	}
+
+	if path, ok := os.LookupEnv("MCP_LOG_FILE"); ok {
+		cfg.Path = path
+	}
+
+	if size, err := strconv.Atoi(os.Getenv("MCP_LOG_MAX_SIZE_MB")); err == nil && size > 0 {
+		cfg.MaxSizeMB = size
+	}
+
+	if backups, err := strconv.Atoi(os.Getenv("MCP_LOG_MAX_BACKUPS")); err == nil && backups >= 0 {
+		cfg.MaxBackups = backups
+	}
+
+	if stderr, err := strconv.ParseBool(os.Getenv("MCP_LOG_STDERR")); err == nil {
+		cfg.Stderr = stderr
+	}
+
+	return cfg
+}
+
+// DefaultLogPath returns the log file location under the XDG state directory,
+// falling back to ~/.local/state when XDG_STATE_HOME is not set
+func DefaultLogPath() string {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		stateDir = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(stateDir, "mcp-server", "mcp-server.log")
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PathDecision records the outcome of a path policy check and the rule that produced it
+type PathDecision struct {
+	Path    string `json:"path"`
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule"`
+}
+
+// CheckPath evaluates the path policy for path and explains which rule decided it.
+// The verdict always comes from IsPathAllowed; the rule is the first deny entry
+// or allowed root that matches the path.
+func (c *ServerConfig) CheckPath(path string) (PathDecision, error) {
+	decision := PathDecision{Path: path}
+
+	allowed, err := c.IsPathAllowed(path)
+	if err != nil {
+		decision.Rule = "error"
+		return decision, err
+	}
+	decision.Allowed = allowed
+
+	absPath, absErr := filepath.Abs(path)
+	if absErr != nil {
+		absPath = filepath.Clean(path)
+	}
+
+	if allowed {
+		decision.Rule = "allow:" + firstMatchingRule(absPath, c.AllowedPaths)
+		return decision, nil
+	}
+
+	if rule := firstMatchingRule(absPath, c.DenyListPaths); rule != "" {
+		decision.Rule = "deny:" + rule
+	} else {
+		decision.Rule = "default:outside-allowed-paths"
+	}
+
+	return decision, nil
+}
+
+// firstMatchingRule returns the first rule that covers path. Absolute rules match
+// the path and everything below it; relative rules match any path component.
+func firstMatchingRule(path string, rules []string) string {
+	for _, rule := range rules {
+		if rule == "" {
+			continue
+		}
+
+		if filepath.IsAbs(rule) {
+			root := filepath.Clean(rule)
+			if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+				return rule
+			}
+			continue
+		}
+
+		for _, component := range strings.Split(path, string(filepath.Separator)) {
+			if component == filepath.Clean(rule) {
+				return rule
+			}
+		}
+	}
+
+	return ""
+}
+
+// String formats the decision for log messages
+func (d PathDecision) String() string {
+	verdict := "denied"
+	if d.Allowed {
+		verdict = "allowed"
+	}
+	return fmt.Sprintf("%s %s (%s)", verdict, d.Path, d.Rule)
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RootsMode says how the roots an MCP client declares shape the path policy
+type RootsMode string
+
+const (
+	// RootsIntersect allows only the parts of the allowed paths that lie inside a
+	// client root. This is the default.
+	RootsIntersect RootsMode = "intersect"
+
+	// RootsOnly allows the client roots in place of the allowed paths. Nothing is
+	// allowed until the client has declared its roots.
+	RootsOnly RootsMode = "only"
+
+	// RootsIgnore leaves the allowed paths as configured
+	RootsIgnore RootsMode = "ignore"
+)
+
+// ParseRootsMode parses a roots mode; an empty string is RootsIntersect
+func ParseRootsMode(mode string) (RootsMode, error) {
+	switch RootsMode(strings.TrimSpace(mode)) {
+	case "", RootsIntersect:
+		return RootsIntersect, nil
+	case RootsOnly:
+		return RootsOnly, nil
+	case RootsIgnore:
+		return RootsIgnore, nil
+	}
+	return "", fmt.Errorf("invalid roots mode %q (expected intersect, only or ignore)", mode)
+}
+
+// NewRootsModeFromEnv reads the roots mode from MCP_ROOTS, defaulting to RootsIntersect
+func NewRootsModeFromEnv() RootsMode {
+	mode, err := ParseRootsMode(os.Getenv("MCP_ROOTS"))
+	if err != nil {
+		return RootsIntersect
+	}
+	return mode
+}
+
+// WithRoots returns a copy of c whose allowed paths follow the client roots
+// according to mode. Deny rules are kept as they are.
+func (c *ServerConfig) WithRoots(roots []string, mode RootsMode) *ServerConfig {
+	scoped := *c
+	switch mode {
+	case RootsIgnore:
+	case RootsOnly:
+		scoped.AllowedPaths = append([]string(nil), roots...)
+	default:
+		scoped.AllowedPaths = IntersectPaths(c.AllowedPaths, roots)
+	}
+	return &scoped
+}
+
+// IntersectPaths returns the directories that are inside both an allowed path and a
+// root: for each pair, whichever of the two lies inside the other
+func IntersectPaths(allowed, roots []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+
+	// within reports whether path is root or below it
+	within := func(path, root string) bool {
+		return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+	}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			result = append(result, path)
+		}
+	}
+
+	for _, allowedPath := range allowed {
+		absAllowed, err := filepath.Abs(allowedPath)
+		if err != nil {
+			continue
+		}
+		for _, root := range roots {
+			absRoot, err := filepath.Abs(root)
+			if err != nil {
+				continue
+			}
+
+			switch {
+			case within(absRoot, absAllowed):
+				add(absRoot)
+			case within(absAllowed, absRoot):
+				add(absAllowed)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Supported listen address schemes
+const (
+	ListenStdio = "stdio"
+	ListenUnix  = "unix"
+)
+
+// TransportConfig describes how the server accepts client connections
+type TransportConfig struct {
+	// Listen is either "stdio" or "unix:/path/to.sock"
+	Listen string
+
+	// AllowedUIDs lists the user IDs allowed to connect to a unix socket.
+	// When both AllowedUIDs and AllowedGIDs are empty only the server's own UID is allowed.
+	AllowedUIDs []int
+
+	// AllowedGIDs lists the group IDs allowed to connect to a unix socket. A peer is
+	// allowed if one of them is its primary group or one of its supplementary groups.
+	AllowedGIDs []int
+}
+
+// DefaultTransportConfig returns a transport configuration that serves a single client over stdio
+func DefaultTransportConfig() *TransportConfig {
+	return &TransportConfig{
+		Listen: ListenStdio,
+	}
+}
+
+// NewTransportConfigFromEnv creates a transport configuration from environment variables
+func NewTransportConfigFromEnv() *TransportConfig {
+	cfg := DefaultTransportConfig()
+
+	if listen := os.Getenv("MCP_LISTEN"); listen != "" {
+		cfg.Listen = listen
+	}
+
+	if uids := os.Getenv("MCP_ALLOWED_UIDS"); uids != "" {
+		if ids, err := ParseIDList(uids); err == nil {
+			cfg.AllowedUIDs = ids
+		}
+	}
+
+	if gids := os.Getenv("MCP_ALLOWED_GIDS"); gids != "" {
+		if ids, err := ParseIDList(gids); err == nil {
+			cfg.AllowedGIDs = ids
+		}
+	}
+
+	return cfg
+}
+
+// ParseListen splits the listen address into its scheme and socket path
+func (c *TransportConfig) ParseListen() (scheme string, path string, err error) {
+	if c.Listen == "" || c.Listen == ListenStdio {
+		return ListenStdio, "", nil
+	}
+
+	scheme, path, found := strings.Cut(c.Listen, ":")
+	if !found || scheme != ListenUnix {
+		return "", "", fmt.Errorf("unsupported listen address %q (expected \"stdio\" or \"unix:/path/to.sock\")", c.Listen)
+	}
+	if path == "" {
+		return "", "", fmt.Errorf("listen address %q is missing a socket path", c.Listen)
+	}
+
+	return scheme, path, nil
+}
+
+// IsPeerAllowed reports whether a connecting process with the given credentials may
+// use the server; gids holds its primary group and any supplementary groups
+func (c *TransportConfig) IsPeerAllowed(uid int, gids ...int) bool {
+	if len(c.AllowedUIDs) == 0 && len(c.AllowedGIDs) == 0 {
+		return uid == os.Getuid()
+	}
+
+	for _, allowed := range c.AllowedUIDs {
+		if uid == allowed {
+			return true
+		}
+	}
+
+	for _, allowed := range c.AllowedGIDs {
+		for _, gid := range gids {
+			if gid == allowed {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ParseIDList parses a comma-separated list of numeric user or group IDs
+func ParseIDList(list string) ([]int, error) {
+	var ids []int
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.Atoi(field)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("invalid ID %q", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,334 @@
+// Package dataquery parses JSON, YAML and TOML documents into a common tree and
+// finds the nodes in it that a JSONPath or jq-style query selects.
+//
+// Documents are trees of *Object, []interface{}, string, int64, float64, bool and
+// nil. Objects keep their keys in document order, so matches come out in the order
+// they appear in the file, and every match carries its Path from the root, for
+// tools that go on to change the document. Infinities and NaNs, which JSON can't
+// represent, become the strings "inf", "-inf" and "nan".
+package dataquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of documents
+const (
+	JSON = "json"
+	YAML = "yaml"
+	TOML = "toml"
+)
+
+// maxNodes is the most nodes a document may have once YAML aliases are expanded,
+// so a small file can't expand into an enormous tree
+const maxNodes = 1000000
+
+// ErrTooManyNodes is returned for documents with more than maxNodes nodes
+var ErrTooManyNodes = errors.New("document has too many nodes")
+
+// Object is a mapping whose keys keep the order they had in the document
+type Object struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+// NewObject returns an empty Object
+func NewObject() *Object {
+	return &Object{Values: make(map[string]interface{})}
+}
+
+// Get returns the value of key, and whether the object has it
+func (o *Object) Get(key string) (interface{}, bool) {
+	value, ok := o.Values[key]
+	return value, ok
+}
+
+// Set sets key to value, adding it after the existing keys if it is new
+func (o *Object) Set(key string, value interface{}) {
+	if _, ok := o.Values[key]; !ok {
+		o.Keys = append(o.Keys, key)
+	}
+	o.Values[key] = value
+}
+
+// MarshalJSON encodes the object with its keys in order
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		encoded, err := json.Marshal(o.Values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// DetectFormat guesses the format of a document from its file name, or failing
+// that from its first non-blank character. It returns "" if it can't tell.
+func DetectFormat(name string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".jsonc", ".geojson", ".webmanifest":
+		return JSON
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	}
+	switch trimmed := bytes.TrimSpace(data); {
+	case len(trimmed) == 0:
+		return ""
+	case trimmed[0] == '{' || trimmed[0] == '[':
+		return JSON
+	case bytes.HasPrefix(trimmed, []byte("---")):
+		return YAML
+	}
+	return ""
+}
+
+// Parse parses data in format into its documents. JSON and TOML files hold one
+// document; a YAML stream may hold several, separated by ---.
+func Parse(data []byte, format string) ([]interface{}, error) {
+	switch format {
+	case JSON:
+		doc, err := parseJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{doc}, nil
+	case YAML:
+		return parseYAML(data)
+	case TOML:
+		doc, err := parseTOML(data)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{doc}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// parseJSON decodes one JSON value, keeping the order of object keys
+func parseJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	value, err := decodeJSON(dec)
+	if err != nil {
+		return nil, jsonError(dec, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top-level value at offset %d", dec.InputOffset())
+	}
+	return value, nil
+}
+
+// decodeJSON decodes the next value from dec
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			obj := NewObject()
+			for dec.More() {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.Set(keyToken.(string), value)
+			}
+			_, err := dec.Token()
+			return obj, err
+		}
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		return t.Float64()
+	}
+	return token, nil
+}
+
+// jsonError adds where in the input a JSON error happened
+func jsonError(dec *json.Decoder, err error) error {
+	if errors.Is(err, io.EOF) {
+		return errors.New("unexpected end of JSON input")
+	}
+	return fmt.Errorf("%v at offset %d", err, dec.InputOffset())
+}
+
+// parseYAML decodes every document in a YAML stream
+func parseYAML(data []byte) ([]interface{}, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []interface{}
+	nodes := 0
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc, err := convertYAML(&node, &nodes)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// convertYAML converts a YAML node into the document tree, counting the nodes it
+// creates in nodes
+func convertYAML(node *yaml.Node, nodes *int) (interface{}, error) {
+	if *nodes++; *nodes > maxNodes {
+		return nil, ErrTooManyNodes
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return convertYAML(node.Content[0], nodes)
+	case yaml.AliasNode:
+		return convertYAML(node.Alias, nodes)
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := convertYAML(item, nodes)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		obj := NewObject()
+		var merges []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				merges = append(merges, value)
+				continue
+			}
+			converted, err := convertYAML(value, nodes)
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(key.Value, converted)
+		}
+		// Keys merged in with << don't override the mapping's own
+		for _, merge := range merges {
+			if err := mergeYAML(obj, merge, nodes); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return convertScalar(node)
+}
+
+// mergeYAML adds the keys of the mapping, or list of mappings, that a << key merges
+// into obj, where obj doesn't already have them
+func mergeYAML(obj *Object, merge *yaml.Node, nodes *int) error {
+	value, err := convertYAML(merge, nodes)
+	if err != nil {
+		return err
+	}
+	sources, ok := value.([]interface{})
+	if !ok {
+		sources = []interface{}{value}
+	}
+	for _, source := range sources {
+		from, ok := source.(*Object)
+		if !ok {
+			return fmt.Errorf("line %d: << must merge a mapping or a list of mappings", merge.Line)
+		}
+		for _, key := range from.Keys {
+			if _, exists := obj.Get(key); !exists {
+				obj.Set(key, from.Values[key])
+			}
+		}
+	}
+	return nil
+}
+
+// convertScalar converts a YAML scalar by its resolved tag. Timestamps and unknown
+// tags keep their text.
+func convertScalar(node *yaml.Node) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool", "!!int", "!!float":
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", node.Line, err)
+		}
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case uint64:
+			// Too large for an int64
+			f, err := strconv.ParseFloat(node.Value, 64)
+			return f, err
+		case float64:
+			return finite(v), nil
+		}
+		return value, nil
+	}
+	return node.Value, nil
+}
+
+// finite returns f, or a string naming it if it is infinite or NaN
+func finite(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return f
+}
@@ -0,0 +1,155 @@
+package dataquery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// encode returns docs as JSON
+func encode(t *testing.T, docs []interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		expected string
+	}{
+		{"json keeps key order", JSON, `{"b": 1, "a": [true, null, 1.5, "x"]}`, `[{"b":1,"a":[true,null,1.5,"x"]}]`},
+		{"yaml", YAML, "b: 1\na:\n  - yes\n  - ~\n  - 1.5\n  - '01'\n", `[{"b":1,"a":["yes",null,1.5,"01"]}]`},
+		{"yaml documents", YAML, "kind: A\n---\nkind: B\n", `[{"kind":"A"},{"kind":"B"}]`},
+		{"yaml anchors and merges", YAML, "base: &base {a: 1, b: 2}\nderived:\n  <<: *base\n  b: 3\n", `[{"base":{"a":1,"b":2},"derived":{"b":3,"a":1}}]`},
+		{"yaml special floats", YAML, "a: .inf\nb: -.inf\nc: .nan\n", `[{"a":"inf","b":"-inf","c":"nan"}]`},
+		{
+			"toml",
+			TOML,
+			`# comment
+title = "TOML \"example\"" # trailing comment
+literal = 'C:\path'
+hex = 0xff
+big = 1_000
+float = 6.626e-34
+inf = -inf
+date = 1979-05-27T07:32:00Z
+spaced = 1979-05-27 07:32:00
+local = 07:32:00
+array = [ 1, 2,
+  3, # three
+]
+inline = { x = 1, y.z = "two" }
+site."google.com" = true
+
+[server.alpha]
+ip = "10.0.0.1"
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+[products.size]
+mm = 3
+`,
+			`[{"title":"TOML \"example\"","literal":"C:\\path","hex":255,"big":1000,"float":6.626e-34,"inf":"-inf",` +
+				`"date":"1979-05-27T07:32:00Z","spaced":"1979-05-27 07:32:00","local":"07:32:00","array":[1,2,3],` +
+				`"inline":{"x":1,"y":{"z":"two"}},"site":{"google.com":true},"server":{"alpha":{"ip":"10.0.0.1"}},` +
+				`"products":[{"name":"Hammer"},{"name":"Nail","size":{"mm":3}}]}]`,
+		},
+		{
+			"toml multi-line strings",
+			TOML,
+			"a = \"\"\"\none \\\n   two\"\"\"\nb = '''\nraw \\n'''\nc = \"\\u00e9\\t\"\n",
+			`[{"a":"one two","b":"raw \\n","c":"é\t"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := Parse([]byte(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := encode(t, docs); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		line   string
+	}{
+		{"json syntax", JSON, `{"a": }`, "offset"},
+		{"json trailing data", JSON, `{} {}`, "after the top-level value"},
+		{"yaml syntax", YAML, "a: [1, 2\n", "line"},
+		{"toml duplicate key", TOML, "a = 1\na = 2\n", "line 2"},
+		{"toml duplicate table", TOML, "[a]\nx = 1\n[a]\n", "line 3"},
+		{"toml extends inline table", TOML, "a = {x = 1}\n[a.b]\n", "line 2"},
+		{"toml unterminated string", TOML, "a = \"abc\n", "line 1"},
+		{"toml missing value", TOML, "a =\n", "line 1"},
+		{"toml trailing text", TOML, "a = 1 b\n", "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.line, err)
+			}
+		})
+	}
+}
+
+func TestParse_AliasExpansion(t *testing.T) {
+	// Each level refers to the one before ten times
+	var b strings.Builder
+	b.WriteString("a0: &a0 [x, x, x, x, x, x, x, x, x, x]\n")
+	for i := 1; i <= 8; i++ {
+		b.WriteString(strings.Replace("aN: &aN [", "N", string(rune('0'+i)), 2))
+		for j := 0; j < 10; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("*a" + string(rune('0'+i-1)))
+		}
+		b.WriteString("]\n")
+	}
+
+	if _, err := Parse([]byte(b.String()), YAML); err != ErrTooManyNodes {
+		t.Errorf("Expected ErrTooManyNodes, got %v", err)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"package-lock.json", "", JSON},
+		{"deploy.YML", "", YAML},
+		{"Cargo.toml", "", TOML},
+		{"data", "  [1, 2]", JSON},
+		{"data", "---\na: 1", YAML},
+		{"data", "a = 1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.name, []byte(tt.data)); got != tt.expected {
+			t.Errorf("DetectFormat(%q, %q): expected %q, got %q", tt.name, tt.data, tt.expected, got)
+		}
+	}
+}
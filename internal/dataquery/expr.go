@@ -0,0 +1,152 @@
+package dataquery
+
+import (
+	"cmp"
+	"reflect"
+	"regexp"
+)
+
+// expr is a condition in a filter or select, or an operand of one. It evaluates to
+// a list of values: a path operand to the values it reaches, which may be none.
+type expr interface {
+	eval(root, current interface{}) []interface{}
+}
+
+// literalExpr is a string, number, boolean or null
+type literalExpr struct {
+	value interface{}
+}
+
+func (e literalExpr) eval(_, _ interface{}) []interface{} {
+	return []interface{}{e.value}
+}
+
+// pathOperand is a path from the node being tested (@ or .) or from the root ($)
+type pathOperand struct {
+	path *pathExpr
+}
+
+func (e pathOperand) eval(root, current interface{}) []interface{} {
+	matches := e.path.eval(root, Match{Path: Path{}, Value: current}, nil)
+	values := make([]interface{}, len(matches))
+	for i, m := range matches {
+		values[i] = m.Value
+	}
+	return values
+}
+
+// notExpr negates a condition
+type notExpr struct {
+	operand expr
+}
+
+func (e notExpr) eval(root, current interface{}) []interface{} {
+	return []interface{}{!truthy(e.operand.eval(root, current))}
+}
+
+// logicalExpr is a && b, or a || b when or is set
+type logicalExpr struct {
+	or          bool
+	left, right expr
+}
+
+func (e logicalExpr) eval(root, current interface{}) []interface{} {
+	left := truthy(e.left.eval(root, current))
+	if left == e.or {
+		return []interface{}{left}
+	}
+	return []interface{}{truthy(e.right.eval(root, current))}
+}
+
+// compareExpr compares two operands. It holds if any value of the left operand
+// compares as op says with any value of the right, so a path that reaches nothing
+// never compares.
+type compareExpr struct {
+	op          string
+	left, right expr
+	// pattern is the compiled right operand of =~
+	pattern *regexp.Regexp
+}
+
+func (e compareExpr) eval(root, current interface{}) []interface{} {
+	rights := []interface{}{nil}
+	if e.pattern == nil {
+		rights = e.right.eval(root, current)
+	}
+	for _, left := range e.left.eval(root, current) {
+		for _, right := range rights {
+			if e.compare(left, right) {
+				return []interface{}{true}
+			}
+		}
+	}
+	return []interface{}{false}
+}
+
+// compare compares two values. Numbers compare with numbers and strings with
+// strings; other values can only be equal or not.
+func (e compareExpr) compare(a, b interface{}) bool {
+	if e.pattern != nil {
+		s, ok := a.(string)
+		return ok && e.pattern.MatchString(s)
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return ordered(e.op, fa, fb)
+		}
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return ordered(e.op, sa, sb)
+		}
+	}
+
+	switch e.op {
+	case "==":
+		return reflect.DeepEqual(a, b)
+	case "!=":
+		return !reflect.DeepEqual(a, b)
+	}
+	return false
+}
+
+// ordered compares a and b with op
+func ordered[T cmp.Ordered](op string, a, b T) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+// toFloat returns a number as a float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// truthy reports whether any of values is something other than null or false
+func truthy(values []interface{}) bool {
+	for _, v := range values {
+		if v != nil && v != false {
+			return true
+		}
+	}
+	return false
+}
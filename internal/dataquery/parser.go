@@ -0,0 +1,443 @@
+package dataquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parser parses a query
+type parser struct {
+	src string
+	pos int
+}
+
+// parseStage parses one stage of a pipeline
+func (p *parser) parseStage() (stage, error) {
+	if p.consumeWord("select") {
+		p.skipSpace()
+		if !p.consume("(") {
+			return stage{}, p.errorf("expected ( after select")
+		}
+		cond, err := p.parseExpr()
+		if err != nil {
+			return stage{}, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return stage{}, p.errorf("expected ) to close select")
+		}
+		return stage{filter: cond}, nil
+	}
+
+	path, err := p.parsePath()
+	if err != nil {
+		return stage{}, err
+	}
+	return stage{path: path}, nil
+}
+
+// parsePath parses a path starting with $, @ or .
+func (p *parser) parsePath() (*pathExpr, error) {
+	path := &pathExpr{}
+	start := p.pos
+	switch {
+	case p.consume("$"):
+		path.fromRoot = true
+	case p.consume("@"):
+	case p.at("."):
+	default:
+		if p.eof() {
+			return nil, p.errorf("expected a path")
+		}
+		return nil, p.errorf("expected a path starting with $, @ or ., found %q", p.rest())
+	}
+
+	for !p.eof() {
+		switch {
+		case p.consume(".."):
+			path.steps = append(path.steps, descendStep{})
+			if s, ok, err := p.parseDotted(); err != nil {
+				return nil, err
+			} else if ok {
+				path.steps = append(path.steps, s)
+			}
+		case p.consume("."):
+			s, ok, err := p.parseDotted()
+			if err != nil {
+				return nil, err
+			}
+			// A path may be just ., the current node, as in jq
+			if ok {
+				path.steps = append(path.steps, s)
+			} else if !p.at("[") && p.pos-1 != start {
+				return nil, p.errorf("expected a name after .")
+			}
+		case p.consume("["):
+			s, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			path.steps = append(path.steps, s)
+		default:
+			return path, nil
+		}
+	}
+	return path, nil
+}
+
+// parseDotted parses what may follow a dot: a name, a quoted name or *. ok is false
+// if there is none of those.
+func (p *parser) parseDotted() (s step, ok bool, err error) {
+	switch {
+	case p.consume("*"):
+		return wildcardStep{}, true, nil
+	case p.at(`"`) || p.at("'"):
+		key, err := p.parseString()
+		if err != nil {
+			return nil, false, err
+		}
+		return childStep{keys: []string{key}}, true, nil
+	}
+
+	start := p.pos
+	for !p.eof() && isNameChar(p.src[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, false, nil
+	}
+	return childStep{keys: []string{p.src[start:p.pos]}}, true, nil
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' ||
+		!first && (c >= '0' && c <= '9' || c == '-')
+}
+
+// parseBracket parses what follows a [: *, nothing, a filter, a slice, or a list
+// of keys or indexes
+func (p *parser) parseBracket() (step, error) {
+	p.skipSpace()
+	switch {
+	case p.consume("]"):
+		return wildcardStep{}, nil
+	case p.consume("*"):
+		return wildcardStep{}, p.closeBracket()
+	case p.consume("?"):
+		cond, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return filterStep{cond: cond}, p.closeBracket()
+	case p.at(`"`) || p.at("'"):
+		var keys []string
+		for {
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			p.skipSpace()
+			if !p.consume(",") {
+				return childStep{keys: keys}, p.closeBracket()
+			}
+			p.skipSpace()
+		}
+	}
+
+	// Indexes and slices
+	first, err := p.parseOptionalInt()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.at(":") {
+		s := sliceStep{start: first, step: 1}
+		p.consume(":")
+		p.skipSpace()
+		if s.end, err = p.parseOptionalInt(); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.consume(":") {
+			p.skipSpace()
+			n, err := p.parseOptionalInt()
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				if *n < 1 {
+					return nil, p.errorf("slice step must be at least 1")
+				}
+				s.step = *n
+			}
+		}
+		return s, p.closeBracket()
+	}
+
+	if first == nil {
+		return nil, p.errorf("expected an index, key, slice, * or filter in brackets")
+	}
+	indexes := []int{*first}
+	for {
+		p.skipSpace()
+		if !p.consume(",") {
+			return indexStep{indexes: indexes}, p.closeBracket()
+		}
+		p.skipSpace()
+		n, err := p.parseOptionalInt()
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			return nil, p.errorf("expected an index after ,")
+		}
+		indexes = append(indexes, *n)
+	}
+}
+
+// closeBracket consumes the ] that ends a bracket step
+func (p *parser) closeBracket() error {
+	p.skipSpace()
+	if !p.consume("]") {
+		return p.errorf("expected ]")
+	}
+	return nil
+}
+
+// parseOptionalInt parses an integer, if there is one
+func (p *parser) parseOptionalInt() (*int, error) {
+	start := p.pos
+	if p.at("-") {
+		p.pos++
+	}
+	for !p.eof() && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	text := p.src[start:p.pos]
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid index %q", text)
+	}
+	return &n, nil
+}
+
+// parseExpr parses a condition
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseLogical(true)
+}
+
+// parseLogical parses conditions joined by || (or) or by && (and)
+func (p *parser) parseLogical(or bool) (expr, error) {
+	next := func() (expr, error) {
+		if or {
+			return p.parseLogical(false)
+		}
+		return p.parseUnary()
+	}
+	symbol, word := "&&", "and"
+	if or {
+		symbol, word = "||", "or"
+	}
+
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume(symbol) && !p.consumeWord(word) {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{or: or, left: left, right: right}
+	}
+}
+
+// parseUnary parses a negated condition or a comparison
+func (p *parser) parseUnary() (expr, error) {
+	p.skipSpace()
+	if (p.at("!") && !p.at("!=")) || p.at("not ") || p.at("not(") {
+		if !p.consume("!") {
+			p.consumeWord("not")
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand: operand}, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		p.skipSpace()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		e := compareExpr{op: op, left: left, right: right}
+		if op == "=~" {
+			literal, ok := right.(literalExpr)
+			pattern, isString := literal.value.(string)
+			if !ok || !isString {
+				return nil, p.errorf("=~ needs a string pattern")
+			}
+			if e.pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, p.errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+		return e, nil
+	}
+	return left, nil
+}
+
+// parseOperand parses a literal, a path or a parenthesized condition
+func (p *parser) parseOperand() (expr, error) {
+	p.skipSpace()
+	switch {
+	case p.consume("("):
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return e, nil
+	case p.at(`"`) || p.at("'"):
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{value: s}, nil
+	case p.consumeWord("true"):
+		return literalExpr{value: true}, nil
+	case p.consumeWord("false"):
+		return literalExpr{value: false}, nil
+	case p.consumeWord("null"):
+		return literalExpr{value: nil}, nil
+	case p.at("$") || p.at("@") || p.at("."):
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path: path}, nil
+	}
+
+	// Numbers
+	start := p.pos
+	for !p.eof() && strings.IndexByte("+-0123456789.eE", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	if p.pos > start {
+		text := p.src[start:p.pos]
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return literalExpr{value: n}, nil
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return literalExpr{value: f}, nil
+		}
+		p.pos = start
+		return nil, p.errorf("invalid number %q", text)
+	}
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	return nil, p.errorf("expected a value, found %q", p.rest())
+}
+
+// parseString parses a string in single or double quotes. Double-quoted strings
+// take Go's escapes; in single-quoted ones, \' and \\ are the only escapes.
+func (p *parser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	start := p.pos
+	p.pos++
+	for !p.eof() && p.src[p.pos] != quote {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.eof() {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+
+	literal := p.src[start:p.pos]
+	if quote == '\'' {
+		return strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(literal[1 : len(literal)-1]), nil
+	}
+	s, err := strconv.Unquote(literal)
+	if err != nil {
+		p.pos = start
+		return "", p.errorf("invalid string %s", literal)
+	}
+	return s, nil
+}
+
+// skipSpace skips spaces, tabs and newlines
+func (p *parser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume consumes s if the query continues with it
+func (p *parser) consume(s string) bool {
+	if p.at(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// consumeWord consumes word if the query continues with it, followed by something
+// that can't be part of a name
+func (p *parser) consumeWord(word string) bool {
+	end := p.pos + len(word)
+	if !p.at(word) || (end < len(p.src) && isNameChar(p.src[end], false)) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *parser) at(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// rest returns the unparsed part of the query, shortened for error messages
+func (p *parser) rest() string {
+	rest := p.src[p.pos:]
+	if len(rest) > 20 {
+		rest = rest[:20] + "..."
+	}
+	return rest
+}
+
+// errorf returns an error at the current position
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid query at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
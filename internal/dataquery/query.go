@@ -0,0 +1,251 @@
+package dataquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query is a compiled query. It accepts JSONPath, such as
+// $.spec.containers[?(@.name == 'app')].image, and the path and select parts of jq,
+// such as .items[] | select(.kind == "Service") | .metadata.name.
+type Query struct {
+	stages []stage
+}
+
+// Match is a node a query selected, with its path from the document's root
+type Match struct {
+	Path  Path
+	Value interface{}
+}
+
+// PathElem is one step of a Path: a key into an object, or an index into an array
+type PathElem struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// Path leads from the root of a document to one of its nodes
+type Path []PathElem
+
+// identifier matches keys that can be written after a dot in a path
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// String formats the path in JSONPath's dot and bracket notation, such as
+// $.spec.containers[0]['image-name']
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, elem := range p {
+		switch {
+		case elem.IsIndex:
+			fmt.Fprintf(&b, "[%d]", elem.Index)
+		case identifier.MatchString(elem.Key):
+			b.WriteByte('.')
+			b.WriteString(elem.Key)
+		default:
+			b.WriteString("['")
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(elem.Key))
+			b.WriteString("']")
+		}
+	}
+	return b.String()
+}
+
+// child returns the path to a child of the node at p, without sharing p's array
+func (p Path) child(elem PathElem) Path {
+	return append(p[:len(p):len(p)], elem)
+}
+
+// stage is one part of a jq pipeline: a path, or a select filter
+type stage struct {
+	path   *pathExpr
+	filter expr
+}
+
+// pathExpr is a path from the root ($), or from the current node (@ or .)
+type pathExpr struct {
+	fromRoot bool
+	steps    []step
+}
+
+// step selects nodes from each node a path has reached so far
+type step interface {
+	apply(root interface{}, from Match, out []Match) []Match
+}
+
+// Compile parses a query
+func Compile(query string) (*Query, error) {
+	p := &parser{src: query}
+	q := &Query{}
+	for {
+		p.skipSpace()
+		s, err := p.parseStage()
+		if err != nil {
+			return nil, err
+		}
+		q.stages = append(q.stages, s)
+
+		p.skipSpace()
+		if p.eof() {
+			return q, nil
+		}
+		if !p.consume("|") {
+			return nil, p.errorf("unexpected %q", p.rest())
+		}
+	}
+}
+
+// Eval returns the nodes of doc that the query selects, in document order
+func (q *Query) Eval(doc interface{}) []Match {
+	matches := []Match{{Path: Path{}, Value: doc}}
+	for _, s := range q.stages {
+		var next []Match
+		for _, m := range matches {
+			if s.path != nil {
+				next = s.path.eval(doc, m, next)
+			} else if truthy(s.filter.eval(doc, m.Value)) {
+				next = append(next, m)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// eval appends the nodes the path reaches from current to out
+func (pe *pathExpr) eval(root interface{}, current Match, out []Match) []Match {
+	if pe.fromRoot {
+		current = Match{Path: Path{}, Value: root}
+	}
+	matches := []Match{current}
+	for _, s := range pe.steps {
+		var next []Match
+		for _, m := range matches {
+			next = s.apply(root, m, next)
+		}
+		matches = next
+	}
+	return append(out, matches...)
+}
+
+// childStep selects the values of keys of objects
+type childStep struct {
+	keys []string
+}
+
+func (s childStep) apply(_ interface{}, from Match, out []Match) []Match {
+	obj, ok := from.Value.(*Object)
+	if !ok {
+		return out
+	}
+	for _, key := range s.keys {
+		if value, ok := obj.Get(key); ok {
+			out = append(out, Match{Path: from.Path.child(PathElem{Key: key}), Value: value})
+		}
+	}
+	return out
+}
+
+// indexStep selects items of arrays; negative indexes count from the end
+type indexStep struct {
+	indexes []int
+}
+
+func (s indexStep) apply(_ interface{}, from Match, out []Match) []Match {
+	list, ok := from.Value.([]interface{})
+	if !ok {
+		return out
+	}
+	for _, i := range s.indexes {
+		if i < 0 {
+			i += len(list)
+		}
+		if i >= 0 && i < len(list) {
+			out = append(out, Match{Path: from.Path.child(PathElem{Index: i, IsIndex: true}), Value: list[i]})
+		}
+	}
+	return out
+}
+
+// sliceStep selects a range of items of arrays, as Python's list[start:end:step] does
+type sliceStep struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceStep) apply(_ interface{}, from Match, out []Match) []Match {
+	list, ok := from.Value.([]interface{})
+	if !ok {
+		return out
+	}
+	bound := func(n *int, fallback int) int {
+		if n == nil {
+			return fallback
+		}
+		i := *n
+		if i < 0 {
+			i += len(list)
+		}
+		return min(max(i, 0), len(list))
+	}
+	end := bound(s.end, len(list))
+	for i := bound(s.start, 0); i < end; i += s.step {
+		out = append(out, Match{Path: from.Path.child(PathElem{Index: i, IsIndex: true}), Value: list[i]})
+		// Stop before a huge step overflows
+		if s.step >= end-i {
+			break
+		}
+	}
+	return out
+}
+
+// wildcardStep selects every value of objects and every item of arrays
+type wildcardStep struct{}
+
+func (wildcardStep) apply(_ interface{}, from Match, out []Match) []Match {
+	return children(from, out, nil)
+}
+
+// filterStep selects the values and items for which a condition holds
+type filterStep struct {
+	cond expr
+}
+
+func (s filterStep) apply(root interface{}, from Match, out []Match) []Match {
+	return children(from, out, func(value interface{}) bool {
+		return truthy(s.cond.eval(root, value))
+	})
+}
+
+// descendStep selects a node and everything below it
+type descendStep struct{}
+
+func (descendStep) apply(root interface{}, from Match, out []Match) []Match {
+	out = append(out, from)
+	for _, child := range children(from, nil, nil) {
+		out = descendStep{}.apply(root, child, out)
+	}
+	return out
+}
+
+// children appends the values of an object or the items of an array that keep
+// accepts, or all of them if keep is nil, to out
+func children(from Match, out []Match, keep func(interface{}) bool) []Match {
+	switch v := from.Value.(type) {
+	case *Object:
+		for _, key := range v.Keys {
+			if value := v.Values[key]; keep == nil || keep(value) {
+				out = append(out, Match{Path: from.Path.child(PathElem{Key: key}), Value: value})
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if keep == nil || keep(item) {
+				out = append(out, Match{Path: from.Path.child(PathElem{Index: i, IsIndex: true}), Value: item})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package dataquery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const manifest = `{
+  "kind": "List",
+  "items": [
+    {"kind": "Deployment", "metadata": {"name": "web", "labels": {"app.kubernetes.io/name": "web"}},
+     "spec": {"replicas": 3, "containers": [{"name": "app", "image": "web:1.2"}, {"name": "proxy", "image": "envoy:1.30"}]}},
+    {"kind": "Service", "metadata": {"name": "web-svc"}, "spec": {"ports": [{"port": 80}, {"port": 443}]}},
+    {"kind": "Deployment", "metadata": {"name": "worker"}, "spec": {"replicas": 1, "containers": [{"name": "app", "image": "worker:0.9"}]}}
+  ]
+}`
+
+// formatMatches formats matches as path=value lines
+func formatMatches(t *testing.T, matches []Match) string {
+	t.Helper()
+
+	var lines []string
+	for _, m := range matches {
+		value, err := json.Marshal(m.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, m.Path.String()+"="+string(value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestQuery(t *testing.T) {
+	docs, err := Parse([]byte(manifest), JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"$.kind", `$.kind="List"`},
+		{".kind", `$.kind="List"`},
+		{"$.items[1].metadata.name", `$.items[1].metadata.name="web-svc"`},
+		{"$['items'][-1]['metadata']['name']", `$.items[2].metadata.name="worker"`},
+		{"$.items[0].metadata.labels['app.kubernetes.io/name']", `$.items[0].metadata.labels['app.kubernetes.io/name']="web"`},
+		{`.items[0].metadata.labels."app.kubernetes.io/name"`, `$.items[0].metadata.labels['app.kubernetes.io/name']="web"`},
+		{"$.items[*].kind", "$.items[0].kind=\"Deployment\"\n$.items[1].kind=\"Service\"\n$.items[2].kind=\"Deployment\""},
+		{"$.items[0,2].spec.replicas", "$.items[0].spec.replicas=3\n$.items[2].spec.replicas=1"},
+		{"$.items[1:].metadata.name", "$.items[1].metadata.name=\"web-svc\"\n$.items[2].metadata.name=\"worker\""},
+		{"$.items[::2].metadata.name", "$.items[0].metadata.name=\"web\"\n$.items[2].metadata.name=\"worker\""},
+		{"$.items[1:10:9223372036854775807].metadata.name", `$.items[1].metadata.name="web-svc"`},
+		{"$..image", "$.items[0].spec.containers[0].image=\"web:1.2\"\n$.items[0].spec.containers[1].image=\"envoy:1.30\"\n$.items[2].spec.containers[0].image=\"worker:0.9\""},
+		{"$..ports[*].port", "$.items[1].spec.ports[0].port=80\n$.items[1].spec.ports[1].port=443"},
+		{"$.items[?(@.kind == 'Service')].metadata.name", `$.items[1].metadata.name="web-svc"`},
+		{"$.items[?(@.spec.replicas > 1)].metadata.name", `$.items[0].metadata.name="web"`},
+		{"$.items[?(@.kind == 'Deployment' && !(@.spec.replicas >= 2))].metadata.name", `$.items[2].metadata.name="worker"`},
+		{"$.items[?@.metadata.name =~ '^web']..containers[?(@.name != 'app')].image", `$.items[0].spec.containers[1].image="envoy:1.30"`},
+		{"$.items[?(@.spec.containers[*].image == 'worker:0.9')].metadata.name", `$.items[2].metadata.name="worker"`},
+		{".items[] | select(.kind == \"Service\") | .spec.ports[0]", `$.items[1].spec.ports[0]={"port":80}`},
+		{".items[] | select(.kind == \"Deployment\" and .spec.replicas == 1) | .metadata", `$.items[2].metadata={"name":"worker"}`},
+		{".items[] | select(.metadata.labels) | .metadata.name", `$.items[0].metadata.name="web"`},
+		{"$.missing", ""},
+		{"$.kind[0]", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := formatMatches(t, q.Eval(docs[0])); got != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"items",
+		"$.",
+		"$.items[",
+		"$.items[?(@.kind == )]",
+		"$.items[0:1:0]",
+		"$.items | ",
+		"$[?(@.name =~ '(')]",
+		"$['unterminated]",
+		"$.a $.b",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := Compile(query); err == nil {
+				t.Errorf("Expected %q not to compile", query)
+			}
+		})
+	}
+}
+
+func TestPathString(t *testing.T) {
+	path := Path{{Key: "spec"}, {Key: "containers"}, {Index: 0, IsIndex: true}, {Key: "image-name"}, {Key: "it's"}}
+	if expected := `$.spec.containers[0]['image-name']['it\'s']`; path.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, path.String())
+	}
+}
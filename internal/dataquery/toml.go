@@ -0,0 +1,561 @@
+package dataquery
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlDateTime matches TOML's offset and local date-times, dates and times, which
+// are kept as strings
+var tomlDateTime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)$`)
+
+// tomlParser parses a TOML document. It accepts everything TOML 1.0 allows, and
+// some things it doesn't, such as newlines in inline tables.
+type tomlParser struct {
+	data []byte
+	pos  int
+	root *Object
+	// current is the table that key/value pairs go in
+	current *Object
+	// defined holds the tables that have had a [header], and sealed the tables and
+	// arrays written inline, neither of which may be added to later
+	defined map[*Object]bool
+	sealed  map[interface{}]bool
+}
+
+// parseTOML parses a TOML document
+func parseTOML(data []byte) (*Object, error) {
+	root := NewObject()
+	p := &tomlParser{data: data, root: root, current: root, defined: make(map[*Object]bool), sealed: make(map[interface{}]bool)}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (p *tomlParser) parse() error {
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			err = p.parseHeader()
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Each header or key/value pair is on a line of its own
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' && !p.at("\r\n") {
+			return p.errorf("expected the end of the line, found %q", p.peek())
+		}
+	}
+}
+
+// parseHeader parses a [table] or [[array of tables]] header
+func (p *tomlParser) parseHeader() error {
+	array := p.at("[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+
+	p.skipBlank(false)
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipBlank(false)
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !p.at(closing) {
+		return p.errorf("expected %s after the table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.descend(p.root, keys[:len(keys)-1], true)
+	if err != nil {
+		return err
+	}
+	name := keys[len(keys)-1]
+	existing, exists := parent.Get(name)
+
+	if array {
+		list, ok := existing.([]interface{})
+		if exists && (!ok || p.sealed[sealKey(list)]) {
+			return p.errorf("%s is already defined and is not an array of tables", strings.Join(keys, "."))
+		}
+		table := NewObject()
+		parent.Set(name, append(list, table))
+		p.current = table
+		return nil
+	}
+
+	if exists {
+		table, ok := existing.(*Object)
+		if !ok || p.defined[table] || p.sealed[table] {
+			return p.errorf("%s is already defined", strings.Join(keys, "."))
+		}
+		p.defined[table] = true
+		p.current = table
+		return nil
+	}
+	table := NewObject()
+	parent.Set(name, table)
+	p.defined[table] = true
+	p.current = table
+	return nil
+}
+
+// parseKeyValue parses a key = value pair into table
+func (p *tomlParser) parseKeyValue(table *Object) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipBlank(false)
+	if p.eof() || p.peek() != '=' {
+		return p.errorf("expected = after %s", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipBlank(false)
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := p.descend(table, keys[:len(keys)-1], false)
+	if err != nil {
+		return err
+	}
+	name := keys[len(keys)-1]
+	if _, exists := parent.Get(name); exists {
+		return p.errorf("%s is already defined", strings.Join(keys, "."))
+	}
+	parent.Set(name, value)
+	return nil
+}
+
+// descend follows keys down from table, creating tables that don't exist yet. In
+// headers, a key naming an array of tables continues into its last table.
+func (p *tomlParser) descend(table *Object, keys []string, header bool) (*Object, error) {
+	for i, key := range keys {
+		existing, ok := table.Get(key)
+		if !ok {
+			child := NewObject()
+			table.Set(key, child)
+			table = child
+			continue
+		}
+
+		switch v := existing.(type) {
+		case *Object:
+			if !p.sealed[v] {
+				table = v
+				continue
+			}
+		case []interface{}:
+			if header && len(v) > 0 && !p.sealed[sealKey(v)] {
+				if last, ok := v[len(v)-1].(*Object); ok {
+					table = last
+					continue
+				}
+			}
+		}
+		return nil, p.errorf("%s is already defined as a value", strings.Join(keys[:i+1], "."))
+	}
+	return table, nil
+}
+
+// sealKey returns a map key identifying an inline array
+func sealKey(list []interface{}) interface{} {
+	if len(list) == 0 {
+		return nil
+	}
+	return &list[0]
+}
+
+// parseKey parses a key, which may be dotted, into its parts
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+
+		var key string
+		var err error
+		switch c := p.peek(); {
+		case c == '"':
+			if p.at(`"""`) {
+				return nil, p.errorf("keys cannot be multi-line strings")
+			}
+			key, err = p.parseBasicString()
+		case c == '\'':
+			if p.at("'''") {
+				return nil, p.errorf("keys cannot be multi-line strings")
+			}
+			key, err = p.parseLiteralString()
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected a key, found %q", c)
+			}
+			key = string(p.data[start:p.pos])
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		p.skipBlank(false)
+		if p.eof() || p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.skipBlank(false)
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses any value
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch c := p.peek(); {
+	case p.at(`"""`):
+		return p.parseMultilineString(`"""`, true)
+	case c == '"':
+		return p.parseBasicString()
+	case p.at("'''"):
+		return p.parseMultilineString("'''", false)
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case p.at("true"):
+		p.pos += 4
+		return true, nil
+	case p.at("false"):
+		p.pos += 5
+		return false, nil
+	}
+	return p.parseScalar()
+}
+
+// parseScalar parses a number, date or time
+func (p *tomlParser) parseScalar() (interface{}, error) {
+	start := p.pos
+	for !p.eof() && isScalarChar(p.peek()) {
+		p.pos++
+		// A date may be separated from its time by a space
+		if p.pos-start == 10 && p.pos+1 < len(p.data) && p.data[p.pos] == ' ' && isDigit(p.data[p.pos+1]) {
+			p.pos++
+		}
+	}
+	token := string(p.data[start:p.pos])
+	if token == "" {
+		return nil, p.errorf("expected a value, found %q", p.peek())
+	}
+
+	if tomlDateTime.MatchString(token) {
+		return token, nil
+	}
+	switch strings.TrimLeft(token, "+-") {
+	case "inf", "nan":
+		if strings.HasPrefix(token, "-") && token != "-nan" {
+			return "-inf", nil
+		}
+		return strings.TrimLeft(token, "+-"), nil
+	}
+
+	digits := strings.ReplaceAll(token, "_", "")
+	if strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") || strings.Contains(token, "__") {
+		return nil, p.errorf("invalid number %q", token)
+	}
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if rest, ok := strings.CutPrefix(digits, prefix); ok {
+			n, err := strconv.ParseInt(rest, base, 64)
+			if err != nil {
+				return nil, p.errorf("invalid number %q", token)
+			}
+			return n, nil
+		}
+	}
+	if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil && !strings.ContainsAny(digits, "xXpP") {
+		return f, nil
+	}
+	return nil, p.errorf("invalid value %q", token)
+}
+
+func isScalarChar(c byte) bool {
+	return isBareKeyChar(c) || c == '+' || c == '.' || c == ':'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseArray parses an array, which may span lines
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	list := []interface{}{}
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			break
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if p.peek() != ']' {
+			return nil, p.errorf("expected , or ] in array, found %q", p.peek())
+		}
+	}
+	p.sealed[sealKey(list)] = true
+	return list, nil
+}
+
+// parseInlineTable parses a table written as { key = value, ... }
+func (p *tomlParser) parseInlineTable() (*Object, error) {
+	p.pos++
+	table := NewObject()
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		if p.peek() == '}' {
+			p.pos++
+			break
+		}
+
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if p.peek() != '}' {
+			return nil, p.errorf("expected , or } in inline table, found %q", p.peek())
+		}
+	}
+	p.seal(table)
+	return table, nil
+}
+
+// seal marks an inline table and the tables within it as complete
+func (p *tomlParser) seal(table *Object) {
+	p.sealed[table] = true
+	for _, value := range table.Values {
+		if child, ok := value.(*Object); ok {
+			p.seal(child)
+		}
+	}
+}
+
+// parseBasicString parses a "string" with escapes
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseLiteralString parses a 'string' without escapes
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := bytes.IndexAny(p.data[p.pos:], "'\n")
+	if end < 0 || p.data[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := string(p.data[p.pos : p.pos+end])
+	p.pos += end + 1
+	return s, nil
+}
+
+// parseMultilineString parses a string between triple quotes, with escapes if
+// escapes is set. A newline straight after the opening quotes is dropped.
+func (p *tomlParser) parseMultilineString(quotes string, escapes bool) (string, error) {
+	p.pos += 3
+	if p.at("\r\n") {
+		p.pos += 2
+	} else if p.at("\n") {
+		p.pos++
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if p.at(quotes) {
+			// Up to two more quotes are part of the string
+			n := 0
+			for p.pos+n < len(p.data) && p.data[p.pos+n] == quotes[0] {
+				n++
+			}
+			if n > 5 {
+				return "", p.errorf("too many quotes at the end of a string")
+			}
+			b.WriteString(quotes[:n-3])
+			p.pos += n
+			return b.String(), nil
+		}
+
+		c := p.peek()
+		if escapes && c == '\\' {
+			// A backslash at the end of a line joins it to the next non-blank text
+			rest := p.pos + 1
+			for rest < len(p.data) && (p.data[rest] == ' ' || p.data[rest] == '\t') {
+				rest++
+			}
+			if rest < len(p.data) && (p.data[rest] == '\n' || p.data[rest] == '\r') {
+				p.pos = rest
+				for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+					p.pos++
+				}
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// parseEscape parses the escape sequence at p.pos into b
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.peek()
+	p.pos++
+
+	simple := map[byte]byte{'b': '\b', 't': '\t', 'n': '\n', 'f': '\f', 'r': '\r', '"': '"', '\\': '\\', 'e': 0x1b}
+	if r, ok := simple[c]; ok {
+		b.WriteByte(r)
+		return nil
+	}
+
+	digits := map[byte]int{'u': 4, 'U': 8}[c]
+	if digits == 0 {
+		return p.errorf("invalid escape \\%c", c)
+	}
+	if p.pos+digits > len(p.data) {
+		return p.errorf("unterminated string")
+	}
+	code, err := strconv.ParseUint(string(p.data[p.pos:p.pos+digits]), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return p.errorf("invalid escape \\%c%s", c, p.data[p.pos:p.pos+digits])
+	}
+	b.WriteRune(rune(code))
+	p.pos += digits
+	return nil
+}
+
+// skipBlank skips spaces, tabs and comments, and newlines too if newlines is set
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		case newlines && (c == '\n' || c == '\r'):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *tomlParser) peek() byte {
+	return p.data[p.pos]
+}
+
+// at reports whether the input continues with s
+func (p *tomlParser) at(s string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+// errorf returns an error at the current line
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line := bytes.Count(p.data[:min(p.pos, len(p.data))], []byte{'\n'}) + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,184 @@
+// Package diff produces unified diffs of text, as diff -u does.
+//
+// Lines are matched with Myers' algorithm. Texts that differ in more than
+// MaxEdits lines are not matched line by line: the diff removes every old line and
+// adds every new one, which is still correct, just less helpful.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxEdits is the most lines that may be added or removed before the diff gives up
+// on finding the lines the texts have in common
+const MaxEdits = 1000
+
+// op is one line of an edit script: kept (' '), removed ('-') or added ('+')
+type op struct {
+	kind byte
+	text string
+}
+
+// Unified returns a unified diff that turns oldText into newText, with context
+// lines around each change, or "" if the texts are the same. oldName and newName
+// label the two sides in the header.
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+	ops := edits(splitLines(oldText), splitLines(newText))
+
+	// oldPos and newPos count the old and new lines before each op
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, o := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if o.kind != '+' {
+			oldPos[i+1]++
+		}
+		if o.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// A hunk runs from context lines before its first change to context lines
+		// after its last, taking in changes that are close enough to share context
+		start, end := max(i-context, 0), i
+		for {
+			next := end + 1
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end-1 > 2*context {
+				break
+			}
+			end = next
+		}
+		stop := min(end+context+1, len(ops))
+
+		oldCount, newCount := oldPos[stop]-oldPos[start], newPos[stop]-newPos[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldPos[start], oldCount), hunkRange(newPos[start], newCount))
+		for _, o := range ops[start:stop] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.text)
+			out.WriteByte('\n')
+		}
+		i = stop
+	}
+	return out.String()
+}
+
+// hunkRange formats the start and length of one side of a hunk. An empty side is
+// given as the line before it, as diff -u does.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// splitLines splits text into lines, without a final empty line for a trailing
+// newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// edits returns the shortest edit script turning a into b
+func edits(a, b []string) []op {
+	n, m := len(a), len(b)
+	limit := min(n+m, MaxEdits)
+
+	// v[offset+k] is the furthest x reached on diagonal k. trace[d] holds the part
+	// of v that round d started from, diagonals -d-1 to d+1, for backtracking.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+
+	// Too different to be worth matching up
+	ops := make([]op, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, op{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, op{'+', line})
+	}
+	return ops
+}
+
+// backtrack follows the trace of edits back from the end of both texts
+func backtrack(trace [][]int, a, b []string) []op {
+	var ops []op
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// Diagonal k of round d's snapshot is at index k+d+1
+		v := func(k int) int { return trace[d][k+d+1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, op{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{'+', b[y-1]})
+				y--
+			} else {
+				ops = append(ops, op{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{
+			"change",
+			"a\nb\nc\n", "a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"insert at end",
+			"a\n", "a\nb\n",
+			"--- old\n+++ new\n@@ -1 +1,2 @@\n a\n+b\n",
+		},
+		{
+			"from empty",
+			"", "a\n",
+			"--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n", "1\nx\n3\n4\n5\n6\n7\ny\n9\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n 1\n-2\n+x\n 3\n@@ -7,3 +7,3 @@\n 7\n-8\n+y\n 9\n",
+		},
+		{
+			"nearby changes share a hunk",
+			"1\n2\n3\n4\n5\n", "x\n2\n3\ny\n5\n",
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n-4\n+y\n 5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.old, tt.new, 1); got != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUnified_TooDifferent(t *testing.T) {
+	var old, new strings.Builder
+	for i := 0; i < MaxEdits; i++ {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+
+	got := Unified("old", "new", old.String(), new.String(), 3)
+	if removed := strings.Count(got, "\n-old "); removed != MaxEdits {
+		t.Errorf("Expected every old line to be removed, got %d", removed)
+	}
+	if added := strings.Count(got, "\n+new "); added != MaxEdits {
+		t.Errorf("Expected every new line to be added, got %d", added)
+	}
+}
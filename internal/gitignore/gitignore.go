@@ -0,0 +1,175 @@
+// Package gitignore matches paths against the patterns in .gitignore files.
+//
+// It follows the rules in gitignore(5): blank lines and lines starting with # are
+// skipped, a leading ! re-includes what an earlier pattern excluded, a trailing /
+// matches only directories, and a pattern containing a / is anchored to the
+// directory of its .gitignore, while one without matches a name at any depth below
+// it. ** matches any number of directories. The last pattern that matches a path
+// decides whether it is ignored.
+package gitignore
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pattern is one line of a .gitignore file
+type pattern struct {
+	// base is the slash-separated absolute directory of the .gitignore
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// Matcher holds the patterns of the .gitignore files loaded into it
+type Matcher struct {
+	patterns []pattern
+}
+
+// New returns a Matcher with the .gitignore files of dir's repository loaded, from
+// the root of the repository down to dir. Outside a repository, only dir's own
+// .gitignore is loaded.
+func New(dir string) *Matcher {
+	m := &Matcher{}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return m
+	}
+
+	// Find the top of the repository, the nearest directory holding .git
+	dirs := []string{abs}
+	for current := abs; ; {
+		if _, err := os.Lstat(filepath.Join(current, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			dirs = dirs[:1]
+			break
+		}
+		current = parent
+		dirs = append(dirs, current)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m.Load(dirs[i])
+	}
+	return m
+}
+
+// Load adds the patterns in dir's .gitignore, if it has one
+func (m *Matcher) Load(dir string) {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	m.Add(abs, data)
+}
+
+// Add adds the patterns in data, the contents of a .gitignore file in dir
+func (m *Matcher) Add(dir string, data []byte) {
+	base := filepath.ToSlash(dir)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// Trailing spaces are dropped unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := pattern{base: base}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		p.segments = strings.Split(line, "/")
+		m.patterns = append(m.patterns, p)
+	}
+}
+
+// Ignored reports whether the patterns exclude path, which is a directory if isDir
+// is set. It does not check whether a directory above path is ignored: walks are
+// expected to skip ignored directories rather than look inside them.
+func (m *Matcher) Ignored(file string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	abs = filepath.ToSlash(abs)
+
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := strings.CutPrefix(abs, strings.TrimSuffix(p.base, "/")+"/")
+		if !ok || rel == "" {
+			continue
+		}
+
+		names := strings.Split(rel, "/")
+		if !p.anchored {
+			names = names[len(names)-1:]
+		}
+		if match(p.segments, names) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// match matches names against the segments of a pattern, where a ** segment
+// matches any number of names
+func match(segments, names []string) bool {
+	for len(segments) > 0 {
+		if segments[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if match(segments[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(segments[0], names[0]); !ok {
+			return false
+		}
+		segments, names = segments[1:], names[1:]
+	}
+	return len(names) == 0
+}
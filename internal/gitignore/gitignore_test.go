@@ -0,0 +1,80 @@
+package gitignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_Ignored(t *testing.T) {
+	m := &Matcher{}
+	m.Add("/repo", []byte(`
+# build output
+*.log
+/bin/
+node_modules
+docs/*.html
+!docs/index.html
+**/generated/**
+trailing
+`))
+	m.Add("/repo/sub", []byte("local.txt\n/only-here\n"))
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"/repo/app.log", false, true},
+		{"/repo/deep/down/app.log", false, true},
+		{"/repo/app.go", false, false},
+		{"/repo/bin", true, true},
+		{"/repo/bin", false, false},
+		{"/repo/sub/bin", true, false},
+		{"/repo/node_modules", true, true},
+		{"/repo/sub/node_modules", true, true},
+		{"/repo/docs/guide.html", false, true},
+		{"/repo/docs/index.html", false, false},
+		{"/repo/docs/api/guide.html", false, false},
+		{"/repo/a/generated/b/c.go", false, true},
+		{"/repo/trailing", false, true},
+		{"/repo/sub/local.txt", false, true},
+		{"/repo/local.txt", false, false},
+		{"/repo/sub/only-here", false, true},
+		{"/repo/sub/x/only-here", false, false},
+		{"/elsewhere/app.log", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if ignored := m.Ignored(tt.path, tt.isDir); ignored != tt.ignored {
+				t.Errorf("Expected ignored=%v, got %v", tt.ignored, ignored)
+			}
+		})
+	}
+}
+
+func TestNew_LoadsRepositoryParents(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, ".gitignore"), []byte("!keep.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(sub)
+	if !m.Ignored(filepath.Join(sub, "x.tmp"), false) {
+		t.Error("Expected the repository's .gitignore to apply below it")
+	}
+	if m.Ignored(filepath.Join(sub, "keep.tmp"), false) {
+		t.Error("Expected the nearer .gitignore to re-include keep.tmp")
+	}
+}
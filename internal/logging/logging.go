@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"mcp-server/internal/config"
+)
+
+// Setup builds a structured logger from the configuration and installs it as the
+// default for both log/slog and the standard log package. The returned closer
+// flushes and closes the log file and must be called on shutdown.
+//
+// If the log file cannot be opened the logger falls back to standard error and
+// the error is returned alongside it, so logging is never silently dropped.
+func Setup(cfg *config.LogConfig) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var writers []io.Writer
+	var closer io.Closer = nopCloser{}
+	var fileErr error
+
+	if cfg.Path != "" {
+		file, err := NewRotatingFile(cfg.Path, int64(cfg.MaxSizeMB)*1024*1024, cfg.MaxBackups)
+		if err != nil {
+			fileErr = fmt.Errorf("failed to open log file %s: %w", cfg.Path, err)
+		} else {
+			writers = append(writers, file)
+			closer = file
+		}
+	}
+
+	// Standard error is safe to use with the stdio transport, which only owns stdout
+	if cfg.Stderr || len(writers) == 0 {
+		writers = append(writers, os.Stderr)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	output := allWriters(writers)
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(output, options)
+	case "json":
+		handler = slog.NewJSONHandler(output, options)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (expected \"text\" or \"json\")", cfg.Format)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	return logger, closer, fileErr
+}
+
+// ParseLevel converts a level name into a slog level
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// allWriters writes to every writer even when one of them fails, unlike
+// io.MultiWriter, so that a log file that cannot be written does not also
+// silence stderr. It returns the first error.
+type allWriters []io.Writer
+
+func (w allWriters) Write(p []byte) (int, error) {
+	var first error
+	for _, writer := range w {
+		if _, err := writer.Write(p); err != nil && first == nil {
+			first = err
+		}
+	}
+	return len(p), first
+}
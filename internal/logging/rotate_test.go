@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile_RotatesAtMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+
+	file, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first1\n", "second\n", "third3\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third3\n",
+		path + ".2": "second\n",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("Expected %s to contain %q, got %q", name, want, got)
+		}
+	}
+
+	// Only MaxBackups rotated files are kept
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected oldest backup to be removed")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat log file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected log file mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestRotatingFile_ReopensAfterFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "server.log")
+
+	file, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write([]byte("first1\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Rotation cannot rename or reopen the file while its directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := file.Write([]byte("second\n")); err == nil || errors.Is(err, os.ErrClosed) {
+			t.Fatalf("Expected write %d to report why the file cannot be opened, got %v", i, err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("third3\n")); err != nil {
+		t.Fatalf("Expected the file to be reopened, got %v", err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "third3\n" {
+		t.Errorf("Expected %s to contain the line written after reopening, got %q, %v", path, got, err)
+	}
+
+	file.Close()
+	if _, err := file.Write([]byte("late\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected writes after Close to fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestAllWriters_KeepsWritingAfterAnError(t *testing.T) {
+	var first, second strings.Builder
+	w := allWriters{&first, failingWriter{}, &second}
+
+	if n, err := w.Write([]byte("line\n")); n != 5 || err == nil {
+		t.Errorf("Expected 5 bytes and the failing writer's error, got %d, %v", n, err)
+	}
+	if first.String() != "line\n" || second.String() != "line\n" {
+		t.Errorf("Expected both working writers to get the line, got %q and %q", first.String(), second.String())
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestParseLevel(t *testing.T) {
+	if _, err := ParseLevel("warn"); err != nil {
+		t.Errorf("Expected warn to be a valid level: %v", err)
+	}
+	if _, err := ParseLevel("loud"); err == nil || !strings.Contains(err.Error(), "loud") {
+		t.Errorf("Expected an error naming the invalid level, got %v", err)
+	}
+}
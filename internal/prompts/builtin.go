@@ -0,0 +1,91 @@
+package prompts
+
+// Builtin returns the prompts the server offers out of the box
+func Builtin() []*Prompt {
+	prompts := []*Prompt{
+		{
+			Name:        "review-file",
+			Title:       "Review File",
+			Description: "Review a file for bugs, unclear code and risky patterns",
+			Arguments: []Argument{
+				{Name: "path", Description: "Path to the file to review", Required: true, Complete: CompletePath},
+				{Name: "focus", Description: "What to pay most attention to, e.g. error handling or performance"},
+			},
+			Tools: []string{"show_file"},
+			Template: `Please review the file {{ .path }}{{ if .focus }}, paying particular attention to {{ .focus }}{{ end }}.
+Point out bugs, unclear code and risky patterns, and suggest concrete improvements.
+
+{{ with tool "show_file" "file_path" .path -}}
+~~~
+{{ .content }}
+~~~
+{{- end }}`,
+		},
+		{
+			Name:        "explain-error",
+			Title:       "Explain Error",
+			Description: "Run a failing command and explain its error",
+			Arguments: []Argument{
+				{Name: "command", Description: "The command to run, split on whitespace", Required: true, Complete: CompleteCommand},
+				{Name: "working_dir", Description: "Directory to run the command in", Complete: CompletePath},
+			},
+			Tools: []string{"execute_shell_command"},
+			Template: `I ran ` + "`{{ .command }}`" + `{{ if .working_dir }} in {{ .working_dir }}{{ end }}. Explain what went wrong and how to fix it.
+
+{{ with tool "execute_shell_command" "command" (fields .command) "working_dir" .working_dir -}}
+Exit code: {{ .exit_code }}
+
+Standard error:
+~~~
+{{ .stderr }}
+~~~
+
+Standard output:
+~~~
+{{ .stdout }}
+~~~
+{{- end }}`,
+		},
+		{
+			Name:        "write-tests-for",
+			Title:       "Write Tests For",
+			Description: "Write unit tests for a file",
+			Arguments: []Argument{
+				{Name: "path", Description: "Path to the file to test", Required: true, Complete: CompletePath},
+				{Name: "framework", Description: "Test framework or style to follow"},
+			},
+			Tools: []string{"show_file"},
+			Template: `Write unit tests for {{ .path }}{{ if .framework }} using {{ .framework }}{{ end }}.
+Cover the main behaviour, edge cases and error paths, and follow the conventions of the code.
+
+{{ with tool "show_file" "file_path" .path -}}
+~~~
+{{ .content }}
+~~~
+{{- end }}`,
+		},
+		{
+			Name:        "summarize-diff",
+			Title:       "Summarize Diff",
+			Description: "Summarize the changes in a diff or patch file",
+			Arguments: []Argument{
+				{Name: "path", Description: "Path to a diff or patch file, e.g. saved from git diff", Required: true, Complete: CompletePath},
+			},
+			Tools: []string{"show_file"},
+			Template: `Summarize the changes in this diff: what changed, why it likely changed, and anything a reviewer should look at closely.
+
+{{ with tool "show_file" "file_path" .path -}}
+~~~diff
+{{ .content }}
+~~~
+{{- end }}`,
+		},
+	}
+
+	for _, prompt := range prompts {
+		if err := prompt.compile(); err != nil {
+			panic(err)
+		}
+	}
+	return prompts
+}
@@ -0,0 +1,231 @@
+// Package prompts defines MCP prompt templates. A prompt is a text/template that
+// is rendered with the client's arguments and can pull in the output of the
+// server's tools, so a prompt such as review-file arrives with the file already
+// attached:
+//
+//	{{ with tool "show_file" "file_path" .path }}{{ .content }}{{ end }}
+//
+// tool calls go through the server like any other tool call, so the path policy,
+// budgets and the audit log apply to them.
+package prompts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"mcp-server/internal/utils"
+)
+
+// ToolFunc runs a tool with the given arguments and returns its decoded JSON result
+type ToolFunc func(name string, args map[string]interface{}) (map[string]interface{}, error)
+
+// Completion kinds for Argument.Complete
+const (
+	// CompletePath completes files and directories under the allowed paths
+	CompletePath = "path"
+
+	// CompleteCommand completes the commands execute_shell_command allows
+	CompleteCommand = "command"
+)
+
+// Argument describes one argument of a prompt
+type Argument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+
+	// Complete says how clients are offered values as the user types: CompletePath
+	// or CompleteCommand
+	Complete string `json:"complete,omitempty"`
+
+	// Values, if set, lists the only values the argument accepts; they are also
+	// offered as completions
+	Values []string `json:"values,omitempty"`
+}
+
+// Prompt is a prompt template
+type Prompt struct {
+	Name        string     `json:"name"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Arguments   []Argument `json:"arguments,omitempty"`
+
+	// Tools lists the tools the template calls. The prompt is only offered when all
+	// of them are available.
+	Tools []string `json:"tools,omitempty"`
+
+	// Template is the text/template source of the prompt's message
+	Template string `json:"template"`
+
+	tmpl *template.Template
+}
+
+// File is the format of a prompts configuration file
+type File struct {
+	Prompts []*Prompt `json:"prompts"`
+}
+
+// Load reads prompts from a JSON configuration file
+func Load(path string) ([]*Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid prompts file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, prompt := range file.Prompts {
+		if err := prompt.compile(); err != nil {
+			return nil, fmt.Errorf("prompts file %s: %w", path, err)
+		}
+		if seen[prompt.Name] {
+			return nil, fmt.Errorf("prompts file %s: prompt %q is defined twice", path, prompt.Name)
+		}
+		seen[prompt.Name] = true
+	}
+
+	return file.Prompts, nil
+}
+
+// Merge returns base with the prompts in overrides added, replacing any prompt of
+// the same name, sorted by name
+func Merge(base []*Prompt, overrides ...*Prompt) []*Prompt {
+	byName := make(map[string]*Prompt, len(base)+len(overrides))
+	for _, prompt := range base {
+		byName[prompt.Name] = prompt
+	}
+	for _, prompt := range overrides {
+		byName[prompt.Name] = prompt
+	}
+
+	merged := make([]*Prompt, 0, len(byName))
+	for _, prompt := range byName {
+		merged = append(merged, prompt)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
+
+// Argument returns the prompt's argument called name, or nil
+func (p *Prompt) Argument(name string) *Argument {
+	for i := range p.Arguments {
+		if p.Arguments[i].Name == name {
+			return &p.Arguments[i]
+		}
+	}
+	return nil
+}
+
+// compile checks the prompt and parses its template
+func (p *Prompt) compile() error {
+	if p.Name == "" {
+		return errors.New("prompt has no name")
+	}
+	if p.Template == "" {
+		return fmt.Errorf("prompt %q has no template", p.Name)
+	}
+	for _, arg := range p.Arguments {
+		switch arg.Complete {
+		case "", CompletePath, CompleteCommand:
+		default:
+			return fmt.Errorf("prompt %q: argument %s has unknown completion %q", p.Name, arg.Name, arg.Complete)
+		}
+	}
+
+	tmpl, err := template.New(p.Name).Funcs(templateFuncs(nil)).Parse(p.Template)
+	if err != nil {
+		return fmt.Errorf("prompt %q: %w", p.Name, err)
+	}
+	p.tmpl = tmpl
+	return nil
+}
+
+// Render fills in the prompt with args, calling tools through call. Missing required
+// arguments and unknown arguments are INVALID_ARGS errors; a failing tool call
+// returns that tool's error.
+func (p *Prompt) Render(args map[string]string, call ToolFunc) (string, error) {
+	if p.tmpl == nil {
+		if err := p.compile(); err != nil {
+			return "", utils.NewToolError(utils.ErrInternal, "%v", err)
+		}
+	}
+
+	data := make(map[string]string, len(p.Arguments))
+	known := make(map[string]bool, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		known[arg.Name] = true
+		value := args[arg.Name]
+		if arg.Required && strings.TrimSpace(value) == "" {
+			return "", utils.NewToolError(utils.ErrInvalidArgs, "Prompt %s requires the %s argument", p.Name, arg.Name).
+				WithDetails(map[string]interface{}{"field": arg.Name})
+		}
+		if value != "" && len(arg.Values) > 0 && !slices.Contains(arg.Values, value) {
+			return "", utils.NewToolError(utils.ErrInvalidArgs, "Invalid value for %s: must be one of %s, got %q", arg.Name, strings.Join(arg.Values, ", "), value).
+				WithDetails(map[string]interface{}{"field": arg.Name})
+		}
+		data[arg.Name] = value
+	}
+	for name := range args {
+		if !known[name] {
+			return "", utils.NewToolError(utils.ErrInvalidArgs, "Prompt %s has no argument %s", p.Name, name).
+				WithDetails(map[string]interface{}{"field": name})
+		}
+	}
+
+	tmpl, err := p.tmpl.Clone()
+	if err != nil {
+		return "", utils.NewToolError(utils.ErrInternal, "%v", err)
+	}
+	tmpl.Funcs(templateFuncs(call))
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		var toolErr *utils.ToolError
+		if errors.As(err, &toolErr) {
+			return "", toolErr
+		}
+		return "", utils.NewToolError(utils.ErrInternal, "Failed to render prompt %s: %v", p.Name, err)
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// templateFuncs returns the functions available to prompt templates
+func templateFuncs(call ToolFunc) template.FuncMap {
+	return template.FuncMap{
+		// tool runs a tool with alternating argument names and values; empty string
+		// values are left out so optional prompt arguments fall back to tool defaults
+		"tool": func(name string, pairs ...interface{}) (map[string]interface{}, error) {
+			if call == nil {
+				return nil, utils.NewToolError(utils.ErrUnavailable, "Tools are not available to this prompt")
+			}
+			if len(pairs)%2 != 0 {
+				return nil, utils.NewToolError(utils.ErrInternal, "tool %s called with an odd number of arguments", name)
+			}
+
+			args := make(map[string]interface{}, len(pairs)/2)
+			for i := 0; i < len(pairs); i += 2 {
+				key, ok := pairs[i].(string)
+				if !ok {
+					return nil, utils.NewToolError(utils.ErrInternal, "tool %s called with a non-string argument name", name)
+				}
+				if s, ok := pairs[i+1].(string); ok && s == "" {
+					continue
+				}
+				args[key] = pairs[i+1]
+			}
+			return call(name, args)
+		},
+		"fields": strings.Fields,
+	}
+}
@@ -0,0 +1,176 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-server/internal/utils"
+)
+
+// fakeTools answers show_file and execute_shell_command and records the calls
+type fakeTools struct {
+	calls []map[string]interface{}
+}
+
+func (f *fakeTools) call(name string, args map[string]interface{}) (map[string]interface{}, error) {
+	f.calls = append(f.calls, args)
+	switch name {
+	case "show_file":
+		if args["file_path"] == "/denied" {
+			return nil, utils.NewToolError(utils.ErrPathDenied, "Access to this file path is not allowed by server configuration")
+		}
+		return map[string]interface{}{"content": "func main() {}"}, nil
+	case "execute_shell_command":
+		return map[string]interface{}{"stdout": "", "stderr": "no such file", "exit_code": float64(2)}, nil
+	}
+	return nil, utils.NewToolError(utils.ErrUnavailable, "Tool %s is not available", name)
+}
+
+func builtin(t *testing.T, name string) *Prompt {
+	t.Helper()
+	for _, prompt := range Builtin() {
+		if prompt.Name == name {
+			return prompt
+		}
+	}
+	t.Fatalf("No built-in prompt %s", name)
+	return nil
+}
+
+func TestRender_ReviewFile(t *testing.T) {
+	tools := &fakeTools{}
+	text, err := builtin(t, "review-file").Render(map[string]string{"path": "/src/main.go", "focus": "error handling"}, tools.call)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	for _, want := range []string{"/src/main.go", "paying particular attention to error handling", "~~~\nfunc main() {}\n~~~"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the prompt to contain %q, got:\n%s", want, text)
+		}
+	}
+	if len(tools.calls) != 1 || tools.calls[0]["file_path"] != "/src/main.go" {
+		t.Errorf("Expected show_file to be called with the path, got %v", tools.calls)
+	}
+}
+
+func TestRender_ExplainErrorSplitsCommand(t *testing.T) {
+	tools := &fakeTools{}
+	text, err := builtin(t, "explain-error").Render(map[string]string{"command": "ls  missing"}, tools.call)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	if !strings.Contains(text, "Exit code: 2") || !strings.Contains(text, "no such file") {
+		t.Errorf("Expected the exit code and stderr, got:\n%s", text)
+	}
+
+	// The empty working_dir is left out so the tool uses its default
+	expected := map[string]interface{}{"command": []string{"ls", "missing"}}
+	if len(tools.calls) != 1 || !reflect.DeepEqual(tools.calls[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, tools.calls)
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	tools := &fakeTools{}
+	prompt := builtin(t, "review-file")
+
+	tests := []struct {
+		name string
+		args map[string]string
+		code utils.ErrorCode
+	}{
+		{"missing required argument", map[string]string{}, utils.ErrInvalidArgs},
+		{"unknown argument", map[string]string{"path": "/a", "colour": "blue"}, utils.ErrInvalidArgs},
+		{"tool error", map[string]string{"path": "/denied"}, utils.ErrPathDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := prompt.Render(tt.args, tools.call)
+			toolErr, ok := err.(*utils.ToolError)
+			if !ok || toolErr.Code != tt.code {
+				t.Errorf("Expected a %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestRender_Values(t *testing.T) {
+	prompt := &Prompt{
+		Name:      "deploy",
+		Arguments: []Argument{{Name: "env", Values: []string{"staging", "production"}}},
+		Template:  "Deploy to {{ .env }}",
+	}
+
+	if text, err := prompt.Render(map[string]string{"env": "staging"}, nil); err != nil || text != "Deploy to staging" {
+		t.Errorf("Expected a listed value to render, got %q, %v", text, err)
+	}
+
+	_, err := prompt.Render(map[string]string{"env": "dev"}, nil)
+	if toolErr, ok := err.(*utils.ToolError); !ok || toolErr.Code != utils.ErrInvalidArgs {
+		t.Errorf("Expected an INVALID_ARGS error for an unlisted value, got %v", err)
+	}
+}
+
+func TestLoadAndMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompts.json")
+	content := `{"prompts":[
+		{"name":"review-file","description":"Team review","arguments":[{"name":"path","required":true}],"template":"Review {{ .path }} per our style guide"},
+		{"name":"greet","template":"Hello"}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	custom, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	merged := Merge(Builtin(), custom...)
+	var names []string
+	for _, prompt := range merged {
+		names = append(names, prompt.Name)
+	}
+	expected := []string{"explain-error", "greet", "review-file", "summarize-diff", "write-tests-for"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	for _, prompt := range merged {
+		if prompt.Name != "review-file" {
+			continue
+		}
+		text, err := prompt.Render(map[string]string{"path": "a.go"}, nil)
+		if err != nil || text != "Review a.go per our style guide" {
+			t.Errorf("Expected the configured review-file to replace the built-in, got %q, %v", text, err)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"bad template": `{"prompts":[{"name":"x","template":"{{ .path "}]}`,
+		"no name":      `{"prompts":[{"template":"x"}]}`,
+		"duplicate":    `{"prompts":[{"name":"x","template":"a"},{"name":"x","template":"b"}]}`,
+		"not json":     `prompts`,
+		"bad complete": `{"prompts":[{"name":"x","arguments":[{"name":"a","complete":"colour"}],"template":"x"}]}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prompts.json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Errorf("Expected an error loading %s", content)
+			}
+		})
+	}
+}
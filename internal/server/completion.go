@@ -0,0 +1,260 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/prompts"
+	"mcp-server/internal/tools"
+	"mcp-server/internal/validate"
+)
+
+// maxCompletions is the most values one completion/complete result holds, as the
+// MCP specification requires
+const maxCompletions = 100
+
+// pathArguments are the names of tool arguments that take a file or directory
+var pathArguments = map[string]bool{
+	"file_path":   true,
+	"working_dir": true,
+	"path":        true,
+}
+
+// enableCompletions suggests argument values as the user types them. Besides the
+// prompt and resource template references in the MCP specification, it accepts
+// {"type": "ref/tool", "name": ...} to complete tool arguments.
+func (s *Server) enableCompletions(sess *session) {
+	sess.ext.addCapability("completions", map[string]interface{}{})
+
+	sess.ext.handle("completion/complete", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Ref struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+				URI  string `json:"uri"`
+			} `json:"ref"`
+			Argument struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"argument"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid completion/complete params: %v", err)
+		}
+
+		cfg := s.configFor(sess.id)
+		name, value := request.Argument.Name, request.Argument.Value
+
+		var values []string
+		switch request.Ref.Type {
+		case "ref/prompt":
+			prompt := s.findPrompt(request.Ref.Name)
+			if prompt == nil {
+				return nil, invalidParams("unknown prompt %q", request.Ref.Name)
+			}
+			values = completePromptArgument(cfg, prompt, name, value)
+		case "ref/resource":
+			// The file template's {path} is the absolute path without its leading slash
+			if request.Ref.URI == "file:///{path}" && name == "path" {
+				for _, path := range completePath(cfg, "/"+value) {
+					values = append(values, strings.TrimPrefix(path, "/"))
+				}
+			}
+		case "ref/tool":
+			tool := s.findTool(request.Ref.Name)
+			if tool == nil {
+				return nil, invalidParams("unknown tool %q", request.Ref.Name)
+			}
+			values = completeToolArgument(cfg, tool, name, value)
+		default:
+			return nil, invalidParams("unsupported completion reference type %q", request.Ref.Type)
+		}
+
+		total := len(values)
+		if total > maxCompletions {
+			values = values[:maxCompletions]
+		}
+		if values == nil {
+			values = []string{}
+		}
+
+		return map[string]interface{}{
+			"completion": map[string]interface{}{
+				"values":  values,
+				"total":   total,
+				"hasMore": total > maxCompletions,
+			},
+		}, nil
+	})
+}
+
+// findTool returns the registered tool called name, or nil
+func (s *Server) findTool(name string) tools.Tool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tool := range s.tools {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// findPrompt returns the available prompt called name, or nil
+func (s *Server) findPrompt(name string) *prompts.Prompt {
+	for _, prompt := range s.availablePrompts() {
+		if prompt.Name == name {
+			return prompt
+		}
+	}
+	return nil
+}
+
+// completePromptArgument suggests values for a prompt argument from its declared
+// values or its completion kind
+func completePromptArgument(cfg *config.ServerConfig, prompt *prompts.Prompt, name, value string) []string {
+	arg := prompt.Argument(name)
+	if arg == nil {
+		return nil
+	}
+	if len(arg.Values) > 0 {
+		return withPrefix(arg.Values, value)
+	}
+
+	switch arg.Complete {
+	case prompts.CompletePath:
+		return completePath(cfg, value)
+	case prompts.CompleteCommand:
+		return completeCommand(cfg, value)
+	}
+	return nil
+}
+
+// completeToolArgument suggests values for a tool argument: paths for arguments that
+// name files, allowed commands for the first word of a command, and the values of
+// an enum
+func completeToolArgument(cfg *config.ServerConfig, tool tools.Tool, name, value string) []string {
+	switch {
+	case name == "command" || name == "command[0]":
+		return completeCommand(cfg, value)
+	case pathArguments[name]:
+		return completePath(cfg, value)
+	}
+
+	execute := reflect.ValueOf(tool).MethodByName("Execute")
+	if !execute.IsValid() {
+		return nil
+	}
+	return withPrefix(validate.EnumValues(execute.Type().In(0), name), value)
+}
+
+// completeCommand suggests allowed command names, or executables by path once the
+// value contains a slash
+func completeCommand(cfg *config.ServerConfig, value string) []string {
+	if strings.Contains(value, "/") {
+		return completePath(cfg, value)
+	}
+	return withPrefix(tools.AllowedCommands(), value)
+}
+
+// completePath suggests the files and directories that extend value, leaving out
+// whatever the path policy denies. Directories get a trailing slash. Directories
+// above an allowed path are offered too, so the user can work their way down to it;
+// an empty value offers the allowed paths themselves.
+func completePath(cfg *config.ServerConfig, value string) []string {
+	if cfg == nil {
+		return nil
+	}
+
+	var roots []string
+	for _, root := range cfg.AllowedPaths {
+		if absRoot, err := filepath.Abs(root); err == nil {
+			roots = append(roots, absRoot)
+		}
+	}
+
+	if value == "" {
+		var values []string
+		for _, root := range roots {
+			if decision, err := cfg.CheckPath(root); err == nil && decision.Allowed {
+				values = append(values, root+string(filepath.Separator))
+			}
+		}
+		return values
+	}
+
+	dirPart, prefix := "", value
+	if i := strings.LastIndex(value, string(filepath.Separator)); i >= 0 {
+		dirPart, prefix = value[:i+1], value[i+1:]
+	}
+	dir := dirPart
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+
+		candidate := dirPart + name
+		absCandidate, err := filepath.Abs(candidate)
+		if err != nil {
+			continue
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(candidate); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+
+		decision, err := cfg.CheckPath(absCandidate)
+		allowed := err == nil && decision.Allowed
+		if !allowed && !(isDir && aboveAny(absCandidate, roots)) {
+			continue
+		}
+
+		if isDir {
+			candidate += string(filepath.Separator)
+		}
+		values = append(values, candidate)
+	}
+
+	return values
+}
+
+// aboveAny reports whether dir is a proper ancestor of one of paths
+func aboveAny(dir string, paths []string) bool {
+	dir = strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+	for _, path := range paths {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// withPrefix returns the values that start with prefix
+func withPrefix(values []string, prefix string) []string {
+	var matched []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matched = append(matched, value)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-server/internal/tools"
+)
+
+func TestServer_Completion(t *testing.T) {
+	root, conn, reader := startResourceServer(t, func(srv *Server) {
+		for _, tool := range []tools.Tool{tools.NewWriteFileTool(), tools.NewExecuteShellTool()} {
+			if err := srv.RegisterTool(tool); err != nil {
+				t.Fatalf("Failed to register tool: %v", err)
+			}
+		}
+	})
+
+	response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	if !strings.Contains(response, `"completions":{}`) {
+		t.Errorf("Expected the completions capability to be declared, got: %s", response)
+	}
+
+	tests := []struct {
+		name     string
+		ref      string
+		argument string
+		value    string
+		expected []string
+	}{
+		{
+			name:     "prompt path offers the allowed paths",
+			ref:      `{"type":"ref/prompt","name":"review-file"}`,
+			argument: "path",
+			expected: []string{root + "/"},
+		},
+		{
+			name:     "prompt path leaves out denied directories",
+			ref:      `{"type":"ref/prompt","name":"review-file"}`,
+			argument: "path",
+			value:    root + "/s",
+			expected: []string{root + "/sub/"},
+		},
+		{
+			name:     "directories above an allowed path are offered",
+			ref:      `{"type":"ref/prompt","name":"review-file"}`,
+			argument: "path",
+			value:    filepath.Dir(root) + "/" + filepath.Base(root),
+			expected: []string{root + "/"},
+		},
+		{
+			name:     "prompt command",
+			ref:      `{"type":"ref/prompt","name":"explain-error"}`,
+			argument: "command",
+			value:    "gu",
+			expected: []string{"gunzip"},
+		},
+		{
+			name:     "tool command",
+			ref:      `{"type":"ref/tool","name":"execute_shell_command"}`,
+			argument: "command[0]",
+			value:    "whe",
+			expected: []string{"whereis"},
+		},
+		{
+			name:     "tool path",
+			ref:      `{"type":"ref/tool","name":"write_file"}`,
+			argument: "file_path",
+			value:    root + "/",
+			expected: []string{root + "/a.txt", root + "/sub/"},
+		},
+		{
+			name:     "tool enum",
+			ref:      `{"type":"ref/tool","name":"write_file"}`,
+			argument: "mode",
+			expected: []string{"w", "a"},
+		},
+		{
+			name:     "resource template",
+			ref:      `{"type":"ref/resource","uri":"file:///{path}"}`,
+			argument: "path",
+			value:    strings.TrimPrefix(root, "/") + "/a",
+			expected: []string{strings.TrimPrefix(root, "/") + "/a.txt"},
+		},
+		{
+			name:     "argument without completions",
+			ref:      `{"type":"ref/prompt","name":"review-file"}`,
+			argument: "focus",
+			value:    "err",
+			expected: []string{},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := roundTrip(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"completion/complete","params":{"ref":%s,"argument":{"name":%q,"value":%q}}}`, 10+i, tt.ref, tt.argument, tt.value))
+			var parsed struct {
+				Result struct {
+					Completion struct {
+						Values  []string `json:"values"`
+						Total   int      `json:"total"`
+						HasMore bool     `json:"hasMore"`
+					} `json:"completion"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+				t.Fatalf("Failed to parse response %q: %v", response, err)
+			}
+
+			completion := parsed.Result.Completion
+			if !reflect.DeepEqual(completion.Values, tt.expected) {
+				t.Errorf("Expected completions %v, got: %s", tt.expected, response)
+			}
+			if completion.Total != len(tt.expected) || completion.HasMore {
+				t.Errorf("Expected a total of %d with nothing more, got: %s", len(tt.expected), response)
+			}
+		})
+	}
+
+	read := resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":30,"method":"completion/complete","params":{"ref":{"type":"ref/tool","name":"run_task"},"argument":{"name":"task","value":""}}}`)
+	if read.Error == nil || read.Error.Code != -32602 {
+		t.Errorf("Expected an unknown tool to be refused, got %+v", read)
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/audit"
+	"mcp-server/internal/tools"
+	"mcp-server/internal/utils"
+)
+
+var (
+	contextType  = reflect.TypeOf((*context.Context)(nil)).Elem()
+	responseType = reflect.TypeOf((*mcp.ToolResponse)(nil))
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// inflightCall tracks a tool invocation that has not returned yet
+type inflightCall struct {
+	tool    string
+	session string
+	started time.Time
+	cancel  context.CancelFunc
+}
+
+// toolHandler builds the function registered with the MCP library for a tool.
+// It has the same signature as the tool's Execute method so the library can derive
+// the input schema from it. Calls from clients arrive through enableToolCalls instead,
+// but both end in callTool.
+func (s *Server) toolHandler(tool tools.Tool, sess *session) interface{} {
+	handlerType := reflect.ValueOf(tool).MethodByName("Execute").Type()
+
+	return reflect.MakeFunc(handlerType, func(in []reflect.Value) []reflect.Value {
+		resp, err := s.callTool(tool, sess, in[0].Interface(), nil)
+		if err != nil {
+			return []reflect.Value{reflect.Zero(responseType), resultError(err)}
+		}
+
+		// Normalize a nil error so the library sees a plain nil interface
+		return []reflect.Value{reflect.ValueOf(resp), reflect.Zero(errorType)}
+	}).Interface()
+}
+
+// enableToolCalls answers tools/call rather than leaving it to the MCP library, which
+// decodes the arguments before the server sees them. Validation needs the arguments
+// as the client sent them to tell a missing required argument from a zero value.
+func (s *Server) enableToolCalls(sess *session) {
+	sess.ext.handle("tools/call", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid tools/call params: %v", err)
+		}
+
+		tool := s.findTool(request.Name)
+		if tool == nil {
+			return nil, invalidParams("unknown tool %q", request.Name)
+		}
+
+		raw := request.Arguments
+		if len(raw) == 0 || string(raw) == "null" {
+			raw = json.RawMessage("{}")
+		}
+		args := reflect.New(reflect.ValueOf(tool).MethodByName("Execute").Type().In(0))
+		if err := json.Unmarshal(raw, args.Interface()); err != nil {
+			return newToolCallResult(nil, utils.NewToolError(utils.ErrInvalidArgs, "Invalid arguments for %s: %v", tool.Name(), err)), nil
+		}
+
+		return newToolCallResult(s.callTool(tool, sess, args.Elem().Interface(), raw)), nil
+	})
+}
+
+// callTool runs a tool call through the server, so that it can be refused, tracked,
+// cancelled during shutdown and passed through the middleware chain. raw holds the
+// arguments as the client sent them, or is nil when args did not come from a client.
+func (s *Server) callTool(tool tools.Tool, sess *session, args interface{}, raw json.RawMessage) (*mcp.ToolResponse, error) {
+	started := time.Now()
+
+	ctx, requestID, done, err := s.beginCall(tool.Name(), sess.id)
+	if err != nil {
+		slog.Warn("Refused tool call", "session", sess.id, "tool", tool.Name(), "error", err)
+		s.audit(&tools.Call{Session: sess.id, Tool: tool.Name()}, args, started, "refused", utils.AsToolError(err))
+		return nil, err
+	}
+	defer done()
+
+	call := &tools.Call{RequestID: requestID, Session: sess.id, Tool: tool.Name(), Config: s.configFor(sess.id)}
+	ctx = tools.WithCall(ctx, call)
+
+	return s.chain(toolExecutor(tool))(ctx, &Invocation{
+		Tool:    tool,
+		Call:    call,
+		Args:    args,
+		RawArgs: raw,
+		Peer:    sess.peer,
+		Started: started,
+	})
+}
+
+// toolExecutor returns the end of the middleware chain for tool, which calls its
+// ExecuteContext method, or Execute when it cannot be cancelled
+func toolExecutor(tool tools.Tool) Handler {
+	toolValue := reflect.ValueOf(tool)
+	executeMethod := toolValue.MethodByName("Execute")
+
+	contextMethod := toolValue.MethodByName("ExecuteContext")
+	if contextMethod.IsValid() {
+		methodType := contextMethod.Type()
+		if methodType.NumIn() != 2 || methodType.In(0) != contextType {
+			contextMethod = reflect.Value{}
+		}
+	}
+
+	argsType := executeMethod.Type().In(0)
+
+	return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+		args := reflect.ValueOf(inv.Args)
+		if !args.IsValid() || args.Type() != argsType {
+			return nil, utils.NewToolError(utils.ErrInternal, "Arguments for %s were replaced with a %T", tool.Name(), inv.Args)
+		}
+
+		var out []reflect.Value
+		if contextMethod.IsValid() {
+			out = contextMethod.Call([]reflect.Value{reflect.ValueOf(ctx), args})
+		} else {
+			out = executeMethod.Call([]reflect.Value{args})
+		}
+
+		resp, _ := out[0].Interface().(*mcp.ToolResponse)
+		err, _ := out[1].Interface().(error)
+		return resp, err
+	}
+}
+
+// PanicCounts returns how many times each tool has panicked since the server started
+func (s *Server) PanicCounts() map[string]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]uint64, len(s.panics))
+	for tool, count := range s.panics {
+		counts[tool] = count
+	}
+	return counts
+}
+
+// beginCall registers a new in-flight call and assigns it a request ID,
+// or refuses it if the server is shutting down
+func (s *Server) beginCall(tool, sessionID string) (context.Context, string, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return nil, "", nil, utils.NewToolError(utils.ErrUnavailable, "Server is shutting down, %s was not run", tool)
+	}
+
+	ctx, cancel := context.WithCancel(s.baseCtx)
+
+	s.nextCallID++
+	id := s.nextCallID
+	s.calls[id] = &inflightCall{
+		tool:    tool,
+		session: sessionID,
+		started: time.Now(),
+		cancel:  cancel,
+	}
+	s.wg.Add(1)
+
+	done := func() {
+		cancel()
+		s.mu.Lock()
+		delete(s.calls, id)
+		s.mu.Unlock()
+		s.wg.Done()
+	}
+
+	return ctx, fmt.Sprintf("call-%d", id), done, nil
+}
+
+// resultError wraps err for the MCP library, which reports any error a handler returns
+// as a tool result with isError set and the error's text as its content. The text is
+// the JSON encoding of the tool error so clients can read its code.
+func resultError(err error) reflect.Value {
+	var wrapped error = toolResultError{utils.AsToolError(err)}
+	return reflect.ValueOf(&wrapped).Elem()
+}
+
+// toolCallResult is a tools/call result, encoded as the MCP library encodes it
+type toolCallResult struct {
+	Content []*mcp.Content `json:"content"`
+	IsError bool           `json:"isError"`
+}
+
+// newToolCallResult encodes a tool's response, or its error as the text of a result
+// with isError set, as the library does with resultError
+func newToolCallResult(resp *mcp.ToolResponse, err error) toolCallResult {
+	if err != nil {
+		return toolCallResult{Content: []*mcp.Content{mcp.NewTextContent(utils.AsToolError(err).JSON())}, IsError: true}
+	}
+	if resp == nil {
+		return toolCallResult{Content: []*mcp.Content{}}
+	}
+	return toolCallResult{Content: resp.Content}
+}
+
+// toolResultError presents a tool error in its wire encoding
+type toolResultError struct {
+	err *utils.ToolError
+}
+
+func (e toolResultError) Error() string {
+	return e.err.JSON()
+}
+
+func (e toolResultError) Unwrap() error {
+	return e.err
+}
+
+// callStatus summarizes how a call ended
+func callStatus(call *tools.Call, err *utils.ToolError) string {
+	if err != nil {
+		if err.Code == utils.ErrPathDenied || err.Code == utils.ErrCommandDenied {
+			return "denied"
+		}
+		return "error"
+	}
+	for _, decision := range call.Decisions() {
+		if !decision.Allowed {
+			return "denied"
+		}
+	}
+	return "ok"
+}
+
+// audit records a call in the audit log, if one is configured
+func (s *Server) audit(call *tools.Call, args interface{}, started time.Time, status string, callErr *utils.ToolError) {
+	if s.Audit == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Time:         started,
+		Session:      call.Session,
+		RequestID:    call.RequestID,
+		Tool:         call.Tool,
+		Args:         audit.SanitizeArgs(args),
+		Decisions:    call.Decisions(),
+		Status:       status,
+		BytesRead:    call.BytesRead(),
+		BytesWritten: call.BytesWritten(),
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+
+	if err := s.Audit.Record(entry); err != nil {
+		slog.Error("Failed to write audit entry", "request_id", call.RequestID, "error", err)
+	}
+}
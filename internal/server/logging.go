@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"mcp-server/internal/tools"
+)
+
+// mcpLogLevels maps MCP (syslog) severities onto slog levels
+var mcpLogLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo + 2,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError + 4,
+	"alert":     slog.LevelError + 8,
+	"emergency": slog.LevelError + 12,
+}
+
+// defaultClientLogLevel is used until the client sends logging/setLevel.
+// It is high enough to surface policy denials and errors without flooding the client.
+const defaultClientLogLevel = slog.LevelWarn
+
+// mcpLevelName returns the MCP severity for a slog level
+func mcpLevelName(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "debug"
+	case level < mcpLogLevels["notice"]:
+		return "info"
+	case level < slog.LevelWarn:
+		return "notice"
+	case level < slog.LevelError:
+		return "warning"
+	case level < mcpLogLevels["critical"]:
+		return "error"
+	case level < mcpLogLevels["alert"]:
+		return "critical"
+	case level < mcpLogLevels["emergency"]:
+		return "alert"
+	default:
+		return "emergency"
+	}
+}
+
+// enableClientLogging declares the logging capability on a session and handles logging/setLevel
+func (s *Server) enableClientLogging(sess *session) {
+	sess.logLevel.Store(int64(defaultClientLogLevel))
+
+	sess.ext.addCapability("logging", map[string]interface{}{})
+	sess.ext.handle("logging/setLevel", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Level string `json:"level"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid logging/setLevel params: %v", err)
+		}
+
+		level, ok := mcpLogLevels[request.Level]
+		if !ok {
+			return nil, invalidParams("unknown log level %q", request.Level)
+		}
+
+		sess.logLevel.Store(int64(level))
+		slog.Debug("Client log level changed", "session", sess.id, "level", request.Level)
+		return map[string]interface{}{}, nil
+	})
+}
+
+// ClientLogHandler wraps next so that records logged with the context of a tool call
+// are also sent to that call's client as notifications/message, subject to the level
+// the client chose with logging/setLevel. Install it as the default slog handler:
+//
+//	slog.SetDefault(slog.New(srv.ClientLogHandler(logger.Handler())))
+func (s *Server) ClientLogHandler(next slog.Handler) slog.Handler {
+	return &clientLogHandler{next: next, server: s}
+}
+
+// clientLogHandler forwards log records to the MCP client of the call they belong to
+type clientLogHandler struct {
+	next   slog.Handler
+	server *Server
+	attrs  []slog.Attr
+	groups []string
+}
+
+// sessionFor returns the session of the tool call in ctx, if any
+func (h *clientLogHandler) sessionFor(ctx context.Context) *session {
+	if ctx == nil {
+		return nil
+	}
+
+	call := tools.CallFromContext(ctx)
+	if call == nil {
+		return nil
+	}
+
+	h.server.mu.Lock()
+	defer h.server.mu.Unlock()
+	return h.server.sessions[call.Session]
+}
+
+func (h *clientLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next.Enabled(ctx, level) {
+		return true
+	}
+	sess := h.sessionFor(ctx)
+	return sess != nil && level >= slog.Level(sess.logLevel.Load())
+}
+
+func (h *clientLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, record.Level) {
+		err = h.next.Handle(ctx, record)
+	}
+
+	sess := h.sessionFor(ctx)
+	if sess == nil || record.Level < slog.Level(sess.logLevel.Load()) {
+		return err
+	}
+
+	data := map[string]interface{}{"message": record.Message}
+	for _, attr := range h.attrs {
+		addLogAttr(data, h.groups, attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addLogAttr(data, h.groups, attr)
+		return true
+	})
+
+	notifyErr := sess.ext.notify("notifications/message", map[string]interface{}{
+		"level":  mcpLevelName(record.Level),
+		"logger": "mcp-server",
+		"data":   data,
+	})
+	if err == nil {
+		err = notifyErr
+	}
+	return err
+}
+
+func (h *clientLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *clientLogHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+// addLogAttr adds an attribute to the notification payload, flattening groups into dotted keys
+func addLogAttr(data map[string]interface{}, groups []string, attr slog.Attr) {
+	key := attr.Key
+	for i := len(groups) - 1; i >= 0; i-- {
+		key = groups[i] + "." + key
+	}
+
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		for _, nested := range value.Group() {
+			addLogAttr(data, append(groups, attr.Key), nested)
+		}
+		return
+	}
+
+	switch v := value.Any().(type) {
+	case error:
+		data[key] = v.Error()
+	case time.Duration:
+		data[key] = v.String()
+	default:
+		data[key] = v
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"mcp-server/internal/tools"
+)
+
+// advertiseToolMetadata adds each tool's title and annotations to tools/list, which
+// the MCP library only fills with the name, description and input schema
+func (s *Server) advertiseToolMetadata(sess *session) {
+	sess.ext.patch("tools/list", func(result map[string]interface{}) error {
+		listed, _ := result["tools"].([]interface{})
+
+		s.mu.Lock()
+		byName := make(map[string]tools.Tool, len(s.tools))
+		for _, tool := range s.tools {
+			byName[tool.Name()] = tool
+		}
+		s.mu.Unlock()
+
+		for _, entry := range listed {
+			entry, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := entry["name"].(string)
+			tool, ok := byName[name]
+			if !ok {
+				continue
+			}
+
+			annotations := tool.Annotations()
+			entry["title"] = tool.Title()
+			entry["annotations"] = map[string]interface{}{
+				"title":           tool.Title(),
+				"readOnlyHint":    annotations.ReadOnly,
+				"destructiveHint": annotations.Destructive && !annotations.ReadOnly,
+				"idempotentHint":  annotations.Idempotent,
+				"openWorldHint":   annotations.OpenWorld,
+			}
+		}
+
+		return nil
+	})
+}
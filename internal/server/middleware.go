@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/budget"
+	"mcp-server/internal/tools"
+	"mcp-server/internal/utils"
+	"mcp-server/internal/validate"
+)
+
+// Invocation is a tool call on its way through the middleware chain
+type Invocation struct {
+	// Tool is the tool being called
+	Tool tools.Tool
+
+	// Call carries the request ID and session, and collects what the tool reports
+	Call *tools.Call
+
+	// Args is the decoded argument struct. Middleware may replace it with another
+	// value of the same type.
+	Args interface{}
+
+	// RawArgs holds the arguments as the client sent them. It is nil when the call did
+	// not come from a client.
+	RawArgs json.RawMessage
+
+	// Peer holds the credentials of the client process on the unix socket transport.
+	// It is nil on stdio.
+	Peer *PeerCredentials
+
+	// Started is when the server received the call
+	Started time.Time
+}
+
+// Handler runs a tool call
+type Handler func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error)
+
+// Middleware wraps a Handler to add behaviour around every tool call. It can inspect
+// or replace the arguments, refuse the call by returning an error without calling
+// next, or inspect and replace the result.
+type Middleware func(next Handler) Handler
+
+// Use adds middleware to the chain every tool call passes through. The built-in
+// recovery, logging, audit, budget, validation and path policy middleware always run first;
+// middleware added with Use runs after them, in the order given, and then the tool.
+func (s *Server) Use(middleware ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// chain wraps final in the built-in middleware and the middleware added with Use
+func (s *Server) chain(final Handler) Handler {
+	s.mu.Lock()
+	middleware := append([]Middleware{
+		s.recoverPanics,
+		logCalls,
+		s.auditCalls,
+		s.enforceBudget,
+		validateArgs,
+		s.enforcePolicy,
+	}, s.middleware...)
+	s.mu.Unlock()
+
+	handler := final
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// recoverPanics turns a panic anywhere further down the chain into an INTERNAL error,
+// so that one broken tool cannot take the whole server down. The panic is logged with
+// its stack and counted against the tool.
+func (s *Server) recoverPanics(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (resp *mcp.ToolResponse, err error) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.panics[inv.Call.Tool]++
+			count := s.panics[inv.Call.Tool]
+			s.mu.Unlock()
+
+			// The stack only goes to the server log, not to the client
+			slog.Error("Tool panicked",
+				"request_id", inv.Call.RequestID,
+				"session", inv.Call.Session,
+				"tool", inv.Call.Tool,
+				"panic", fmt.Sprint(recovered),
+				"panics", count,
+				"stack", string(debug.Stack()),
+			)
+
+			resp = nil
+			err = utils.NewToolError(utils.ErrInternal, "%s failed unexpectedly: %v", inv.Call.Tool, recovered)
+		}()
+
+		return next(ctx, inv)
+	}
+}
+
+// logCalls logs every call with its duration and outcome
+func logCalls(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+		resp, err := next(ctx, inv)
+
+		attrs := []any{
+			"request_id", inv.Call.RequestID,
+			"session", inv.Call.Session,
+			"tool", inv.Call.Tool,
+			"duration", time.Since(inv.Started),
+		}
+		if err != nil {
+			toolErr := utils.AsToolError(err)
+			status := callStatus(inv.Call, toolErr)
+			slog.WarnContext(ctx, "Tool call failed", append(attrs, "outcome", status, "code", toolErr.Code, "error", toolErr.Message)...)
+		} else {
+			slog.InfoContext(ctx, "Tool call completed", append(attrs, "outcome", callStatus(inv.Call, nil))...)
+		}
+
+		return resp, err
+	}
+}
+
+// auditCalls records every call in the audit log, if one is configured
+func (s *Server) auditCalls(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+		resp, err := next(ctx, inv)
+
+		var toolErr *utils.ToolError
+		if err != nil {
+			toolErr = utils.AsToolError(err)
+		}
+		s.audit(inv.Call, inv.Args, inv.Started, callStatus(inv.Call, toolErr), toolErr)
+
+		return resp, err
+	}
+}
+
+// enforceBudget refuses calls once the session or tool has used up a budget, and
+// charges each call's writes, command CPU time and output against its budgets
+func (s *Server) enforceBudget(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (resp *mcp.ToolResponse, err error) {
+		if s.Budget == nil {
+			return next(ctx, inv)
+		}
+		if exempt, ok := inv.Tool.(tools.BudgetExempt); ok && exempt.BudgetExempt() {
+			return next(ctx, inv)
+		}
+
+		reservation, err := s.Budget.Begin(inv.Call.Session, inv.Call.Tool)
+		if err != nil {
+			slog.WarnContext(ctx, "Tool call over budget", "tool", inv.Call.Tool, "error", err)
+			return nil, err
+		}
+
+		// Deferred so the concurrency slot is released even if the tool panics
+		defer func() {
+			consumed := budget.Consumed{
+				BytesWritten: inv.Call.BytesWritten(),
+				CPUTime:      inv.Call.CPUTime(),
+			}
+			if resp != nil {
+				if encoded, encodeErr := json.Marshal(resp.Content); encodeErr == nil {
+					consumed.OutputBytes = int64(len(encoded))
+				}
+			}
+			reservation.End(consumed)
+		}()
+
+		return next(ctx, inv)
+	}
+}
+
+// validateArgs enforces the constraints declared on the argument struct
+func validateArgs(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+		if err := validate.Present(inv.Args, inv.RawArgs); err != nil {
+			return nil, err
+		}
+		if err := validate.Struct(inv.Args); err != nil {
+			return nil, err
+		}
+		return next(ctx, inv)
+	}
+}
+
+// enforcePolicy checks every path named by the arguments against the session's path policy
+func (s *Server) enforcePolicy(next Handler) Handler {
+	return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+		if pathArgs, ok := inv.Args.(tools.PathArgs); ok {
+			cfg := s.configFor(inv.Call.Session)
+			for _, arg := range pathArgs.Paths() {
+				if err := tools.RequirePath(ctx, cfg, arg.Path, arg.What); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return next(ctx, inv)
+	}
+}
+
+// Auth returns middleware that asks authorize whether each call may run. A non-nil
+// error refuses the call; errors other than a *utils.ToolError are reported to the
+// client as UNAUTHORIZED.
+func Auth(authorize func(ctx context.Context, inv *Invocation) error) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+			if err := authorize(ctx, inv); err != nil {
+				if _, ok := err.(*utils.ToolError); !ok {
+					err = utils.NewToolError(utils.ErrUnauthorized, "%s", err.Error())
+				}
+				slog.WarnContext(ctx, "Tool call not authorized", "tool", inv.Call.Tool, "error", err)
+				return nil, err
+			}
+			return next(ctx, inv)
+		}
+	}
+}
+
+// RateLimit returns middleware that allows each session at most limit calls in any
+// sliding window of the given length. Calls over the limit fail with RATE_LIMITED.
+func RateLimit(limit int, window time.Duration) Middleware {
+	limiter := &rateLimiter{
+		limit:  limit,
+		window: window,
+		calls:  make(map[string][]time.Time),
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+			if retryAfter := limiter.allow(inv.Call.Session, time.Now()); retryAfter > 0 {
+				slog.WarnContext(ctx, "Tool call rate limited", "tool", inv.Call.Tool, "limit", limit, "window", window)
+				return nil, utils.NewToolError(utils.ErrRateLimited, "More than %d calls in %s; retry in %s", limit, window, retryAfter.Round(time.Millisecond)).
+					WithDetails(map[string]interface{}{"retry_after_ms": retryAfter.Milliseconds()})
+			}
+			return next(ctx, inv)
+		}
+	}
+}
+
+// rateLimiter keeps the recent call times of each session
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	calls     map[string][]time.Time
+	lastSweep time.Time
+}
+
+// allow records a call at now if the session is under its limit. Otherwise it
+// returns how long until the oldest call in the window expires.
+func (l *rateLimiter) allow(session string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+
+	// Forget sessions that have gone quiet so the map doesn't grow without bound
+	if now.Sub(l.lastSweep) > l.window {
+		for id, times := range l.calls {
+			if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+				delete(l.calls, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	times := l.calls[session]
+	for len(times) > 0 && !times[0].After(cutoff) {
+		times = times[1:]
+	}
+
+	if len(times) >= l.limit {
+		l.calls[session] = times
+		return times[0].Sub(cutoff)
+	}
+
+	l.calls[session] = append(times, now)
+	return 0
+}
+
+// OutputBudget returns middleware that rejects results larger than maxBytes, as
+// encoded on the wire, with TOO_LARGE instead of sending them to the client
+func OutputBudget(maxBytes int) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+			resp, err := next(ctx, inv)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+
+			encoded, err := json.Marshal(resp.Content)
+			if err != nil {
+				return nil, utils.NewToolError(utils.ErrInternal, "Failed to encode result: %v", err)
+			}
+			if len(encoded) > maxBytes {
+				slog.WarnContext(ctx, "Tool output over budget", "tool", inv.Call.Tool, "bytes", len(encoded), "limit", maxBytes)
+				return nil, utils.NewToolError(utils.ErrTooLarge, "Result is %d bytes, more than the %d byte limit; request less output", len(encoded), maxBytes).
+					WithDetails(map[string]interface{}{"bytes": len(encoded), "limit": maxBytes})
+			}
+
+			return resp, nil
+		}
+	}
+}
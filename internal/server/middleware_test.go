@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/budget"
+	"mcp-server/internal/config"
+	"mcp-server/internal/tools"
+	"mcp-server/internal/utils"
+)
+
+// echoToolArgs defines the arguments for echoTool
+type echoToolArgs struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Paths returns the path argument for the policy check
+func (a echoToolArgs) Paths() []tools.PathArg {
+	return []tools.PathArg{{Path: a.Path, What: "file path"}}
+}
+
+// echoTool returns its message and counts how often it ran
+type echoTool struct {
+	runs int
+}
+
+func (t *echoTool) Name() string        { return "echo" }
+func (t *echoTool) Description() string { return "Echoes its message" }
+func (t *echoTool) Title() string       { return "Echo" }
+func (t *echoTool) Annotations() tools.Annotations {
+	return tools.Annotations{ReadOnly: true}
+}
+
+func (t *echoTool) Execute(args echoToolArgs) (*mcp.ToolResponse, error) {
+	t.runs++
+	return mcp.NewToolResponse(mcp.NewTextContent(args.Message)), nil
+}
+
+// newEchoHandler returns a server with cwd allowed and a direct handler for echoTool
+func newEchoHandler(t *testing.T, middleware ...Middleware) (*echoTool, func(echoToolArgs) (*mcp.ToolResponse, error)) {
+	t.Helper()
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	srv, err := NewServerWithConfig(config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	srv.Use(middleware...)
+
+	tool := &echoTool{}
+	handler := srv.toolHandler(tool, &session{id: "session-test"}).(func(echoToolArgs) (*mcp.ToolResponse, error))
+	return tool, handler
+}
+
+// expectCode fails the test unless err is a tool error with the given code
+func expectCode(t *testing.T, err error, code utils.ErrorCode) {
+	t.Helper()
+
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) {
+		t.Fatalf("Expected a %s error, got: %v", code, err)
+	}
+	if toolErr.Code != code {
+		t.Fatalf("Expected a %s error, got %s: %s", code, toolErr.Code, toolErr.Message)
+	}
+}
+
+func TestMiddleware_RunsInOrderAroundTool(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+				order = append(order, name+":"+inv.Call.Tool)
+				return next(ctx, inv)
+			}
+		}
+	}
+	shout := func(next Handler) Handler {
+		return func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+			args := inv.Args.(echoToolArgs)
+			args.Message = strings.ToUpper(args.Message)
+			inv.Args = args
+			return next(ctx, inv)
+		}
+	}
+
+	tool, handler := newEchoHandler(t, trace("first"), trace("second"), shout)
+
+	resp, err := handler(echoToolArgs{Path: "notes.txt", Message: "hello"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := resp.Content[0].TextContent.Text; got != "HELLO" {
+		t.Errorf("Expected middleware to rewrite the arguments, got %q", got)
+	}
+	if strings.Join(order, ",") != "first:echo,second:echo" || tool.runs != 1 {
+		t.Errorf("Unexpected middleware order %v with %d runs", order, tool.runs)
+	}
+}
+
+func TestMiddleware_PolicyRunsBeforeTool(t *testing.T) {
+	tool, handler := newEchoHandler(t)
+
+	_, err := handler(echoToolArgs{Path: "/definitely/not/allowed", Message: "hello"})
+	expectCode(t, err, utils.ErrPathDenied)
+	if tool.runs != 0 {
+		t.Error("Expected the tool not to run after a policy denial")
+	}
+}
+
+func TestMiddleware_Auth(t *testing.T) {
+	tool, handler := newEchoHandler(t, Auth(func(ctx context.Context, inv *Invocation) error {
+		if inv.Args.(echoToolArgs).Message == "secret" {
+			return errors.New("secrets are off limits")
+		}
+		return nil
+	}))
+
+	_, err := handler(echoToolArgs{Path: "notes.txt", Message: "secret"})
+	expectCode(t, err, utils.ErrUnauthorized)
+
+	if _, err := handler(echoToolArgs{Path: "notes.txt", Message: "public"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tool.runs != 1 {
+		t.Errorf("Expected exactly the authorized call to run, got %d runs", tool.runs)
+	}
+}
+
+func TestMiddleware_OutputBudget(t *testing.T) {
+	_, handler := newEchoHandler(t, OutputBudget(64))
+
+	if _, err := handler(echoToolArgs{Path: "notes.txt", Message: "short"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := handler(echoToolArgs{Path: "notes.txt", Message: strings.Repeat("x", 100)})
+	expectCode(t, err, utils.ErrTooLarge)
+}
+
+func TestMiddleware_RateLimit(t *testing.T) {
+	_, handler := newEchoHandler(t, RateLimit(2, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if _, err := handler(echoToolArgs{Path: "notes.txt", Message: "hi"}); err != nil {
+			t.Fatalf("Unexpected error on call %d: %v", i+1, err)
+		}
+	}
+
+	_, err := handler(echoToolArgs{Path: "notes.txt", Message: "hi"})
+	expectCode(t, err, utils.ErrRateLimited)
+}
+
+func TestRateLimiter_WindowSlides(t *testing.T) {
+	limiter := &rateLimiter{limit: 2, window: time.Second, calls: make(map[string][]time.Time)}
+	start := time.Now()
+
+	if limiter.allow("a", start) != 0 || limiter.allow("a", start.Add(100*time.Millisecond)) != 0 {
+		t.Fatal("Expected the first two calls to be allowed")
+	}
+	if wait := limiter.allow("a", start.Add(500*time.Millisecond)); wait != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms for the oldest call to expire, got %s", wait)
+	}
+	if limiter.allow("b", start.Add(500*time.Millisecond)) != 0 {
+		t.Error("Expected other sessions to have their own limit")
+	}
+	if limiter.allow("a", start.Add(1001*time.Millisecond)) != 0 {
+		t.Error("Expected a call to be allowed once the oldest call left the window")
+	}
+}
+
+func TestMiddleware_BudgetExceeded(t *testing.T) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	srv, err := NewServerWithConfig(config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	srv.Budget = budget.NewTracker(&config.BudgetConfig{
+		Tools: map[string]config.BudgetLimits{"echo": {OutputBytes: 10}},
+	})
+
+	sess := &session{id: "session-test"}
+	echo := srv.toolHandler(&echoTool{}, sess).(func(echoToolArgs) (*mcp.ToolResponse, error))
+	usage := srv.toolHandler(tools.NewUsageTool(srv.Budget), sess).(func(tools.UsageArgs) (*mcp.ToolResponse, error))
+
+	if _, err := echo(echoToolArgs{Path: "notes.txt", Message: "a long enough message"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = echo(echoToolArgs{Path: "notes.txt", Message: "again"})
+	expectCode(t, err, utils.ErrBudgetExceeded)
+
+	// The usage tool still answers once a budget is exhausted
+	resp, err := usage(tools.UsageArgs{})
+	if err != nil {
+		t.Fatalf("Unexpected error from usage: %v", err)
+	}
+	if text := resp.Content[0].TextContent.Text; !strings.Contains(text, `"output_bytes":0`) {
+		t.Errorf("Expected usage to report no output bytes remaining, got: %s", text)
+	}
+}
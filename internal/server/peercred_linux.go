@@ -0,0 +1,64 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix creates the socket with the given permissions already applied,
+// so there is no window in which other users could connect
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	oldMask := syscall.Umask(0777 &^ int(mode))
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// peerCredentials reads SO_PEERCRED from a unix socket connection
+func peerCredentials(conn net.Conn) (*PeerCredentials, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("not a unix socket connection")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *syscall.Ucred
+	var groups []int
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+		if credErr == nil {
+			groups, credErr = peerGroups(fd)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	return &PeerCredentials{
+		PID:    int(cred.Pid),
+		UID:    int(cred.Uid),
+		GID:    int(cred.Gid),
+		Groups: groups,
+	}, nil
+}
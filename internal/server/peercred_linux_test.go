@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"mcp-server/internal/config"
+)
+
+// TestHelperConnect is run as another user by TestServer_UnixSocket_SupplementaryGroup.
+// It lists the tools on the socket in MCP_TEST_SOCKET and prints the response.
+func TestHelperConnect(t *testing.T) {
+	socketPath := os.Getenv("MCP_TEST_SOCKET")
+	if socketPath == "" {
+		t.Skip("Only run as a helper process")
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{}}` + "\n"))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	fmt.Print(line)
+	os.Exit(0)
+}
+
+func TestServer_UnixSocket_SupplementaryGroup(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Connecting as another user needs root")
+	}
+
+	// nobody, with an allowed group that is only ever a supplementary one
+	const uid, gid, allowedGID = 65534, 65534, 4242
+	_, socketPath := startUnixServer(t, &config.TransportConfig{AllowedGIDs: []int{allowedGID}})
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Expected socket file to exist: %v", err)
+	}
+	if owner := info.Sys().(*syscall.Stat_t); owner.Gid != allowedGID || info.Mode().Perm() != 0660 {
+		t.Fatalf("Expected the socket to be 0660 and belong to group %d, got %o and group %d", allowedGID, info.Mode().Perm(), owner.Gid)
+	}
+
+	// The helper runs a copy of the test binary next to the socket, where nobody can reach it
+	dir := filepath.Dir(socketPath)
+	for _, d := range []string{dir, filepath.Dir(dir)} {
+		if err := os.Chmod(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	helper := filepath.Join(dir, "helper.test")
+	copyExecutable(t, helper)
+
+	tests := []struct {
+		name    string
+		groups  []uint32
+		allowed bool
+	}{
+		{"in the allowed group", []uint32{allowedGID}, true},
+		{"in no allowed group", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(helper, "-test.run=^TestHelperConnect$")
+			cmd.Env = append(os.Environ(), "MCP_TEST_SOCKET="+socketPath)
+			cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uid, Gid: gid, Groups: tt.groups}}
+			out, err := cmd.Output()
+
+			listed := strings.Contains(string(out), `"show_file"`)
+			if listed != tt.allowed {
+				t.Errorf("Expected the peer to be allowed: %v, got output %q (err %v)", tt.allowed, out, err)
+			}
+		})
+	}
+}
+
+// copyExecutable copies the running test binary to path
+func copyExecutable(t *testing.T, path string) {
+	t.Helper()
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
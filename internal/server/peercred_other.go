@@ -0,0 +1,29 @@
+//go:build !linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// listenUnix creates the socket and restricts its permissions
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// peerCredentials is only implemented on Linux; other platforms reject every connection
+func peerCredentials(conn net.Conn) (*PeerCredentials, error) {
+	return nil, fmt.Errorf("SO_PEERCRED is not supported on this platform")
+}
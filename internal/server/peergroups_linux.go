@@ -0,0 +1,38 @@
+//go:build linux && !386
+
+package server
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// soPeerGroups is SO_PEERGROUPS, which the syscall package doesn't define
+const soPeerGroups = 0x3b
+
+// peerGroups reads the supplementary groups of a unix socket's peer with
+// SO_PEERGROUPS. Kernels before 4.13 don't support it; the peer is then only
+// known by its primary group.
+func peerGroups(fd uintptr) ([]int, error) {
+	buf := make([]uint32, 32)
+	for {
+		size := uint32(len(buf) * 4)
+		_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, syscall.SOL_SOCKET, soPeerGroups,
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
+		switch {
+		case errno == 0:
+			groups := make([]int, size/4)
+			for i := range groups {
+				groups[i] = int(buf[i])
+			}
+			return groups, nil
+		case errno == syscall.ERANGE && int(size/4) > len(buf):
+			// The kernel reports how much room the groups need
+			buf = make([]uint32, size/4)
+		case errno == syscall.ENOPROTOOPT:
+			return nil, nil
+		default:
+			return nil, errno
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/prompts"
+	"mcp-server/internal/utils"
+)
+
+// enablePrompts offers the server's prompt templates on a session
+func (s *Server) enablePrompts(sess *session) {
+	if len(s.Prompts) == 0 {
+		return
+	}
+
+	sess.ext.addCapability("prompts", map[string]interface{}{"listChanged": false})
+
+	sess.ext.handle("prompts/list", func(params json.RawMessage) (interface{}, error) {
+		listed := []map[string]interface{}{}
+		for _, prompt := range s.availablePrompts() {
+			arguments := []map[string]interface{}{}
+			for _, arg := range prompt.Arguments {
+				arguments = append(arguments, map[string]interface{}{
+					"name":        arg.Name,
+					"description": arg.Description,
+					"required":    arg.Required,
+				})
+			}
+
+			entry := map[string]interface{}{
+				"name":        prompt.Name,
+				"description": prompt.Description,
+				"arguments":   arguments,
+			}
+			if prompt.Title != "" {
+				entry["title"] = prompt.Title
+			}
+			listed = append(listed, entry)
+		}
+		return map[string]interface{}{"prompts": listed}, nil
+	})
+
+	sess.ext.handle("prompts/get", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid prompts/get params: %v", err)
+		}
+
+		prompt := s.findPrompt(request.Name)
+		if prompt == nil {
+			return nil, invalidParams("unknown prompt %q", request.Name)
+		}
+
+		started := time.Now()
+		text, err := prompt.Render(request.Arguments, s.promptTools(sess))
+		if err != nil {
+			toolErr := utils.AsToolError(err)
+			slog.Warn("Prompt failed", "session", sess.id, "prompt", prompt.Name, "code", toolErr.Code, "error", toolErr.Message)
+			return nil, promptError(toolErr)
+		}
+		slog.Info("Prompt rendered", "session", sess.id, "prompt", prompt.Name, "duration", time.Since(started))
+
+		return map[string]interface{}{
+			"description": prompt.Description,
+			"messages": []map[string]interface{}{{
+				"role":    "user",
+				"content": map[string]interface{}{"type": "text", "text": text},
+			}},
+		}, nil
+	})
+}
+
+// availablePrompts returns the prompts whose tools are all registered
+func (s *Server) availablePrompts() []*prompts.Prompt {
+	s.mu.Lock()
+	registered := make(map[string]bool, len(s.tools))
+	for _, tool := range s.tools {
+		registered[tool.Name()] = true
+	}
+	s.mu.Unlock()
+
+	var available []*prompts.Prompt
+	for _, prompt := range s.Prompts {
+		ok := true
+		for _, name := range prompt.Tools {
+			if !registered[name] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			available = append(available, prompt)
+		}
+	}
+	return available
+}
+
+// promptTools lets a prompt call the session's tools. The calls go through the same
+// handler as the client's own calls, so they are checked, budgeted and audited.
+func (s *Server) promptTools(sess *session) prompts.ToolFunc {
+	return func(name string, args map[string]interface{}) (map[string]interface{}, error) {
+		tool := s.findTool(name)
+		if tool == nil {
+			return nil, utils.NewToolError(utils.ErrUnavailable, "Tool %s is not available", name)
+		}
+
+		handler := reflect.ValueOf(s.toolHandler(tool, sess))
+		argsValue := reflect.New(handler.Type().In(0))
+		encoded, err := json.Marshal(args)
+		if err == nil {
+			err = json.Unmarshal(encoded, argsValue.Interface())
+		}
+		if err != nil {
+			return nil, utils.NewToolError(utils.ErrInvalidArgs, "Invalid arguments for %s: %v", name, err)
+		}
+
+		out := handler.Call([]reflect.Value{argsValue.Elem()})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, utils.AsToolError(err)
+		}
+
+		resp, _ := out[0].Interface().(*mcp.ToolResponse)
+		if resp == nil || len(resp.Content) == 0 || resp.Content[0].TextContent == nil {
+			return map[string]interface{}{}, nil
+		}
+
+		text := resp.Content[0].TextContent.Text
+		var result map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			return map[string]interface{}{"text": text}, nil
+		}
+		return result, nil
+	}
+}
+
+// promptError converts the error from rendering a prompt into a JSON-RPC error. Tools
+// mostly fail because of the arguments they were given, so their errors are reported
+// as invalid params.
+func promptError(err *utils.ToolError) error {
+	switch err.Code {
+	case utils.ErrInternal, utils.ErrUnavailable:
+		return &rpcError{code: -32603, message: err.Message}
+	}
+	return &rpcError{code: -32602, message: err.Message}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServer_Prompts(t *testing.T) {
+	root, conn, reader := startResourceServer(t, nil)
+
+	response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	if !strings.Contains(response, `"prompts":{"listChanged":false}`) {
+		t.Errorf("Expected the prompts capability to be declared, got: %s", response)
+	}
+
+	// Only show_file is registered, so explain-error is not offered
+	response = roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"prompts/list"}`)
+	var list struct {
+		Result struct {
+			Prompts []struct {
+				Name      string `json:"name"`
+				Arguments []struct {
+					Name     string `json:"name"`
+					Required bool   `json:"required"`
+				} `json:"arguments"`
+			} `json:"prompts"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(response), &list); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", response, err)
+	}
+	var names []string
+	for _, prompt := range list.Result.Prompts {
+		names = append(names, prompt.Name)
+	}
+	if expected := []string{"review-file", "write-tests-for", "summarize-diff"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected prompts %v, got %v", expected, names)
+	}
+
+	path := filepath.Join(root, "a.txt")
+	response = roundTrip(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"review-file","arguments":{"path":%q}}}`, path))
+	var get struct {
+		Result struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"messages"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(response), &get); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", response, err)
+	}
+	if len(get.Result.Messages) != 1 || get.Result.Messages[0].Role != "user" || !strings.Contains(get.Result.Messages[0].Content.Text, "~~~\nhello\n\n~~~") {
+		t.Errorf("Expected the file to be attached to the prompt, got: %s", response)
+	}
+
+	// The tool call behind the prompt is subject to the path policy
+	denied := filepath.Join(root, "secret", "c.txt")
+	read := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":4,"method":"prompts/get","params":{"name":"review-file","arguments":{"path":%q}}}`, denied))
+	if read.Error == nil || read.Error.Code != -32602 {
+		t.Errorf("Expected the denied file to be refused, got %+v", read)
+	}
+
+	read = resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":5,"method":"prompts/get","params":{"name":"explain-error","arguments":{"command":"ls"}}}`)
+	if read.Error == nil || read.Error.Code != -32602 {
+		t.Errorf("Expected a prompt whose tool is missing to be unknown, got %+v", read)
+	}
+}
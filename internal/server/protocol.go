@@ -0,0 +1,396 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// requestHandler answers a JSON-RPC request that the MCP library does not implement
+type requestHandler func(params json.RawMessage) (interface{}, error)
+
+// notificationHandler reacts to a JSON-RPC notification from the client
+type notificationHandler func(params json.RawMessage)
+
+// serverRequestIDBase is where the IDs of requests the server sends to the client
+// start, far above any ID the library's own counter will reach
+const serverRequestIDBase = 1 << 40
+
+// clientReply is the client's response to a request the server sent
+type clientReply struct {
+	result json.RawMessage
+	err    error
+}
+
+// resultPatcher rewrites the library's result for a request before it is sent
+type resultPatcher func(result map[string]interface{}) error
+
+// extendedTransport sits between the MCP library and the real transport. The library
+// only implements part of the protocol and keeps its request routing private, so this
+// is where the server answers the methods the library doesn't know about, augments
+// some of the library's responses and sends its own notifications.
+type extendedTransport struct {
+	transport.Transport
+
+	mu            sync.Mutex
+	handlers      map[string]requestHandler
+	notifications map[string]notificationHandler
+	patchers      map[string]resultPatcher
+	capabilities  map[string]interface{}
+	pending       map[transport.RequestId]string
+	observers     map[string]notificationHandler
+	outgoing      map[transport.RequestId]chan clientReply
+	nextRequestID transport.RequestId
+	onMessage     func(message *transport.BaseJsonRpcMessage)
+}
+
+// newExtendedTransport wraps t
+func newExtendedTransport(t transport.Transport) *extendedTransport {
+	ext := &extendedTransport{
+		Transport:     t,
+		handlers:      make(map[string]requestHandler),
+		notifications: make(map[string]notificationHandler),
+		patchers:      make(map[string]resultPatcher),
+		capabilities:  make(map[string]interface{}),
+		pending:       make(map[transport.RequestId]string),
+		observers:     make(map[string]notificationHandler),
+		outgoing:      make(map[transport.RequestId]chan clientReply),
+		nextRequestID: serverRequestIDBase,
+	}
+
+	// Advertise whatever capabilities the extensions add
+	ext.patch("initialize", func(result map[string]interface{}) error {
+		ext.mu.Lock()
+		defer ext.mu.Unlock()
+
+		capabilities, _ := result["capabilities"].(map[string]interface{})
+		if capabilities == nil {
+			capabilities = make(map[string]interface{})
+		}
+		for name, value := range ext.capabilities {
+			capabilities[name] = value
+		}
+		result["capabilities"] = capabilities
+		return nil
+	})
+
+	return ext
+}
+
+// handle answers requests for method instead of passing them to the library
+func (e *extendedTransport) handle(method string, handler requestHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers[method] = handler
+}
+
+// onNotification registers a handler for a client notification
+func (e *extendedTransport) onNotification(method string, handler notificationHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.notifications[method] = handler
+}
+
+// observe registers a function that sees the params of requests for method before
+// the library answers them
+func (e *extendedTransport) observe(method string, observer notificationHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers[method] = observer
+}
+
+// patch rewrites the library's result for method before it reaches the client
+func (e *extendedTransport) patch(method string, patcher resultPatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.patchers[method] = patcher
+}
+
+// addCapability advertises a server capability in the initialize result
+func (e *extendedTransport) addCapability(name string, value interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.capabilities[name] = value
+}
+
+// notify sends a notification to the client
+func (e *extendedTransport) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return e.Transport.Send(transport.NewBaseMessageNotification(&transport.BaseJSONRPCNotification{
+		Jsonrpc: "2.0",
+		Method:  method,
+		Params:  data,
+	}))
+}
+
+// request sends a request to the client and waits for its response or for ctx to end
+func (e *extendedTransport) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := make(chan clientReply, 1)
+	e.mu.Lock()
+	e.nextRequestID++
+	id := e.nextRequestID
+	e.outgoing[id] = reply
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		delete(e.outgoing, id)
+		e.mu.Unlock()
+	}()
+
+	if err := e.Transport.Send(transport.NewBaseMessageRequest(&transport.BaseJSONRPCRequest{
+		Jsonrpc: "2.0",
+		Id:      id,
+		Method:  method,
+		Params:  data,
+	})); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-reply:
+		return r.result, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no response to %s: %w", method, ctx.Err())
+	}
+}
+
+// SetMessageHandler installs the library's message handler behind our own dispatch
+func (e *extendedTransport) SetMessageHandler(handler func(message *transport.BaseJsonRpcMessage)) {
+	e.mu.Lock()
+	e.onMessage = handler
+	e.mu.Unlock()
+
+	e.Transport.SetMessageHandler(e.dispatch)
+}
+
+// dispatch routes an incoming message either to an extension or to the library
+func (e *extendedTransport) dispatch(message *transport.BaseJsonRpcMessage) {
+	e.mu.Lock()
+	next := e.onMessage
+	var handler requestHandler
+	var observer, notification notificationHandler
+	var reply chan clientReply
+
+	switch message.Type {
+	case transport.BaseMessageTypeJSONRPCRequestType:
+		request := message.JsonRpcRequest
+		handler = e.handlers[request.Method]
+		observer = e.observers[request.Method]
+		if _, ok := e.patchers[request.Method]; ok && handler == nil {
+			e.pending[request.Id] = request.Method
+		}
+	case transport.BaseMessageTypeJSONRPCNotificationType:
+		notification = e.notifications[message.JsonRpcNotification.Method]
+	case transport.BaseMessageTypeJSONRPCResponseType:
+		reply = e.outgoing[message.JsonRpcResponse.Id]
+	case transport.BaseMessageTypeJSONRPCErrorType:
+		reply = e.outgoing[message.JsonRpcError.Id]
+	}
+	e.mu.Unlock()
+
+	// Responses to the server's own requests never reach the library
+	if reply != nil {
+		r := clientReply{}
+		if message.Type == transport.BaseMessageTypeJSONRPCErrorType {
+			inner := message.JsonRpcError.Error
+			r.err = &rpcError{code: inner.Code, message: inner.Message}
+		} else {
+			r.result = message.JsonRpcResponse.Result
+		}
+
+		// A duplicate response is dropped rather than blocking the read loop
+		select {
+		case reply <- r:
+		default:
+		}
+		return
+	}
+
+	if handler != nil {
+		go e.respond(message.JsonRpcRequest, handler)
+		return
+	}
+
+	if observer != nil {
+		observer(message.JsonRpcRequest.Params)
+	}
+
+	if notification != nil {
+		go notification(message.JsonRpcNotification.Params)
+	}
+
+	if next != nil {
+		next(message)
+	}
+}
+
+// respond runs an extension handler and sends its result or error
+func (e *extendedTransport) respond(request *transport.BaseJSONRPCRequest, handler requestHandler) {
+	result, err := handler(request.Params)
+	if err != nil {
+		e.sendError(request.Id, err)
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		e.sendError(request.Id, fmt.Errorf("failed to marshal result: %w", err))
+		return
+	}
+
+	if err := e.Transport.Send(transport.NewBaseMessageResponse(&transport.BaseJSONRPCResponse{
+		Jsonrpc: "2.0",
+		Id:      request.Id,
+		Result:  data,
+	})); err != nil {
+		slog.Error("Failed to send response", "method", request.Method, "error", err)
+	}
+}
+
+// sendError sends a JSON-RPC error response
+func (e *extendedTransport) sendError(id transport.RequestId, err error) {
+	code := -32603 // Internal error
+	if rpcErr, ok := err.(*rpcError); ok {
+		code = rpcErr.code
+	}
+
+	if sendErr := e.Transport.Send(transport.NewBaseMessageError(&transport.BaseJSONRPCError{
+		Jsonrpc: "2.0",
+		Id:      id,
+		Error: transport.BaseJSONRPCErrorInner{
+			Code:    code,
+			Message: err.Error(),
+		},
+	})); sendErr != nil {
+		slog.Error("Failed to send error response", "error", sendErr)
+	}
+}
+
+// Send patches responses to requests that have a patcher before passing them on
+func (e *extendedTransport) Send(message *transport.BaseJsonRpcMessage) error {
+	if message.Type == transport.BaseMessageTypeJSONRPCResponseType {
+		e.mu.Lock()
+		method, ok := e.pending[message.JsonRpcResponse.Id]
+		patcher := e.patchers[method]
+		delete(e.pending, message.JsonRpcResponse.Id)
+		e.mu.Unlock()
+
+		if ok && patcher != nil {
+			if err := e.patchResponse(message.JsonRpcResponse, patcher); err != nil {
+				slog.Error("Failed to extend response", "method", method, "error", err)
+			}
+		}
+	} else if message.Type == transport.BaseMessageTypeJSONRPCErrorType {
+		e.mu.Lock()
+		delete(e.pending, message.JsonRpcError.Id)
+		e.mu.Unlock()
+	}
+
+	return e.Transport.Send(message)
+}
+
+// patchResponse applies patcher to the response's result in place
+func (e *extendedTransport) patchResponse(response *transport.BaseJSONRPCResponse, patcher resultPatcher) error {
+	var result map[string]interface{}
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return err
+	}
+
+	if err := patcher(result); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	response.Result = data
+	return nil
+}
+
+// rpcError is an error with a specific JSON-RPC error code
+type rpcError struct {
+	code    int
+	message string
+}
+
+func (e *rpcError) Error() string {
+	return e.message
+}
+
+// invalidParams reports a JSON-RPC "invalid params" error
+func invalidParams(format string, args ...interface{}) error {
+	return &rpcError{code: -32602, message: fmt.Sprintf(format, args...)}
+}
+
+// paramsReader adds an empty params object to requests and notifications that leave
+// it out. JSON-RPC makes params optional and clients omit it for methods such as
+// resources/list, but the MCP library refuses to parse such a request and then takes
+// it for a response, which it cannot handle.
+type paramsReader struct {
+	r       *bufio.Reader
+	pending []byte
+	err     error
+}
+
+// newParamsReader wraps r, which carries one JSON-RPC message per line
+func newParamsReader(r io.Reader) *paramsReader {
+	return &paramsReader{r: bufio.NewReader(r)}
+}
+
+func (p *paramsReader) Read(buf []byte) (int, error) {
+	for len(p.pending) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		line, err := p.r.ReadBytes('\n')
+		p.pending = addMissingParams(line)
+		p.err = err
+	}
+
+	n := copy(buf, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+// addMissingParams returns line with "params":{} added if it is a request or
+// notification without params, and unchanged otherwise
+func addMissingParams(line []byte) []byte {
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(line, &message); err != nil {
+		return line
+	}
+	if _, ok := message["method"]; !ok {
+		return line
+	}
+	if params, ok := message["params"]; ok && !bytes.Equal(params, []byte("null")) {
+		return line
+	}
+
+	message["params"] = json.RawMessage("{}")
+	data, err := json.Marshal(message)
+	if err != nil {
+		return line
+	}
+	if bytes.HasSuffix(line, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	return data
+}
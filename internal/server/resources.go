@@ -0,0 +1,560 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/config"
+	"mcp-server/internal/tools"
+	"mcp-server/internal/utils"
+)
+
+// resourcePageSize is how many files one page of resources/list holds
+var resourcePageSize = 100
+
+// resourcePollInterval is how often subscribed files are checked for changes
+var resourcePollInterval = time.Second
+
+// maxResourceSize is the largest file resources/read returns
+const maxResourceSize = 10 << 20
+
+// resourceNotFound is the MCP error code for a resource that doesn't exist
+const resourceNotFound = -32002
+
+// enableResources exposes the files under the allowed paths as resources on a session
+func (s *Server) enableResources(sess *session) {
+	sess.resources = newResourceWatcher(sess.ext)
+
+	sess.ext.addCapability("resources", map[string]interface{}{
+		"subscribe":   true,
+		"listChanged": false,
+	})
+
+	sess.ext.handle("resources/list", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			Cursor string `json:"cursor"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid resources/list params: %v", err)
+		}
+
+		offset, err := decodeCursor(request.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		resources, more := listResources(s.configFor(sess.id), offset, resourcePageSize)
+		result := map[string]interface{}{"resources": resources}
+		if more {
+			result["nextCursor"] = encodeCursor(offset + len(resources))
+		}
+		return result, nil
+	})
+
+	sess.ext.handle("resources/templates/list", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"resourceTemplates": []map[string]interface{}{{
+				"uriTemplate": "file:///{path}",
+				"name":        "File",
+				"description": "Any file under the server's allowed paths, by absolute path",
+			}},
+		}, nil
+	})
+
+	sess.ext.handle("resources/read", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid resources/read params: %v", err)
+		}
+
+		return s.readResource(sess, request.URI)
+	})
+
+	sess.ext.handle("resources/subscribe", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid resources/subscribe params: %v", err)
+		}
+
+		call := &tools.Call{Session: sess.id, Tool: "resources/subscribe", Config: s.configFor(sess.id)}
+		path, err := resourcePath(tools.WithCall(s.baseCtx, call), call.Config, request.URI)
+		if err != nil {
+			return nil, resourceError(err)
+		}
+
+		sess.resources.subscribe(request.URI, path)
+		slog.Debug("Resource subscribed", "session", sess.id, "uri", request.URI)
+		return map[string]interface{}{}, nil
+	})
+
+	sess.ext.handle("resources/unsubscribe", func(params json.RawMessage) (interface{}, error) {
+		var request struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, invalidParams("invalid resources/unsubscribe params: %v", err)
+		}
+
+		sess.resources.unsubscribe(request.URI)
+		return map[string]interface{}{}, nil
+	})
+}
+
+// listResources walks the paths cfg allows in order and returns up to limit files,
+// starting with the offset-th, and whether there are more after them. Denied files
+// are left out and denied directories are not entered.
+func listResources(cfg *config.ServerConfig, offset, limit int) ([]map[string]interface{}, bool) {
+	resources := []map[string]interface{}{}
+	if cfg == nil {
+		return resources, false
+	}
+
+	var roots []string
+	for _, root := range cfg.AllowedPaths {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		roots = append(roots, absRoot)
+	}
+
+	errDone := errors.New("page complete")
+	seen := 0
+	more := false
+
+	for i, root := range roots {
+		// A root inside an earlier root has already been listed
+		nested := false
+		for _, earlier := range roots[:i] {
+			if root == earlier || strings.HasPrefix(root, earlier+string(filepath.Separator)) {
+				nested = true
+				break
+			}
+		}
+		if nested {
+			continue
+		}
+
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable entries are skipped rather than failing the whole listing
+				if entry != nil && entry.IsDir() && path != root {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			decision, err := cfg.CheckPath(path)
+			if err != nil || !decision.Allowed {
+				if entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			seen++
+			if seen <= offset {
+				return nil
+			}
+			if len(resources) == limit {
+				more = true
+				return errDone
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			name, err := filepath.Rel(root, path)
+			if err != nil {
+				name = path
+			}
+
+			resource := map[string]interface{}{
+				"uri":  fileURI(path),
+				"name": filepath.ToSlash(name),
+				"size": info.Size(),
+			}
+			if mimeType := mimeTypeOf(path); mimeType != "" {
+				resource["mimeType"] = mimeType
+			}
+			resources = append(resources, resource)
+			return nil
+		})
+		if errors.Is(err, errDone) {
+			break
+		}
+	}
+
+	return resources, more
+}
+
+// ReadResourceArgs are the arguments of a resources/read request. Reads pass through
+// the same middleware chain as tool calls, with these as the invocation's Args.
+type ReadResourceArgs struct {
+	URI string `json:"uri"`
+}
+
+// Paths returns the file the URI names for the path policy check
+func (a ReadResourceArgs) Paths() []tools.PathArg {
+	path, ok := localPath(a.URI)
+	if !ok {
+		return nil
+	}
+	return []tools.PathArg{{Path: path, What: "resource"}}
+}
+
+// resourceReader stands in for the tool in the invocation of a resources/read request
+type resourceReader struct{}
+
+func (resourceReader) Name() string {
+	return "resources/read"
+}
+
+func (resourceReader) Description() string {
+	return "Read a file under the allowed paths as an MCP resource"
+}
+
+func (resourceReader) Title() string {
+	return "Read Resource"
+}
+
+func (resourceReader) Annotations() tools.Annotations {
+	return tools.Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
+// readResource returns the contents of the file a resource URI names: as text if it
+// is valid UTF-8, and base64-encoded otherwise. Reads go through the middleware chain,
+// so they are authorized, limited, logged and audited like tool calls.
+func (s *Server) readResource(sess *session, uri string) (interface{}, error) {
+	started := time.Now()
+	reader := resourceReader{}
+
+	ctx, requestID, done, err := s.beginCall(reader.Name(), sess.id)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	defer done()
+
+	call := &tools.Call{RequestID: requestID, Session: sess.id, Tool: reader.Name(), Config: s.configFor(sess.id)}
+	ctx = tools.WithCall(ctx, call)
+
+	// The contents pass through the chain as a text result, so that output limits
+	// see their size and middleware can inspect or replace them
+	read := func(ctx context.Context, inv *Invocation) (*mcp.ToolResponse, error) {
+		args, ok := inv.Args.(ReadResourceArgs)
+		if !ok {
+			return nil, utils.NewToolError(utils.ErrInternal, "Arguments for %s were replaced with a %T", reader.Name(), inv.Args)
+		}
+		contents, err := readResourceContents(ctx, args.URI)
+		if err != nil {
+			return nil, err
+		}
+		return utils.CreateSuccessResponse(map[string]interface{}{"contents": []map[string]interface{}{contents}}), nil
+	}
+
+	resp, err := s.chain(read)(ctx, &Invocation{
+		Tool:    reader,
+		Call:    call,
+		Args:    ReadResourceArgs{URI: uri},
+		Peer:    sess.peer,
+		Started: started,
+	})
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	if resp == nil || len(resp.Content) != 1 || resp.Content[0].TextContent == nil || !json.Valid([]byte(resp.Content[0].TextContent.Text)) {
+		return nil, resourceError(utils.NewToolError(utils.ErrInternal, "The result of reading %s was replaced with one that isn't resource contents", uri))
+	}
+	return json.RawMessage(resp.Content[0].TextContent.Text), nil
+}
+
+// readResourceContents reads the file behind uri into a resource contents entry.
+// The path policy has already been checked by the middleware chain.
+func readResourceContents(ctx context.Context, uri string) (map[string]interface{}, error) {
+	path, err := resourceFile(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, resourceFileError(err, uri)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, resourceFileError(err, uri)
+	}
+	if info.IsDir() {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "%s is a directory", uri)
+	}
+	if info.Size() > maxResourceSize {
+		return nil, utils.NewToolError(utils.ErrTooLarge, "%s is %d bytes, more than the %d byte limit for resources", uri, info.Size(), maxResourceSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxResourceSize+1))
+	if err != nil {
+		return nil, resourceFileError(err, uri)
+	}
+	if len(data) > maxResourceSize {
+		return nil, utils.NewToolError(utils.ErrTooLarge, "%s is more than the %d byte limit for resources", uri, maxResourceSize)
+	}
+	tools.CallFromContext(ctx).AddBytesRead(int64(len(data)))
+
+	// The version's hash can be passed to write_file as expected_hash
+	contents := map[string]interface{}{"uri": uri, "_meta": tools.ContentVersion(path, info, data)}
+	mimeType := mimeTypeOf(path)
+	if utf8.Valid(data) {
+		if mimeType == "" {
+			mimeType = "text/plain"
+		}
+		contents["text"] = string(data)
+	} else {
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		contents["blob"] = base64.StdEncoding.EncodeToString(data)
+	}
+	contents["mimeType"] = mimeType
+
+	return contents, nil
+}
+
+// resourcePath returns the file a resource URI names, provided cfg allows it
+func resourcePath(ctx context.Context, cfg *config.ServerConfig, uri string) (string, error) {
+	path, err := resourceFile(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tools.RequirePath(ctx, cfg, path, "resource"); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// resourceFile returns the file a resource URI names
+func resourceFile(uri string) (string, error) {
+	path, ok := localPath(uri)
+	if !ok {
+		return "", utils.NewToolError(utils.ErrInvalidArgs, "Unsupported resource URI %q; expected file:///absolute/path", uri)
+	}
+	return path, nil
+}
+
+// resourceFileError classifies an error reading a resource's file
+func resourceFileError(err error, uri string) *utils.ToolError {
+	if errors.Is(err, os.ErrNotExist) {
+		return utils.NewToolError(utils.ErrNotFound, "Resource %s does not exist", uri)
+	}
+	return utils.NewToolError(utils.ErrIO, "Error reading %s: %v", uri, err)
+}
+
+// resourceError converts a tool error into the JSON-RPC error sent for a resource request
+func resourceError(err error) error {
+	toolErr := utils.AsToolError(err)
+	switch toolErr.Code {
+	case utils.ErrNotFound:
+		return &rpcError{code: resourceNotFound, message: toolErr.Message}
+	case utils.ErrInvalidArgs, utils.ErrPathDenied, utils.ErrTooLarge:
+		return &rpcError{code: -32602, message: toolErr.Message}
+	}
+	return &rpcError{code: -32603, message: toolErr.Message}
+}
+
+// fileURI returns the file:// URI of an absolute path
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// mimeTypeOf guesses a file's MIME type from its extension, without parameters
+func mimeTypeOf(path string) string {
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(filepath.Ext(path)), ";")
+	return mimeType
+}
+
+// resourceCursor is the position in the listing a resources/list cursor points to
+type resourceCursor struct {
+	Offset int `json:"offset"`
+}
+
+func encodeCursor(offset int) string {
+	data, _ := json.Marshal(resourceCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	var decoded resourceCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &decoded)
+	}
+	if err != nil || decoded.Offset < 0 {
+		return 0, invalidParams("invalid cursor %q", cursor)
+	}
+	return decoded.Offset, nil
+}
+
+// resourceWatcher polls the files a session has subscribed to and notifies the
+// client when one changes, appears or disappears
+type resourceWatcher struct {
+	ext *extendedTransport
+
+	mu         sync.Mutex
+	subscribed map[string]*watchedFile
+	started    bool
+	stopped    bool
+	stop       chan struct{}
+}
+
+// watchedFile is a subscribed file and what it looked like when last checked
+type watchedFile struct {
+	path    string
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func newResourceWatcher(ext *extendedTransport) *resourceWatcher {
+	return &resourceWatcher{
+		ext:        ext,
+		subscribed: make(map[string]*watchedFile),
+		stop:       make(chan struct{}),
+	}
+}
+
+// subscribe starts watching path for changes, reported under uri
+func (w *resourceWatcher) subscribe(uri, path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return
+	}
+
+	file := &watchedFile{path: path}
+	file.refresh()
+	w.subscribed[uri] = file
+
+	if !w.started {
+		w.started = true
+		go w.poll()
+	}
+}
+
+// unsubscribe stops watching uri
+func (w *resourceWatcher) unsubscribe(uri string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.subscribed, uri)
+}
+
+// retain stops watching the files keep rejects, returning their URIs. It is safe to
+// call on a nil watcher.
+func (w *resourceWatcher) retain(keep func(path string) bool) []string {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var dropped []string
+	for uri, file := range w.subscribed {
+		if !keep(file.path) {
+			delete(w.subscribed, uri)
+			dropped = append(dropped, uri)
+		}
+	}
+	return dropped
+}
+
+// close stops the watcher; it is safe to call on a nil watcher and more than once
+func (w *resourceWatcher) close() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stop)
+	}
+}
+
+// poll checks the subscribed files until the watcher is closed
+func (w *resourceWatcher) poll() {
+	ticker := time.NewTicker(resourcePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		w.mu.Lock()
+		var changed []string
+		for uri, file := range w.subscribed {
+			if file.refresh() {
+				changed = append(changed, uri)
+			}
+		}
+		w.mu.Unlock()
+
+		for _, uri := range changed {
+			if err := w.ext.notify("notifications/resources/updated", map[string]interface{}{"uri": uri}); err != nil {
+				slog.Warn("Failed to send resource update", "uri", uri, "error", err)
+			}
+		}
+	}
+}
+
+// refresh records the file's current state and reports whether it changed
+func (f *watchedFile) refresh() bool {
+	info, err := os.Stat(f.path)
+	exists := err == nil
+
+	var size int64
+	var modTime time.Time
+	if exists {
+		size = info.Size()
+		modTime = info.ModTime()
+	}
+
+	changed := exists != f.exists || size != f.size || !modTime.Equal(f.modTime)
+	f.exists, f.size, f.modTime = exists, size, modTime
+	return changed
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-server/internal/config"
+)
+
+// resourceResponse is the parts of a JSON-RPC response the resource tests look at
+type resourceResponse struct {
+	Method string `json:"method"`
+	Result struct {
+		Resources []struct {
+			URI      string `json:"uri"`
+			Name     string `json:"name"`
+			MimeType string `json:"mimeType"`
+			Size     int64  `json:"size"`
+		} `json:"resources"`
+		NextCursor string `json:"nextCursor"`
+		Contents   []struct {
+			URI      string `json:"uri"`
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Blob     string `json:"blob"`
+			Meta     struct {
+				SHA256 string `json:"sha256"`
+			} `json:"_meta"`
+		} `json:"contents"`
+	} `json:"result"`
+	Params struct {
+		URI string `json:"uri"`
+	} `json:"params"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// startResourceServer serves a workspace with a few files, one of them in a denied
+// directory. configure, if not nil, can change the server further before it starts.
+func startResourceServer(t *testing.T, configure func(*Server)) (string, net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.txt":        "hello\n",
+		"sub/b.bin":    "\xff\xfe\x00binary",
+		"secret/c.txt": "do not list",
+	})
+
+	_, socketPath := startUnixServerWith(t, &config.TransportConfig{}, func(srv *Server) {
+		srv.Config = &config.ServerConfig{AllowedPaths: []string{root}, DenyListPaths: []string{"secret"}}
+		if configure != nil {
+			configure(srv)
+		}
+	})
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return root, conn, bufio.NewReader(conn)
+}
+
+// writeFiles creates files under root, keyed by slash-separated relative path
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// resourceRequest sends a request and parses its response
+func resourceRequest(t *testing.T, conn net.Conn, reader *bufio.Reader, request string) resourceResponse {
+	t.Helper()
+
+	line := roundTrip(t, conn, reader, request)
+	var parsed resourceResponse
+	if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", line, err)
+	}
+	return parsed
+}
+
+func TestServer_ResourcesListAndRead(t *testing.T) {
+	root, conn, reader := startResourceServer(t, nil)
+
+	response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	var initialize struct {
+		Result struct {
+			Capabilities struct {
+				Resources map[string]bool `json:"resources"`
+			} `json:"capabilities"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(response), &initialize); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", response, err)
+	}
+	if !initialize.Result.Capabilities.Resources["subscribe"] {
+		t.Errorf("Expected the resources capability with subscribe, got: %s", response)
+	}
+
+	// Clients commonly leave out params when there is no cursor
+	list := resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)
+	names := map[string]int64{}
+	for _, resource := range list.Result.Resources {
+		names[resource.Name] = resource.Size
+	}
+	if len(names) != 2 || names["a.txt"] != 6 || names["sub/b.bin"] != 9 {
+		t.Fatalf("Expected a.txt and sub/b.bin but not the denied file, got %+v", list.Result.Resources)
+	}
+
+	textURI := fileURI(filepath.Join(root, "a.txt"))
+	read := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":%q}}`, textURI))
+	if len(read.Result.Contents) != 1 || read.Result.Contents[0].Text != "hello\n" || read.Result.Contents[0].MimeType != "text/plain" {
+		t.Errorf("Expected a.txt as text, got %+v", read.Result.Contents)
+	}
+	if sum := sha256.Sum256([]byte("hello\n")); len(read.Result.Contents) == 1 && read.Result.Contents[0].Meta.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the hash of a.txt, got %+v", read.Result.Contents[0].Meta)
+	}
+
+	binaryURI := fileURI(filepath.Join(root, "sub", "b.bin"))
+	read = resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":4,"method":"resources/read","params":{"uri":%q}}`, binaryURI))
+	if len(read.Result.Contents) != 1 || read.Result.Contents[0].Blob != base64.StdEncoding.EncodeToString([]byte("\xff\xfe\x00binary")) {
+		t.Errorf("Expected b.bin as a base64 blob, got %+v", read.Result.Contents)
+	}
+
+	deniedURI := fileURI(filepath.Join(root, "secret", "c.txt"))
+	read = resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":5,"method":"resources/read","params":{"uri":%q}}`, deniedURI))
+	if read.Error == nil || read.Error.Code != -32602 {
+		t.Errorf("Expected the denied file to be refused, got %+v", read)
+	}
+
+	missingURI := fileURI(filepath.Join(root, "missing.txt"))
+	read = resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":6,"method":"resources/read","params":{"uri":%q}}`, missingURI))
+	if read.Error == nil || read.Error.Code != resourceNotFound {
+		t.Errorf("Expected a missing file to be reported as not found, got %+v", read)
+	}
+
+	templates := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":7,"method":"resources/templates/list","params":{}}`)
+	if !strings.Contains(templates, `"uriTemplate":"file:///{path}"`) {
+		t.Errorf("Expected the file template, got: %s", templates)
+	}
+}
+
+func TestServer_ResourcesListPaginates(t *testing.T) {
+	previous := resourcePageSize
+	resourcePageSize = 1
+	t.Cleanup(func() { resourcePageSize = previous })
+
+	_, conn, reader := startResourceServer(t, nil)
+
+	var names []string
+	cursor := ""
+	for id := 1; id < 10; id++ {
+		list := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/list","params":{"cursor":%q}}`, id, cursor))
+		if list.Error != nil {
+			t.Fatalf("Listing failed: %+v", list.Error)
+		}
+		for _, resource := range list.Result.Resources {
+			names = append(names, resource.Name)
+		}
+		cursor = list.Result.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if len(names) != 2 || names[0] == names[1] {
+		t.Errorf("Expected two distinct files over two pages, got %v", names)
+	}
+
+	list := resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":99,"method":"resources/list","params":{"cursor":"not a cursor"}}`)
+	if list.Error == nil || list.Error.Code != -32602 {
+		t.Errorf("Expected an invalid cursor to be refused, got %+v", list)
+	}
+}
+
+func TestServer_ResourceSubscriptionNotifiesChanges(t *testing.T) {
+	previous := resourcePollInterval
+	resourcePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { resourcePollInterval = previous })
+
+	root, conn, reader := startResourceServer(t, nil)
+
+	path := filepath.Join(root, "a.txt")
+	uri := fileURI(path)
+	subscribe := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":%q}}`, uri))
+	if subscribe.Error != nil {
+		t.Fatalf("Subscribe failed: %+v", subscribe.Error)
+	}
+
+	if err := os.WriteFile(path, []byte("hello, world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Expected an update notification: %v", err)
+	}
+
+	var notification resourceResponse
+	if err := json.Unmarshal([]byte(line), &notification); err != nil {
+		t.Fatalf("Failed to parse notification %q: %v", line, err)
+	}
+	if notification.Method != "notifications/resources/updated" || notification.Params.URI != uri {
+		t.Errorf("Expected an update for %s, got: %s", uri, line)
+	}
+
+	denied := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":%q}}`, fileURI(filepath.Join(root, "secret", "c.txt"))))
+	if denied.Error == nil {
+		t.Errorf("Expected subscribing to a denied file to fail")
+	}
+}
+
+func TestServer_ResourceReadsPassThroughMiddleware(t *testing.T) {
+	var authorized []string
+	root, conn, reader := startResourceServer(t, func(srv *Server) {
+		srv.Use(Auth(func(ctx context.Context, inv *Invocation) error {
+			args, ok := inv.Args.(ReadResourceArgs)
+			if !ok {
+				return nil
+			}
+			authorized = append(authorized, inv.Call.Tool)
+			if strings.HasSuffix(args.URI, "/b.bin") {
+				return errors.New("not for you")
+			}
+			return nil
+		}), OutputBudget(1<<10))
+	})
+
+	read := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":%q}}`, fileURI(filepath.Join(root, "a.txt"))))
+	if len(read.Result.Contents) != 1 || read.Result.Contents[0].Text != "hello\n" {
+		t.Errorf("Expected a.txt to be read, got %+v", read)
+	}
+
+	read = resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":%q}}`, fileURI(filepath.Join(root, "sub", "b.bin"))))
+	if read.Error == nil || !strings.Contains(read.Error.Message, "not for you") {
+		t.Errorf("Expected the read to be refused by Auth, got %+v", read)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "big.txt"), []byte(strings.Repeat("x", 2<<10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	read = resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":%q}}`, fileURI(filepath.Join(root, "big.txt"))))
+	if read.Error == nil || read.Error.Code != -32602 {
+		t.Errorf("Expected the read to be refused by OutputBudget, got %+v", read)
+	}
+
+	if len(authorized) != 3 || authorized[0] != "resources/read" {
+		t.Errorf("Expected Auth to see three resources/read calls, got %v", authorized)
+	}
+}
+
+func TestServer_NoResources(t *testing.T) {
+	root, conn, reader := startResourceServer(t, func(srv *Server) {
+		srv.NoResources = true
+	})
+
+	read := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":%q}}`, fileURI(filepath.Join(root, "a.txt"))))
+	if read.Error == nil || len(read.Result.Contents) != 0 {
+		t.Errorf("Expected resources to be unavailable, got %+v", read)
+	}
+}
+
+func TestAddMissingParams(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"resources/list"}` + "\n", `{"id":1,"jsonrpc":"2.0","method":"resources/list","params":{}}` + "\n"},
+		{`{"jsonrpc":"2.0","method":"notifications/initialized","params":null}`, `{"jsonrpc":"2.0","method":"notifications/initialized","params":{}}`},
+		{`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"cursor":"x"}}`, `{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"cursor":"x"}}`},
+		{`{"jsonrpc":"2.0","id":1,"result":{}}`, `{"jsonrpc":"2.0","id":1,"result":{}}`},
+		{`not json`, `not json`},
+	}
+
+	for _, tt := range tests {
+		if got := string(addMissingParams([]byte(tt.line))); got != tt.expected {
+			t.Errorf("addMissingParams(%q) = %q, want %q", tt.line, got, tt.expected)
+		}
+	}
+}
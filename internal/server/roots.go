@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/tools"
+)
+
+// rootsTimeout is how long the server waits for the client to answer roots/list
+var rootsTimeout = 10 * time.Second
+
+// enableRoots makes a session's path policy follow the roots its client declares.
+// The roots are requested once the client has initialized and again whenever it
+// reports that they changed.
+func (s *Server) enableRoots(sess *session) {
+	if s.Roots == config.RootsIgnore {
+		return
+	}
+
+	sess.rootsKnown = make(chan struct{})
+
+	// Until the client declares its roots, roots-only mode allows nothing
+	if s.Roots == config.RootsOnly && s.Config != nil {
+		sess.policy.Store(s.Config.WithRoots(nil, config.RootsOnly))
+	}
+
+	sess.ext.observe("initialize", func(params json.RawMessage) {
+		var request struct {
+			Capabilities struct {
+				Roots *json.RawMessage `json:"roots"`
+			} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(params, &request); err != nil {
+			return
+		}
+
+		supported := request.Capabilities.Roots != nil
+		sess.rootsSupported.Store(supported)
+		if !supported {
+			sess.markRootsKnown()
+			if s.Roots == config.RootsOnly {
+				slog.Warn("Client does not declare roots; no paths are allowed in roots-only mode", "session", sess.id)
+			}
+		}
+	})
+
+	refresh := func(json.RawMessage) {
+		if sess.rootsSupported.Load() {
+			s.refreshRoots(sess)
+			sess.markRootsKnown()
+		}
+	}
+	sess.ext.onNotification("notifications/initialized", refresh)
+	sess.ext.onNotification("notifications/roots/list_changed", refresh)
+}
+
+// refreshRoots asks the client for its roots and rebuilds the session's path policy
+func (s *Server) refreshRoots(sess *session) {
+	if s.Config == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.baseCtx, rootsTimeout)
+	defer cancel()
+
+	result, err := sess.ext.request(ctx, "roots/list", map[string]interface{}{})
+	if err != nil {
+		slog.Warn("Failed to list client roots", "session", sess.id, "error", err)
+
+		// A client that declares roots but can't list them is allowed nothing,
+		// rather than the whole server configuration, until it can
+		if sess.policy.Load() == nil {
+			sess.policy.Store(s.Config.WithRoots(nil, s.Roots))
+		}
+		return
+	}
+
+	var listed struct {
+		Roots []struct {
+			URI  string `json:"uri"`
+			Name string `json:"name"`
+		} `json:"roots"`
+	}
+	if err := json.Unmarshal(result, &listed); err != nil {
+		slog.Warn("Invalid roots/list result", "session", sess.id, "error", err)
+		return
+	}
+
+	var roots []string
+	for _, root := range listed.Roots {
+		path, ok := localPath(root.URI)
+		if !ok {
+			slog.Warn("Ignoring client root that is not a local directory", "session", sess.id, "uri", root.URI)
+			continue
+		}
+		roots = append(roots, path)
+	}
+
+	policy := s.Config.WithRoots(roots, s.Roots)
+
+	// Subscriptions made under the old roots must not outlive them. They are dropped
+	// before the policy changes, so a request that sees the new roots sees them gone.
+	dropped := sess.resources.retain(func(path string) bool {
+		return tools.RequirePath(context.Background(), policy, path, "resource") == nil
+	})
+	if len(dropped) > 0 {
+		slog.Info("Dropped resource subscriptions outside the client's roots", "session", sess.id, "uris", dropped)
+	}
+
+	sess.policy.Store(policy)
+	slog.Info("Client roots updated", "session", sess.id, "roots", roots, "allowed_paths", policy.AllowedPaths)
+}
+
+// localPath returns the absolute path a file:// URI names
+func localPath(uri string) (string, bool) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" || (parsed.Host != "" && parsed.Host != "localhost") {
+		return "", false
+	}
+
+	path := filepath.FromSlash(parsed.Path)
+	if !filepath.IsAbs(path) {
+		return "", false
+	}
+	return filepath.Clean(path), true
+}
+
+// markRootsKnown releases the calls waiting for the session's first roots
+func (sess *session) markRootsKnown() {
+	if sess.rootsKnown != nil {
+		sess.rootsKnownOnce.Do(func() { close(sess.rootsKnown) })
+	}
+}
+
+// awaitRoots waits for the first roots of a session whose client declares them, so
+// that a call made before they arrive doesn't run under the whole server
+// configuration. It reports false if they don't arrive within rootsTimeout.
+func awaitRoots(sess *session) bool {
+	if sess.rootsKnown == nil || !sess.rootsSupported.Load() {
+		return true
+	}
+
+	timer := time.NewTimer(rootsTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-sess.rootsKnown:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// configFor returns the path policy of a session: the server's configuration,
+// narrowed to the client's roots once they are known. While a client that declares
+// roots has yet to send them, it waits for them, and allows nothing if they don't come.
+func (s *Server) configFor(sessionID string) *config.ServerConfig {
+	s.mu.Lock()
+	sess := s.sessions[sessionID]
+	s.mu.Unlock()
+
+	if sess != nil {
+		if !awaitRoots(sess) && s.Config != nil {
+			slog.Warn("Client roots did not arrive in time; allowing no paths", "session", sess.id)
+			return s.Config.WithRoots(nil, s.Roots)
+		}
+		if policy := sess.policy.Load(); policy != nil {
+			return policy
+		}
+	}
+	return s.Config
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"mcp-server/internal/config"
+)
+
+func TestIntersectPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		roots    []string
+		expected []string
+	}{
+		{"root inside allowed path", []string{"/home/user"}, []string{"/home/user/project"}, []string{"/home/user/project"}},
+		{"allowed path inside root", []string{"/home/user/project/src"}, []string{"/home/user"}, []string{"/home/user/project/src"}},
+		{"disjoint", []string{"/srv"}, []string{"/home/user"}, nil},
+		{"sibling with common prefix", []string{"/home/user/app"}, []string{"/home/user/app2"}, nil},
+		{"duplicates collapse", []string{"/a", "/a/b"}, []string{"/a/b"}, []string{"/a/b"}},
+		{"no roots", []string{"/a"}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.IntersectPaths(tt.allowed, tt.roots); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("IntersectPaths(%v, %v) = %v, want %v", tt.allowed, tt.roots, got, tt.expected)
+			}
+		})
+	}
+}
+
+// initializeWithRoots initializes a session as a client that declares roots and answers
+// the server's first roots/list request with roots
+func initializeWithRoots(t *testing.T, conn net.Conn, reader *bufio.Reader, roots ...string) {
+	t.Helper()
+
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{"roots":{"listChanged":true}},"clientInfo":{"name":"test","version":"1"}}}`)
+	answerRootsList(t, conn, reader, `{"jsonrpc":"2.0","method":"notifications/initialized"}`, roots...)
+}
+
+// answerRootsList sends message, expects the server to ask for the roots in return
+// and answers with roots
+func answerRootsList(t *testing.T, conn net.Conn, reader *bufio.Reader, message string, roots ...string) {
+	t.Helper()
+
+	line := roundTrip(t, conn, reader, message)
+	var request struct {
+		ID     int64  `json:"id"`
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal([]byte(line), &request); err != nil || request.Method != "roots/list" {
+		t.Fatalf("Expected a roots/list request, got %q", line)
+	}
+
+	listed := make([]map[string]string, len(roots))
+	for i, root := range roots {
+		listed[i] = map[string]string{"uri": fileURI(root), "name": filepath.Base(root)}
+	}
+	result, _ := json.Marshal(map[string]interface{}{"roots": listed})
+	if _, err := fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"result":%s}`+"\n", request.ID, result); err != nil {
+		t.Fatalf("Failed to answer roots/list: %v", err)
+	}
+}
+
+// listedNames polls resources/list until it returns want, since the policy is updated
+// in the background after the roots arrive
+func listedNames(t *testing.T, conn net.Conn, reader *bufio.Reader, want []string) {
+	t.Helper()
+
+	var names []string
+	deadline := time.Now().Add(5 * time.Second)
+	for id := 100; time.Now().Before(deadline); id++ {
+		list := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"resources/list"}`, id))
+		names = nil
+		for _, resource := range list.Result.Resources {
+			names = append(names, resource.Name)
+		}
+		if reflect.DeepEqual(names, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected resources %v, got %v", want, names)
+}
+
+func TestServer_RootsNarrowAllowedPaths(t *testing.T) {
+	root, conn, reader := startResourceServer(t, nil)
+
+	initializeWithRoots(t, conn, reader, filepath.Join(root, "sub"))
+	listedNames(t, conn, reader, []string{"b.bin"})
+
+	// The client's workspace changes; the policy follows it
+	answerRootsList(t, conn, reader, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`, root)
+	listedNames(t, conn, reader, []string{"a.txt", "sub/b.bin"})
+
+	// A root outside the allowed paths grants nothing
+	answerRootsList(t, conn, reader, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`, t.TempDir())
+	listedNames(t, conn, reader, nil)
+}
+
+func TestServer_RootsOnly(t *testing.T) {
+	_, conn, reader := startResourceServer(t, func(srv *Server) {
+		srv.Roots = config.RootsOnly
+	})
+
+	// Nothing is allowed before the client declares its roots
+	listedNames(t, conn, reader, nil)
+
+	// The configured allowed paths don't limit the roots
+	workspace := t.TempDir()
+	writeFiles(t, workspace, map[string]string{"main.go": "package main\n"})
+
+	initializeWithRoots(t, conn, reader, workspace)
+	listedNames(t, conn, reader, []string{"main.go"})
+}
+
+func TestServer_RootsChangeDropsSubscriptions(t *testing.T) {
+	previous := resourcePollInterval
+	resourcePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { resourcePollInterval = previous })
+
+	root, conn, reader := startResourceServer(t, nil)
+	initializeWithRoots(t, conn, reader, root)
+	listedNames(t, conn, reader, []string{"a.txt", "sub/b.bin"})
+
+	path := filepath.Join(root, "a.txt")
+	subscribe := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"resources/subscribe","params":{"uri":%q}}`, fileURI(path)))
+	if subscribe.Error != nil {
+		t.Fatalf("Subscribe failed: %+v", subscribe.Error)
+	}
+
+	// a.txt is outside the new roots, so its subscription goes with the old ones
+	answerRootsList(t, conn, reader, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`, filepath.Join(root, "sub"))
+	listedNames(t, conn, reader, []string{"b.bin"})
+
+	if err := os.WriteFile(path, []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * resourcePollInterval)
+
+	// The next line is the response, not an update for a.txt
+	list := resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":3,"method":"resources/list"}`)
+	if list.Method != "" {
+		t.Fatalf("Expected no update for a file outside the roots, got %s %s", list.Method, list.Params.URI)
+	}
+
+	denied := resourceRequest(t, conn, reader, fmt.Sprintf(`{"jsonrpc":"2.0","id":4,"method":"resources/subscribe","params":{"uri":%q}}`, fileURI(path)))
+	if denied.Error == nil {
+		t.Errorf("Expected subscribing to a file outside the roots to fail")
+	}
+}
+
+func TestServer_CallsWaitForFirstRoots(t *testing.T) {
+	root, conn, reader := startResourceServer(t, nil)
+
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{"roots":{"listChanged":true}},"clientInfo":{"name":"test","version":"1"}}}`)
+	line := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	var request struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(line), &request); err != nil {
+		t.Fatalf("Expected a roots/list request, got %q", line)
+	}
+
+	// A list sent before the roots are answered waits for them rather than seeing
+	// every allowed path
+	if _, err := fmt.Fprintln(conn, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"result":{"roots":[{"uri":%q}]}}`+"\n", request.ID, fileURI(filepath.Join(root, "sub"))); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	var list resourceResponse
+	if err := json.Unmarshal([]byte(line), &list); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", line, err)
+	}
+	if len(list.Result.Resources) != 1 || list.Result.Resources[0].Name != "b.bin" {
+		t.Errorf("Expected only the file inside the root, got: %s", line)
+	}
+}
+
+func TestServer_RootsThatNeverArriveAllowNothing(t *testing.T) {
+	previous := rootsTimeout
+	rootsTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { rootsTimeout = previous })
+
+	_, conn, reader := startResourceServer(t, nil)
+
+	// The client declares roots but never sends notifications/initialized, so the
+	// server never asks for them
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{"roots":{}},"clientInfo":{"name":"test","version":"1"}}}`)
+
+	list := resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)
+	if list.Error != nil || len(list.Result.Resources) != 0 {
+		t.Errorf("Expected no resources while the roots are unknown, got %+v", list)
+	}
+}
+
+func TestServer_RootsListErrorAllowsNothing(t *testing.T) {
+	_, conn, reader := startResourceServer(t, nil)
+
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{"roots":{}},"clientInfo":{"name":"test","version":"1"}}}`)
+	line := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	var request struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(line), &request); err != nil {
+		t.Fatalf("Expected a roots/list request, got %q", line)
+	}
+	if _, err := fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"error":{"code":-32601,"message":"no roots"}}`+"\n", request.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	list := resourceRequest(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)
+	if list.Error != nil || len(list.Result.Resources) != 0 {
+		t.Errorf("Expected no resources after roots/list failed, got %+v", list)
+	}
+}
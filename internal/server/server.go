@@ -1,22 +1,123 @@
 package server
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	mcp "github.com/metoro-io/mcp-golang"
+	"github.com/metoro-io/mcp-golang/transport"
 	"github.com/metoro-io/mcp-golang/transport/stdio"
+	"mcp-server/internal/audit"
+	"mcp-server/internal/budget"
 	"mcp-server/internal/config"
+	"mcp-server/internal/prompts"
 	"mcp-server/internal/tools"
 )
 
 // Server wraps the MCP server functionality
 type Server struct {
-	mcpServer *mcp.Server
 	done      chan struct{}
+	doneOnce  sync.Once
 	Config    *config.ServerConfig
+	Transport *config.TransportConfig
+
+	// Audit, when set, receives an entry for every tool invocation
+	Audit *audit.Log
+
+	// Budget, when set, limits what each session may do
+	Budget *budget.Tracker
+
+	// ReadOnly, when set before tools are registered, leaves out every tool that
+	// isn't annotated as read-only
+	ReadOnly bool
+
+	// Roots says how the roots each client declares narrow the allowed paths for
+	// its session. The zero value intersects them with the configured paths.
+	Roots config.RootsMode
+
+	// NoResources, when set, leaves out the resources capability, so the allowed
+	// files can only be reached through tools
+	NoResources bool
+
+	// Prompts are the prompt templates offered to clients; prompts whose tools are
+	// not registered are left out
+	Prompts []*prompts.Prompt
+
+	mu         sync.Mutex
+	tools      []tools.Tool
+	sessions   map[string]*session
+	nextID     int
+	listener   net.Listener
+	socketPath string
+
+	// In-flight call tracking, used to drain calls on shutdown
+	baseCtx    context.Context
+	abort      context.CancelFunc
+	draining   bool
+	calls      map[uint64]*inflightCall
+	nextCallID uint64
+	wg         sync.WaitGroup
+	stopOnce   sync.Once
+	report     *ShutdownReport
+
+	// middleware added with Use, run after the built-in middleware
+	middleware []Middleware
+
+	// panics counts recovered tool panics by tool name
+	panics map[string]uint64
+}
+
+// session is a single MCP conversation with one client, backed by the server's shared tool registry
+type session struct {
+	id        string
+	mcpServer *mcp.Server
+	ext       *extendedTransport
+	conn      net.Conn
+	peer      *PeerCredentials
+
+	// logLevel is the minimum slog level forwarded to the client as notifications/message
+	logLevel atomic.Int64
+
+	// resources watches the files the client subscribed to
+	resources *resourceWatcher
+
+	// policy, once set, replaces the server's configuration for this session's path
+	// checks; it follows the roots the client declares
+	policy         atomic.Pointer[config.ServerConfig]
+	rootsSupported atomic.Bool
+
+	// rootsKnown is closed once the first roots/list request has been answered or has
+	// failed. It is nil when roots are ignored.
+	rootsKnown     chan struct{}
+	rootsKnownOnce sync.Once
 }
 
+// ShutdownReport summarizes what happened to in-flight calls when the server stopped
+type ShutdownReport struct {
+	// Drained is the number of calls that finished on their own during the drain window
+	Drained int
+
+	// Aborted lists the calls that were still running at the deadline and had to be cancelled
+	Aborted []AbortedCall
+}
+
+// AbortedCall describes a tool call that was cancelled during shutdown
+type AbortedCall struct {
+	Tool    string
+	Session string
+	Running time.Duration
+}
+
+// abortGracePeriod is how long cancelled calls get to clean up after the drain deadline
+const abortGracePeriod = 2 * time.Second
+
 // NewServer creates a new MCP server instance with the default configuration
 func NewServer() (*Server, error) {
 	return NewServerWithConfig(config.NewConfigFromEnv())
@@ -24,63 +125,254 @@ func NewServer() (*Server, error) {
 
 // NewServerWithConfig creates a new MCP server instance with the provided configuration
 func NewServerWithConfig(cfg *config.ServerConfig) (*Server, error) {
-	// Create a stdio transport
-	transport := stdio.NewStdioServerTransport()
-
-	// Create a new MCP server
-	mcpServer := mcp.NewServer(transport)
+	baseCtx, abort := context.WithCancel(context.Background())
 
 	return &Server{
-		mcpServer: mcpServer,
 		done:      make(chan struct{}),
 		Config:    cfg,
+		Transport: config.NewTransportConfigFromEnv(),
+		sessions:  make(map[string]*session),
+		baseCtx:   baseCtx,
+		abort:     abort,
+		Prompts:   prompts.Builtin(),
+		calls:     make(map[uint64]*inflightCall),
+		panics:    make(map[string]uint64),
 	}, nil
 }
 
 // RegisterTool registers a tool with the MCP server
 func (s *Server) RegisterTool(tool tools.Tool) error {
-	// Get tool name, description, and handler function
+	// Get tool name and description
 	name := tool.Name()
-	description := tool.Description()
 
-	log.Printf("Registering tool: %s", name)
+	if s.ReadOnly && !tool.Annotations().ReadOnly {
+		slog.Info("Skipping tool in read-only mode", "tool", name)
+		return nil
+	}
+
+	slog.Info("Registering tool", "tool", name)
 
 	// Inject configuration into the tool if it implements ConfigAware
 	if configAware, ok := tool.(tools.ConfigAware); ok {
 		configAware.SetConfig(s.Config)
 	}
 
-	// Get the Execute method using reflection
-	toolValue := reflect.ValueOf(tool)
-	executeMethod := toolValue.MethodByName("Execute")
+	// Make sure the tool has an Execute method before accepting it
+	if !reflect.ValueOf(tool).MethodByName("Execute").IsValid() {
+		return fmt.Errorf("tool %s does not implement Execute", name)
+	}
 
-	// Register the tool with the MCP server
-	err := s.mcpServer.RegisterTool(name, description, executeMethod.Interface())
-	if err != nil {
-		return err
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tools = append(s.tools, tool)
+
+	// Sessions that are already running pick up the new tool immediately
+	for _, sess := range s.sessions {
+		if err := s.registerOn(sess, tool); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Start begins the MCP server
+// registerOn registers a tool with a single session's MCP server
+func (s *Server) registerOn(sess *session, tool tools.Tool) error {
+	return sess.mcpServer.RegisterTool(tool.Name(), tool.Description(), s.toolHandler(tool, sess))
+}
+
+// Start begins the MCP server on the configured transport
 func (s *Server) Start() error {
-	log.Printf("Server starting with configuration: Allowed paths: %v", s.Config.AllowedPaths)
+	slog.Info("Server starting", "allowed_paths", s.Config.AllowedPaths, "listen", s.Transport.Listen)
+
+	scheme, path, err := s.Transport.ParseListen()
+	if err != nil {
+		return err
+	}
+
+	if scheme == config.ListenUnix {
+		return s.startUnix(path)
+	}
+
+	// A stdio server has exactly one client; once it hangs up there is nothing left to serve
+	stdin := &hangupReader{r: os.Stdin, onHangup: func() {
+		slog.Info("Client closed stdin")
+		s.closeDone()
+	}}
+
+	sess, err := s.newSession(stdio.NewStdioServerTransportWithIO(newParamsReader(stdin), os.Stdout), nil, nil)
+	if err != nil {
+		return err
+	}
 
 	go func() {
-		if err := s.mcpServer.Serve(); err != nil {
-			log.Printf("Error in server: %v", err)
-			close(s.done)
+		if err := sess.mcpServer.Serve(); err != nil {
+			slog.Error("Error in server", "error", err)
+			s.closeDone()
 		}
 	}()
 
 	return nil
 }
 
-// Stop gracefully stops the MCP server
-func (s *Server) Stop() {
-	log.Println("Stopping MCP server...")
-	// Currently the mcp-golang library doesn't have a built-in way to stop the server,
-	// but we could implement one if needed by closing connections, etc.
-	close(s.done)
+// Done is closed once the server has stopped serving, either because Stop was
+// called or because the transport failed or the stdio client went away
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// closeDone closes the done channel exactly once
+func (s *Server) closeDone() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// newSession creates an MCP server for one client and registers every known tool on it.
+// The session is not served until the caller invokes Serve on it.
+func (s *Server) newSession(t transport.Transport, conn net.Conn, peer *PeerCredentials) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return nil, fmt.Errorf("server is shutting down")
+	}
+
+	s.nextID++
+	ext := newExtendedTransport(t)
+	sess := &session{
+		id:        fmt.Sprintf("session-%d", s.nextID),
+		mcpServer: mcp.NewServer(ext),
+		ext:       ext,
+		conn:      conn,
+		peer:      peer,
+	}
+	s.enableClientLogging(sess)
+	s.advertiseToolMetadata(sess)
+	s.enableToolCalls(sess)
+	if !s.NoResources {
+		s.enableResources(sess)
+	}
+	s.enableRoots(sess)
+	s.enablePrompts(sess)
+	s.enableCompletions(sess)
+
+	for _, tool := range s.tools {
+		if err := s.registerOn(sess, tool); err != nil {
+			return nil, fmt.Errorf("failed to register tool %s: %w", tool.Name(), err)
+		}
+	}
+
+	s.sessions[sess.id] = sess
+
+	return sess, nil
+}
+
+// endSession forgets a session and closes its connection, if any
+func (s *Server) endSession(sess *session) {
+	s.mu.Lock()
+	_, known := s.sessions[sess.id]
+	delete(s.sessions, sess.id)
+	s.mu.Unlock()
+
+	if !known {
+		return
+	}
+
+	sess.resources.close()
+	if sess.conn != nil {
+		sess.conn.Close()
+	}
+	if s.Budget != nil {
+		s.Budget.EndSession(sess.id)
+	}
+	slog.Info("Session ended", "session", sess.id)
+}
+
+// Stop gracefully stops the MCP server. New tool calls are refused immediately,
+// in-flight calls are given until ctx is done to finish, and whatever is still
+// running after that is cancelled. Stop is safe to call more than once; later
+// calls return the report of the first.
+func (s *Server) Stop(ctx context.Context) *ShutdownReport {
+	s.stopOnce.Do(func() {
+		s.report = s.shutdown(ctx)
+	})
+	return s.report
+}
+
+// shutdown performs the actual work of Stop
+func (s *Server) shutdown(ctx context.Context) *ShutdownReport {
+	slog.Info("Stopping MCP server")
+
+	s.mu.Lock()
+	s.draining = true
+	listener := s.listener
+	pending := len(s.calls)
+	s.mu.Unlock()
+
+	// Stop accepting new connections
+	if listener != nil {
+		listener.Close()
+		os.Remove(s.socketPath)
+	}
+
+	if pending > 0 {
+		slog.Info("Waiting for in-flight calls to finish", "pending", pending)
+	}
+
+	report := &ShutdownReport{}
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		report.Drained = pending
+	case <-ctx.Done():
+		// Cancel whatever is left; tools that honour their context kill child processes
+		// and remove partial output
+		s.mu.Lock()
+		now := time.Now()
+		for _, call := range s.calls {
+			report.Aborted = append(report.Aborted, AbortedCall{
+				Tool:    call.tool,
+				Session: call.session,
+				Running: now.Sub(call.started),
+			})
+		}
+		s.mu.Unlock()
+
+		report.Drained = pending - len(report.Aborted)
+		s.abort()
+
+		select {
+		case <-drained:
+		case <-time.After(abortGracePeriod):
+			slog.Warn("Some aborted calls did not return in time", "grace_period", abortGracePeriod)
+		}
+	}
+	s.abort()
+
+	for _, call := range report.Aborted {
+		slog.Warn("Aborted tool call", "tool", call.Tool, "session", call.Session, "running", call.Running.Round(time.Millisecond))
+	}
+
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		s.endSession(sess)
+	}
+
+	s.closeDone()
+
+	return report
 }
@@ -0,0 +1,501 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/config"
+	"mcp-server/internal/tools"
+	"mcp-server/internal/utils"
+)
+
+// startUnixServer starts a server listening on a socket inside a temporary directory
+func startUnixServer(t *testing.T, transportCfg *config.TransportConfig) (*Server, string) {
+	t.Helper()
+	return startUnixServerWith(t, transportCfg, nil)
+}
+
+// startUnixServerWith is startUnixServer with a chance to configure the server before it starts
+func startUnixServerWith(t *testing.T, transportCfg *config.TransportConfig, configure func(*Server)) (*Server, string) {
+	t.Helper()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("Peer credential checks are only supported on Linux")
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "mcp.sock")
+	transportCfg.Listen = "unix:" + socketPath
+
+	srv, err := NewServerWithConfig(config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	srv.Transport = transportCfg
+
+	if err := srv.RegisterTool(tools.NewShowFileTool()); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+
+	if configure != nil {
+		configure(srv)
+	}
+
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { srv.Stop(context.Background()) })
+
+	return srv, socketPath
+}
+
+// roundTrip sends a JSON-RPC request and returns the raw response line
+func roundTrip(t *testing.T, conn net.Conn, reader *bufio.Reader, request string) string {
+	t.Helper()
+
+	if _, err := conn.Write([]byte(request + "\n")); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return line
+}
+
+func TestServer_UnixSocket_SessionPerConnection(t *testing.T) {
+	_, socketPath := startUnixServer(t, &config.TransportConfig{})
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Expected socket file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// Two independent clients should each get a working session
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{}}`)
+
+		var parsed struct {
+			Result struct {
+				Tools []struct {
+					Name string `json:"name"`
+				} `json:"tools"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+			t.Fatalf("Failed to parse response %q: %v", response, err)
+		}
+
+		if len(parsed.Result.Tools) != 1 || parsed.Result.Tools[0].Name != "show_file" {
+			t.Errorf("Expected show_file to be listed, got: %s", response)
+		}
+	}
+}
+
+func TestServer_UnixSocket_RejectsDisallowedPeer(t *testing.T) {
+	// Only allow a UID that is guaranteed not to be ours
+	_, socketPath := startUnixServer(t, &config.TransportConfig{
+		AllowedUIDs: []int{os.Getuid() + 1},
+	})
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{}}` + "\n"))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err == nil || strings.TrimSpace(line) != "" {
+		t.Errorf("Expected connection to be closed without a response, got %q (err %v)", line, err)
+	}
+}
+
+func TestServer_UnixSocket_ModeLetsAllowedPeersConnect(t *testing.T) {
+	other := os.Getuid() + 1
+	tests := []struct {
+		name     string
+		cfg      *config.TransportConfig
+		expected os.FileMode
+	}{
+		{"owner only", &config.TransportConfig{}, 0600},
+		{"own uid", &config.TransportConfig{AllowedUIDs: []int{os.Getuid()}}, 0600},
+		{"groups", &config.TransportConfig{AllowedGIDs: []int{os.Getgid()}}, 0660},
+		{"other uid", &config.TransportConfig{AllowedUIDs: []int{other}}, 0666},
+		{"other uid and groups", &config.TransportConfig{AllowedUIDs: []int{other}, AllowedGIDs: []int{os.Getgid()}}, 0666},
+		{"several groups", &config.TransportConfig{AllowedGIDs: []int{os.Getgid(), os.Getgid() + 1}}, 0666},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, socketPath := startUnixServer(t, tt.cfg)
+
+			info, err := os.Stat(socketPath)
+			if err != nil {
+				t.Fatalf("Expected socket file to exist: %v", err)
+			}
+			if info.Mode().Perm() != tt.expected {
+				t.Errorf("Expected socket mode %o, got %o", tt.expected, info.Mode().Perm())
+			}
+		})
+	}
+}
+
+// blockingToolArgs defines the arguments for blockingTool
+type blockingToolArgs struct{}
+
+// blockingTool runs until its context is cancelled
+type blockingTool struct {
+	started chan struct{}
+}
+
+func (t *blockingTool) Name() string        { return "block" }
+func (t *blockingTool) Description() string { return "Blocks until cancelled" }
+func (t *blockingTool) Title() string       { return "Block" }
+func (t *blockingTool) Annotations() tools.Annotations {
+	return tools.Annotations{ReadOnly: true}
+}
+
+func (t *blockingTool) Execute(args blockingToolArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+func (t *blockingTool) ExecuteContext(ctx context.Context, args blockingToolArgs) (*mcp.ToolResponse, error) {
+	close(t.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestServer_Stop_AbortsInFlightCalls(t *testing.T) {
+	srv, socketPath := startUnixServer(t, &config.TransportConfig{})
+
+	tool := &blockingTool{started: make(chan struct{})}
+	if err := srv.RegisterTool(tool); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"block","arguments":{}}}` + "\n"))
+
+	select {
+	case <-tool.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tool call never started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report := srv.Stop(ctx)
+	if len(report.Aborted) != 1 || report.Aborted[0].Tool != "block" {
+		t.Fatalf("Expected the blocking call to be aborted, got %+v", report)
+	}
+
+	// Stopping again must not panic and returns the same report
+	if again := srv.Stop(context.Background()); again != report {
+		t.Errorf("Expected repeated Stop to return the original report")
+	}
+
+	select {
+	case <-srv.Done():
+	default:
+		t.Error("Expected Done to be closed after Stop")
+	}
+
+	// New calls are refused once shutdown has begun
+	if _, _, _, err := srv.beginCall("block", "session-test"); err == nil {
+		t.Error("Expected new calls to be refused after Stop")
+	}
+}
+
+// panickingToolArgs defines the arguments for panickingTool
+type panickingToolArgs struct {
+	Index int `json:"index"`
+}
+
+// panickingTool indexes past the end of a slice
+type panickingTool struct{}
+
+func (t *panickingTool) Name() string        { return "panic" }
+func (t *panickingTool) Description() string { return "Panics with an out-of-range index" }
+func (t *panickingTool) Title() string       { return "Panic" }
+func (t *panickingTool) Annotations() tools.Annotations {
+	return tools.Annotations{ReadOnly: true}
+}
+
+func (t *panickingTool) Execute(args panickingToolArgs) (*mcp.ToolResponse, error) {
+	values := []string{"only"}
+	return mcp.NewToolResponse(mcp.NewTextContent(values[args.Index])), nil
+}
+
+func TestServer_RecoversToolPanics(t *testing.T) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	srv, err := NewServerWithConfig(config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	handler := srv.toolHandler(&panickingTool{}, &session{id: "session-test"}).(func(panickingToolArgs) (*mcp.ToolResponse, error))
+
+	for i := 0; i < 2; i++ {
+		resp, err := handler(panickingToolArgs{Index: 5})
+		if resp != nil {
+			t.Errorf("Expected no response, got %+v", resp)
+		}
+
+		var toolErr *utils.ToolError
+		if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrInternal {
+			t.Fatalf("Expected an INTERNAL error, got: %v", err)
+		}
+	}
+
+	// The server keeps serving calls after a panic
+	if resp, err := handler(panickingToolArgs{Index: 0}); err != nil || resp == nil {
+		t.Fatalf("Expected the next call to succeed, got %v, %v", resp, err)
+	}
+
+	if counts := srv.PanicCounts(); counts["panic"] != 2 {
+		t.Errorf("Expected 2 recorded panics, got %v", counts)
+	}
+}
+
+func TestServer_ForwardsPolicyDenialsToClient(t *testing.T) {
+	srv, socketPath := startUnixServer(t, &config.TransportConfig{})
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(srv.ClientLogHandler(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	if !strings.Contains(response, `"logging":{}`) {
+		t.Errorf("Expected the logging capability to be declared, got: %s", response)
+	}
+
+	response = roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"warning"}}`)
+	if !strings.Contains(response, `"result":{}`) {
+		t.Errorf("Expected logging/setLevel to succeed, got: %s", response)
+	}
+
+	// The denial notification arrives before the tool's response
+	notification := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"show_file","arguments":{"file_path":"/definitely/not/allowed"}}}`)
+
+	var parsed struct {
+		Method string `json:"method"`
+		Params struct {
+			Level string                 `json:"level"`
+			Data  map[string]interface{} `json:"data"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(notification), &parsed); err != nil {
+		t.Fatalf("Failed to parse notification %q: %v", notification, err)
+	}
+
+	if parsed.Method != "notifications/message" || parsed.Params.Level != "warning" {
+		t.Fatalf("Expected a warning notification, got: %s", notification)
+	}
+	if parsed.Params.Data["path"] != "/definitely/not/allowed" {
+		t.Errorf("Expected the notification to name the denied path, got: %v", parsed.Params.Data)
+	}
+}
+
+func TestServer_ToolErrorsAreReportedWithIsError(t *testing.T) {
+	_, socketPath := startUnixServer(t, &config.TransportConfig{})
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+
+	// Keep warnings out of the way of the response
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"error"}}`)
+
+	response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"show_file","arguments":{"file_path":"/definitely/\"not\"/allowed"}}}`)
+
+	var parsed struct {
+		Result struct {
+			IsError bool `json:"isError"`
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", response, err)
+	}
+	if !parsed.Result.IsError || len(parsed.Result.Content) != 1 {
+		t.Fatalf("Expected an error result, got: %s", response)
+	}
+
+	var payload struct {
+		Success bool            `json:"success"`
+		Error   utils.ToolError `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(parsed.Result.Content[0].Text), &payload); err != nil {
+		t.Fatalf("Expected the error content to be valid JSON, got %q: %v", parsed.Result.Content[0].Text, err)
+	}
+	if payload.Success || payload.Error.Code != utils.ErrPathDenied {
+		t.Errorf("Expected a %s error, got: %+v", utils.ErrPathDenied, payload)
+	}
+}
+
+func TestServer_ToolsListIncludesAnnotations(t *testing.T) {
+	_, socketPath := startUnixServer(t, &config.TransportConfig{})
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	response := roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{}}`)
+
+	var parsed struct {
+		Result struct {
+			Tools []struct {
+				Name        string                 `json:"name"`
+				Title       string                 `json:"title"`
+				Annotations map[string]interface{} `json:"annotations"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", response, err)
+	}
+	if len(parsed.Result.Tools) != 1 {
+		t.Fatalf("Expected one tool, got: %s", response)
+	}
+
+	tool := parsed.Result.Tools[0]
+	if tool.Title != "Show File" {
+		t.Errorf("Expected the title to be advertised, got %q", tool.Title)
+	}
+	if tool.Annotations["readOnlyHint"] != true || tool.Annotations["destructiveHint"] != false || tool.Annotations["idempotentHint"] != true {
+		t.Errorf("Expected show_file to be advertised as read-only and idempotent, got %v", tool.Annotations)
+	}
+}
+
+func TestServer_ReadOnlySkipsWritingTools(t *testing.T) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	srv, err := NewServerWithConfig(config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	srv.ReadOnly = true
+
+	for _, tool := range []tools.Tool{tools.NewShowFileTool(), tools.NewWriteFileTool(), tools.NewExecuteShellTool()} {
+		if err := srv.RegisterTool(tool); err != nil {
+			t.Fatalf("Failed to register %s: %v", tool.Name(), err)
+		}
+	}
+
+	if len(srv.tools) != 1 || srv.tools[0].Name() != "show_file" {
+		t.Errorf("Expected only show_file to be offered in read-only mode, got %d tools", len(srv.tools))
+	}
+}
+
+func TestServer_MissingRequiredArgumentIsRejected(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, socketPath := startUnixServerWith(t, &config.TransportConfig{}, func(srv *Server) {
+		srv.Config = &config.ServerConfig{AllowedPaths: []string{dir}}
+		if err := srv.RegisterTool(tools.NewWriteFileTool()); err != nil {
+			t.Fatalf("Failed to register tool: %v", err)
+		}
+	})
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	roundTrip(t, conn, reader, `{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"error"}}`)
+
+	request, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      3,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": "write_file", "arguments": map[string]interface{}{"file_path": path}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := roundTrip(t, conn, reader, string(request))
+
+	var parsed struct {
+		Result struct {
+			IsError bool `json:"isError"`
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", response, err)
+	}
+	if !parsed.Result.IsError || len(parsed.Result.Content) != 1 || !strings.Contains(parsed.Result.Content[0].Text, `"INVALID_ARGS"`) {
+		t.Fatalf("Expected an INVALID_ARGS error, got: %s", response)
+	}
+	if !strings.Contains(parsed.Result.Content[0].Text, "content") {
+		t.Errorf("Expected the error to name content, got: %s", parsed.Result.Content[0].Text)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != "keep me" {
+		t.Errorf("Expected the file to be unchanged, got %q, %v", data, err)
+	}
+}
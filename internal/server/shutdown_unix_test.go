@@ -0,0 +1,90 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/tools"
+)
+
+func TestServer_Stop_KillsChildrenOfAbortedCommands(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fork.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nsleep 60 &\necho $! > \"$1\"\nwait\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(dir, "child.pid")
+
+	srv, socketPath := startUnixServerWith(t, &config.TransportConfig{}, func(srv *Server) {
+		srv.Config = &config.ServerConfig{AllowedPaths: []string{dir}}
+		if err := srv.RegisterTool(tools.NewExecuteShellTool()); err != nil {
+			t.Fatalf("Failed to register tool: %v", err)
+		}
+	})
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"execute_shell_command","arguments":{"command":["` + script + `","` + pidFile + `"]}}}` + "\n"))
+
+	// The script writes the background child's PID once it has started it
+	var pid int
+	deadline := time.Now().Add(5 * time.Second)
+	for pid == 0 {
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Command never started its child")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report := srv.Stop(ctx)
+	if len(report.Aborted) != 1 || report.Aborted[0].Tool != "execute_shell_command" {
+		t.Fatalf("Expected the command to be aborted, got %+v", report)
+	}
+
+	// The child is orphaned when the script dies, so once it is killed whoever
+	// reaps orphans removes it; give that a moment
+	deadline = time.Now().Add(5 * time.Second)
+	for !processGone(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the child process %d to be gone after Stop", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processGone reports whether pid no longer names a live process. A zombie
+// waiting to be reaped counts as gone, since it has already been killed.
+func processGone(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return true
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	// The state follows the parenthesised command name
+	_, fields, ok := strings.Cut(string(stat), ") ")
+	return ok && strings.HasPrefix(fields, "Z")
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/metoro-io/mcp-golang/transport/stdio"
+	"mcp-server/internal/config"
+)
+
+// PeerCredentials identifies the process on the other end of a unix socket connection
+type PeerCredentials struct {
+	PID int
+	UID int
+	GID int
+
+	// Groups lists the peer's supplementary groups
+	Groups []int
+}
+
+// startUnix listens on a unix domain socket and serves every accepted connection as its own session
+func (s *Server) startUnix(path string) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+
+	mode := socketMode(s.Transport)
+	listener, err := listenUnix(path, mode)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	// Mode 0660 only admits the socket file's group, so give it to the allowed one
+	if mode == 0660 {
+		if err := os.Chown(path, -1, s.Transport.AllowedGIDs[0]); err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to give %s to group %d: %w", path, s.Transport.AllowedGIDs[0], err)
+		}
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.socketPath = path
+	s.mu.Unlock()
+
+	slog.Info("Listening on unix socket", "path", path, "mode", fmt.Sprintf("%o", mode))
+
+	go s.acceptLoop(listener)
+
+	return nil
+}
+
+// socketMode returns the permissions of the socket file. Every connection's peer
+// credentials are checked against the allowed users and groups, so the mode only
+// needs to let those peers reach that check: the owner alone by default, the
+// socket's group when one group is allowed, and everyone when other users or
+// several groups are allowed, since a file has only one group.
+func socketMode(cfg *config.TransportConfig) os.FileMode {
+	for _, uid := range cfg.AllowedUIDs {
+		if uid != os.Getuid() {
+			return 0666
+		}
+	}
+	switch len(cfg.AllowedGIDs) {
+	case 0:
+		return 0600
+	case 1:
+		return 0660
+	}
+	return 0666
+}
+
+// acceptLoop accepts connections until the listener is closed
+func (s *Server) acceptLoop(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("Error accepting connection", "error", err)
+			continue
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn checks the peer's credentials and runs an MCP session over the connection
+func (s *Server) serveConn(conn net.Conn) {
+	peer, err := peerCredentials(conn)
+	if err != nil {
+		slog.Warn("Rejecting connection: unable to read peer credentials", "error", err)
+		conn.Close()
+		return
+	}
+
+	if !s.Transport.IsPeerAllowed(peer.UID, append([]int{peer.GID}, peer.Groups...)...) {
+		slog.Warn("Rejecting connection: not an allowed user or group", "pid", peer.PID, "uid", peer.UID, "gid", peer.GID, "groups", peer.Groups)
+		conn.Close()
+		return
+	}
+
+	// The stdio transport's read loop exits on EOF without reporting it,
+	// so watch the reader ourselves to know when the client hangs up
+	closed := make(chan struct{})
+	reader := &hangupReader{r: conn, onHangup: func() { close(closed) }}
+
+	sess, err := s.newSession(stdio.NewStdioServerTransportWithIO(newParamsReader(reader), conn), conn, peer)
+	if err != nil {
+		slog.Error("Failed to create session", "error", err)
+		conn.Close()
+		return
+	}
+
+	slog.Info("Session opened", "session", sess.id, "pid", peer.PID, "uid", peer.UID, "gid", peer.GID)
+
+	if err := sess.mcpServer.Serve(); err != nil {
+		slog.Error("Error in session", "session", sess.id, "error", err)
+		s.endSession(sess)
+		return
+	}
+
+	<-closed
+	s.endSession(sess)
+}
+
+// removeStaleSocket deletes a leftover socket file, refusing to touch it if another server is still listening
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is already listening on %s", path)
+	}
+
+	return os.Remove(path)
+}
+
+// hangupReader reports the first read error (usually EOF) from the underlying reader
+type hangupReader struct {
+	r        io.Reader
+	once     sync.Once
+	onHangup func()
+}
+
+func (h *hangupReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	if err != nil {
+		h.once.Do(h.onHangup)
+	}
+	return n, err
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"mcp-server/internal/charset"
+)
+
+// binarySniffSize is how much of the start of a file is examined to decide whether
+// it is binary
+const binarySniffSize = 8 << 10
+
+// fileType is what sniffing the start of a file found
+type fileType struct {
+	Binary   bool
+	MimeType string
+	// Kind describes the file for error messages, e.g. "PNG image"
+	Kind string
+	// Image is set for the image formats clients can display
+	Image bool
+	// Encoding is the character encoding of a text file
+	Encoding charset.Encoding
+}
+
+// magicNumber identifies a file format by the bytes it starts with
+type magicNumber struct {
+	prefix   string
+	mimeType string
+	kind     string
+	image    bool
+}
+
+var magicNumbers = []magicNumber{
+	{"\x89PNG\r\n\x1a\n", "image/png", "PNG image", true},
+	{"\xff\xd8\xff", "image/jpeg", "JPEG image", true},
+	{"GIF87a", "image/gif", "GIF image", true},
+	{"GIF89a", "image/gif", "GIF image", true},
+	{"%PDF-", "application/pdf", "PDF document", false},
+	{"PK\x03\x04", "application/zip", "ZIP archive", false},
+	{"\x1f\x8b", "application/gzip", "gzip archive", false},
+	{"\xfd7zXZ\x00", "application/x-xz", "xz archive", false},
+	{"\x28\xb5\x2f\xfd", "application/zstd", "zstd archive", false},
+	{"7z\xbc\xaf\x27\x1c", "application/x-7z-compressed", "7-Zip archive", false},
+	{"\x7fELF", "application/x-executable", "ELF binary", false},
+	{"\xfe\xed\xfa\xce", "application/x-mach-binary", "Mach-O binary", false},
+	{"\xfe\xed\xfa\xcf", "application/x-mach-binary", "Mach-O binary", false},
+	{"\xce\xfa\xed\xfe", "application/x-mach-binary", "Mach-O binary", false},
+	{"\xcf\xfa\xed\xfe", "application/x-mach-binary", "Mach-O binary", false},
+	{"MZ", "application/vnd.microsoft.portable-executable", "Windows executable", false},
+	{"\xca\xfe\xba\xbe", "application/java-vm", "Java class file", false},
+	{"\x00asm", "application/wasm", "WebAssembly module", false},
+	{"SQLite format 3\x00", "application/vnd.sqlite3", "SQLite database", false},
+}
+
+// sniffFile reads the start of file to tell whether it is binary
+func sniffFile(file io.ReaderAt) (fileType, int64, error) {
+	sample := make([]byte, binarySniffSize)
+	n, err := file.ReadAt(sample, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fileType{}, int64(n), err
+	}
+	return detectFileType(sample[:n], n == binarySniffSize), int64(n), nil
+}
+
+// detectFileType decides whether sample, the start of a file, is binary: it starts
+// with a known magic number, or does not look like text in any encoding the
+// charset package knows. truncated says the file continues past sample.
+func detectFileType(sample []byte, truncated bool) fileType {
+	for _, magic := range magicNumbers {
+		if bytes.HasPrefix(sample, []byte(magic.prefix)) {
+			return fileType{Binary: true, MimeType: magic.mimeType, Kind: magic.kind, Image: magic.image}
+		}
+	}
+	if len(sample) >= 12 && string(sample[:4]) == "RIFF" && string(sample[8:12]) == "WEBP" {
+		return fileType{Binary: true, MimeType: "image/webp", Kind: "WebP image", Image: true}
+	}
+
+	encoding, ok := charset.Detect(sample, truncated)
+	if !ok {
+		return fileType{Binary: true, MimeType: "application/octet-stream", Kind: "binary file"}
+	}
+	return fileType{MimeType: "text/plain", Kind: "text file", Encoding: encoding}
+}
+
+// hexDump formats data like xxd: an offset, 16 bytes in groups of two, and the
+// bytes as ASCII with dots for anything unprintable. offset is where data starts in
+// the file.
+func hexDump(data []byte, offset int64) string {
+	var out strings.Builder
+	for start := 0; start < len(data); start += 16 {
+		row := data[start:min(start+16, len(data))]
+
+		fmt.Fprintf(&out, "%08x: ", offset+int64(start))
+		for i := 0; i < 16; i++ {
+			if i < len(row) {
+				fmt.Fprintf(&out, "%02x", row[i])
+			} else {
+				out.WriteString("  ")
+			}
+			if i%2 == 1 {
+				out.WriteByte(' ')
+			}
+		}
+
+		out.WriteByte(' ')
+		for _, b := range row {
+			if b >= 0x20 && b < 0x7f {
+				out.WriteByte(b)
+			} else {
+				out.WriteByte('.')
+			}
+		}
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// Call carries per-invocation state between the server's dispatch path and a tool.
+// The server attaches it to the context passed to ExecuteContext; tools use it to
+// report what they touched so it can be logged and audited.
+type Call struct {
+	RequestID string
+	Session   string
+	Tool      string
+
+	// Config is the path policy of the call's session. Tools that check paths
+	// themselves, rather than through their arguments' Paths, use it in place of
+	// the server configuration.
+	Config *config.ServerConfig
+
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	cpuTime      atomic.Int64
+
+	mu        sync.Mutex
+	decisions []config.PathDecision
+}
+
+type callKey struct{}
+
+// WithCall returns a copy of ctx carrying call
+func WithCall(ctx context.Context, call *Call) context.Context {
+	return context.WithValue(ctx, callKey{}, call)
+}
+
+// CallFromContext returns the call attached to ctx, or nil if there is none.
+// All Call methods are safe to use on a nil call.
+func CallFromContext(ctx context.Context) *Call {
+	call, _ := ctx.Value(callKey{}).(*Call)
+	return call
+}
+
+// AddBytesRead records n bytes read from disk or a command
+func (c *Call) AddBytesRead(n int64) {
+	if c != nil {
+		c.bytesRead.Add(n)
+	}
+}
+
+// AddBytesWritten records n bytes written to disk
+func (c *Call) AddBytesWritten(n int64) {
+	if c != nil {
+		c.bytesWritten.Add(n)
+	}
+}
+
+// BytesRead returns the number of bytes the tool reported reading
+func (c *Call) BytesRead() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.bytesRead.Load()
+}
+
+// BytesWritten returns the number of bytes the tool reported writing
+func (c *Call) BytesWritten() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.bytesWritten.Load()
+}
+
+// AddCPUTime records CPU time used by a command the tool ran
+func (c *Call) AddCPUTime(d time.Duration) {
+	if c != nil {
+		c.cpuTime.Add(int64(d))
+	}
+}
+
+// CPUTime returns the CPU time the tool reported for the commands it ran
+func (c *Call) CPUTime() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(c.cpuTime.Load())
+}
+
+// RecordDecision records the outcome of a policy check made during the call
+func (c *Call) RecordDecision(decision config.PathDecision) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.decisions = append(c.decisions, decision)
+	c.mu.Unlock()
+}
+
+// Decisions returns the policy decisions made during the call
+func (c *Call) Decisions() []config.PathDecision {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]config.PathDecision(nil), c.decisions...)
+}
+
+// policyFor returns the path policy for the call in ctx, or fallback, the server
+// configuration a tool was given, outside a call
+func policyFor(ctx context.Context, fallback *config.ServerConfig) *config.ServerConfig {
+	if call := CallFromContext(ctx); call != nil && call.Config != nil {
+		return call.Config
+	}
+	return fallback
+}
+
+// checkPath evaluates the path policy and records the decision on the call in ctx.
+// Denials are logged with ctx so the client is told why the call was refused.
+func checkPath(ctx context.Context, cfg *config.ServerConfig, path string) (config.PathDecision, error) {
+	decision, err := cfg.CheckPath(path)
+	CallFromContext(ctx).RecordDecision(decision)
+
+	if err != nil {
+		slog.WarnContext(ctx, "Path policy check failed", "path", path, "error", err)
+	} else if !decision.Allowed {
+		slog.WarnContext(ctx, "Path denied by policy", "path", path, "rule", decision.Rule)
+	}
+
+	return decision, err
+}
+
+// requireArgPaths checks the paths args names against cfg, the server configuration
+// a tool was given, when the tool is run directly rather than by the server. Calls
+// made by the server carry a Call, and their paths have already been checked
+// against their session's policy.
+func requireArgPaths(ctx context.Context, cfg *config.ServerConfig, args PathArgs) error {
+	if CallFromContext(ctx) != nil {
+		return nil
+	}
+	for _, arg := range args.Paths() {
+		if err := RequirePath(ctx, cfg, arg.Path, arg.What); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequirePath checks path against the policy and returns a PATH_DENIED error unless it
+// is allowed. what names the path in the message, e.g. "file path" or "working directory".
+func RequirePath(ctx context.Context, cfg *config.ServerConfig, path, what string) error {
+	if cfg == nil {
+		return nil
+	}
+
+	decision, err := checkPath(ctx, cfg, path)
+	if err != nil {
+		return utils.NewToolError(utils.ErrPathDenied, "Access to this %s is not allowed by server configuration: %v", what, err).
+			WithDetails(map[string]interface{}{"path": path})
+	}
+	if !decision.Allowed {
+		return utils.NewToolError(utils.ErrPathDenied, "Access to this %s is not allowed by server configuration", what).
+			WithDetails(map[string]interface{}{"path": path, "rule": decision.Rule})
+	}
+	return nil
+}
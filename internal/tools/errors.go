@@ -0,0 +1,18 @@
+package tools
+
+import (
+	"errors"
+	"os"
+
+	"mcp-server/internal/utils"
+)
+
+// fileError classifies an error from the os package as a tool error.
+// action describes what failed, e.g. "reading file".
+func fileError(err error, path, action string) *utils.ToolError {
+	details := map[string]interface{}{"path": path}
+	if errors.Is(err, os.ErrNotExist) {
+		return utils.NewToolError(utils.ErrNotFound, "File %s does not exist", path).WithDetails(details)
+	}
+	return utils.NewToolError(utils.ErrIO, "Error %s: %v", action, err).WithDetails(details)
+}
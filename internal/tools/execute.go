@@ -1,10 +1,14 @@
 package tools
 
 import (
-	"fmt"
-	"io"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,9 +19,17 @@ import (
 
 // ExecuteShellCommandArgs defines the arguments for the execute_shell_command tool
 type ExecuteShellCommandArgs struct {
-	Command    []string `json:"command" jsonschema:"required,description=The command to execute as an array of strings"`
-	Timeout    int      `json:"timeout" jsonschema:"description=Maximum execution time in seconds"`
-	WorkingDir *string  `json:"working_dir" jsonschema:"description=Working directory for command execution"`
+	Command    []string `json:"command" jsonschema:"required,minItems=1,description=The command to execute as an array of strings"`
+	Timeout    int      `json:"timeout" jsonschema:"minimum=1,maximum=3600,description=Maximum execution time in seconds (defaults to 60)"`
+	WorkingDir *string  `json:"working_dir" jsonschema:"minLength=1,description=Working directory for command execution"`
+}
+
+// Paths returns the working directory, if one was given, for the server's path policy check
+func (a ExecuteShellCommandArgs) Paths() []PathArg {
+	if a.WorkingDir == nil {
+		return nil
+	}
+	return []PathArg{{Path: *a.WorkingDir, What: "working directory"}}
 }
 
 // ExecuteShellCommandResult defines the result of the execute_shell_command tool
@@ -54,8 +66,30 @@ func (t *ExecuteShellTool) Description() string {
 	return "Execute a shell command and return the complete results including stdout, stderr, and exit code"
 }
 
+// Title returns the tool's display name
+func (t *ExecuteShellTool) Title() string {
+	return "Execute Shell Command"
+}
+
+// Annotations describes the tool's behaviour
+func (t *ExecuteShellTool) Annotations() Annotations {
+	return Annotations{
+		Destructive: true,
+		OpenWorld:   true,
+	}
+}
+
 // Execute runs a shell command with the provided arguments
 func (t *ExecuteShellTool) Execute(args ExecuteShellCommandArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs a shell command, killing its whole process group if ctx is cancelled
+func (t *ExecuteShellTool) ExecuteContext(ctx context.Context, args ExecuteShellCommandArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
+	}
+
 	// Set default timeout if not provided
 	timeout := 60
 	if args.Timeout > 0 {
@@ -63,34 +97,24 @@ func (t *ExecuteShellTool) Execute(args ExecuteShellCommandArgs) (*mcp.ToolRespo
 	}
 
 	if len(args.Command) == 0 {
-		return utils.CreateErrorResponse("Empty command"), nil
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Empty command")
 	}
 
 	// Check if the command is valid
-	if !t.isCommandAllowed(args.Command[0]) {
-		return t.createResponse(
-			"",
-			fmt.Sprintf("Command '%s' is not allowed for security reasons", args.Command[0]),
-			-1,
-			strings.Join(args.Command, " "),
-			false,
-		), nil
-	}
-
-	// Check working directory if provided
-	if args.WorkingDir != nil && t.config != nil {
-		allowed, err := t.config.IsPathAllowed(*args.WorkingDir)
-		if err != nil || !allowed {
-			errorMsg := "Working directory is not allowed by server configuration"
-			if err != nil {
-				errorMsg = fmt.Sprintf("%s: %v", errorMsg, err)
-			}
-			return t.createResponse("", errorMsg, -1, strings.Join(args.Command, " "), false), nil
-		}
+	if !t.isCommandAllowed(ctx, args.Command[0]) {
+		slog.WarnContext(ctx, "Command blocked by allowlist", "command", args.Command[0])
+		return nil, utils.NewToolError(utils.ErrCommandDenied, "Command '%s' is not allowed for security reasons", args.Command[0])
 	}
 
-	// Create the command
-	cmd := exec.Command(args.Command[0], args.Command[1:]...)
+	// Create the command; it is killed when the timeout expires or the caller cancels
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, args.Command[0], args.Command[1:]...)
+	setProcessGroup(cmd)
+
+	// Don't wait forever on pipes held open by processes that escaped the group
+	cmd.WaitDelay = time.Second
 
 	// Set working directory if provided
 	if args.WorkingDir != nil {
@@ -98,67 +122,61 @@ func (t *ExecuteShellTool) Execute(args ExecuteShellCommandArgs) (*mcp.ToolRespo
 	}
 
 	// Capture stdout and stderr
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return t.createResponse("", fmt.Sprintf("Error creating stdout pipe: %v", err), -1, strings.Join(args.Command, " "), false), nil
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Start the command
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+			return nil, utils.NewToolError(utils.ErrNotFound, "Error starting command: %v", err)
+		}
+		return nil, utils.NewToolError(utils.ErrIO, "Error starting command: %v", err)
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return t.createResponse("", fmt.Sprintf("Error creating stderr pipe: %v", err), -1, strings.Join(args.Command, " "), false), nil
+	// Wait for command to complete, time out or be cancelled
+	err := cmd.Wait()
+	call := CallFromContext(ctx)
+	call.AddBytesRead(int64(stdout.Len() + stderr.Len()))
+	if cmd.ProcessState != nil {
+		call.AddCPUTime(cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime())
 	}
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		return t.createResponse("", fmt.Sprintf("Error starting command: %v", err), -1, strings.Join(args.Command, " "), false), nil
+	// Partial output is still useful when the command did not finish
+	partial := map[string]interface{}{
+		"stdout":  stdout.String(),
+		"stderr":  stderr.String(),
+		"command": strings.Join(args.Command, " "),
 	}
 
-	// Create a channel for command completion
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Wait()
-	}()
+	if ctx.Err() != nil {
+		// The server is shutting down
+		return nil, utils.NewToolError(utils.ErrAborted, "Command aborted: %v", ctx.Err()).WithDetails(partial)
+	}
 
-	// Read stdout and stderr
-	stdoutData, _ := io.ReadAll(stdout)
-	stderrData, _ := io.ReadAll(stderr)
+	if runCtx.Err() == context.DeadlineExceeded {
+		// Command timed out
+		return nil, utils.NewToolError(utils.ErrTimeout, "Command timed out after %d seconds", timeout).WithDetails(partial)
+	}
 
-	// Wait for command to complete or timeout
+	// Command completed
 	var exitCode int
 	var success bool
-
-	select {
-	case <-time.After(time.Duration(timeout) * time.Second):
-		// Command timed out
-		if cmd.Process != nil {
-			cmd.Process.Kill()
-		}
-		return t.createResponse(
-			string(stdoutData),
-			fmt.Sprintf("Command timed out after %d seconds\n%s", timeout, string(stderrData)),
-			-1,
-			strings.Join(args.Command, " "),
-			false,
-		), nil
-
-	case err := <-done:
-		// Command completed
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				exitCode = exitError.ExitCode()
-			} else {
-				exitCode = -1
-			}
-			success = false
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
 		} else {
-			exitCode = 0
-			success = true
+			exitCode = -1
 		}
+		success = false
+	} else {
+		exitCode = 0
+		success = true
 	}
 
 	return t.createResponse(
-		string(stdoutData),
-		string(stderrData),
+		stdout.String(),
+		stderr.String(),
 		exitCode,
 		strings.Join(args.Command, " "),
 		success,
@@ -166,32 +184,45 @@ func (t *ExecuteShellTool) Execute(args ExecuteShellCommandArgs) (*mcp.ToolRespo
 }
 
 // isCommandAllowed checks if a command is allowed to be executed
-func (t *ExecuteShellTool) isCommandAllowed(command string) bool {
+func (t *ExecuteShellTool) isCommandAllowed(ctx context.Context, command string) bool {
 	// Check if it's a path
 	if filepath.IsAbs(command) || strings.Contains(command, "/") || strings.Contains(command, "\\") {
-		// If it's a path and we have a config, check if it's in an allowed path
-		if t.config != nil {
-			allowed, _ := t.config.IsPathAllowed(command)
-			return allowed
+		// If it's a path and we have a policy, check if it's in an allowed path.
+		// The session's policy is narrowed by the roots its client declared.
+		if cfg := policyFor(ctx, t.config); cfg != nil {
+			decision, _ := checkPath(ctx, cfg, command)
+			return decision.Allowed
 		}
 	}
 
-	// Allowed common utilities and binaries
-	allowedCommands := map[string]bool{
-		"ls": true, "find": true, "grep": true, "cat": true, "echo": true,
-		"pwd": true, "cd": true, "mkdir": true, "rm": true, "cp": true, "mv": true,
-		"touch": true, "head": true, "tail": true, "wc": true, "sort": true,
-		"uniq": true, "cut": true, "tr": true, "sed": true, "awk": true,
-		"ps": true, "top": true, "df": true, "du": true, "free": true,
-		"which": true, "whereis": true, "whatis": true, "file": true,
-		"zip": true, "unzip": true, "tar": true, "gzip": true, "gunzip": true,
-		// Add more allowed commands as needed
-	}
-
-	// Default to not allowed if not in the whitelist
+	// Default to not allowed if not in the allowlist
 	return allowedCommands[command]
 }
 
+// allowedCommands are the common utilities and binaries that may be run by name
+var allowedCommands = map[string]bool{
+	"ls": true, "find": true, "grep": true, "cat": true, "echo": true,
+	"pwd": true, "cd": true, "mkdir": true, "rm": true, "cp": true, "mv": true,
+	"touch": true, "head": true, "tail": true, "wc": true, "sort": true,
+	"uniq": true, "cut": true, "tr": true, "sed": true, "awk": true,
+	"ps": true, "top": true, "df": true, "du": true, "free": true,
+	"which": true, "whereis": true, "whatis": true, "file": true,
+	"zip": true, "unzip": true, "tar": true, "gzip": true, "gunzip": true,
+	// Add more allowed commands as needed
+}
+
+// AllowedCommands returns the names of the commands execute_shell_command runs
+// without a path, sorted. Executables given by path are allowed if the path policy
+// allows them.
+func AllowedCommands() []string {
+	names := make([]string, 0, len(allowedCommands))
+	for name := range allowedCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // createResponse creates a response for the execute_shell_command tool
 func (t *ExecuteShellTool) createResponse(stdout, stderr string, exitCode int, command string, success bool) *mcp.ToolResponse {
 	result := ExecuteShellCommandResult{
@@ -1,11 +1,17 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
 )
 
 func TestExecuteShellTool_Name(t *testing.T) {
@@ -31,25 +37,23 @@ func TestExecuteShellTool_Execute_EmptyCommand(t *testing.T) {
 	}
 
 	resp, err := tool.Execute(args)
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
-	}
-
-	if resp == nil {
-		t.Fatal("Expected response to be non-nil")
-	}
-
-	// Verify error is returned in the response
-	if len(resp.Content) == 0 {
-		t.Fatal("Expected Content to be non-empty")
+	if resp != nil {
+		t.Errorf("Expected no response, got: %+v", resp)
 	}
 
 	// Use the mcp package here to fix "not used" error
 	_ = mcp.ContentTypeText // Just to use the import
 
-	content := resp.Content[0].TextContent.Text
-	if !strings.Contains(content, "Empty command") {
-		t.Errorf("Expected response to contain error message, got: %s", content)
+	// Verify a typed error is returned
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) {
+		t.Fatalf("Expected a *utils.ToolError, got: %v", err)
+	}
+	if toolErr.Code != utils.ErrInvalidArgs {
+		t.Errorf("Expected code %s, got %s", utils.ErrInvalidArgs, toolErr.Code)
+	}
+	if !strings.Contains(toolErr.Message, "Empty command") {
+		t.Errorf("Expected error message to mention the empty command, got: %s", toolErr.Message)
 	}
 }
 
@@ -102,6 +106,34 @@ func TestExecuteShellTool_Execute_Echo(t *testing.T) {
 	}
 }
 
+func TestExecuteShellTool_ExecutableByPathUsesSessionPolicy(t *testing.T) {
+	if isWindows() {
+		t.Skip("Skipping test on Windows")
+	}
+
+	// The server allows both directories; the session's roots narrow it to one
+	inRoots, outsideRoots := t.TempDir(), t.TempDir()
+	for _, dir := range []string{inRoots, outsideRoots} {
+		if err := os.WriteFile(filepath.Join(dir, "hello.sh"), []byte("#!/bin/sh\necho hello\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := NewExecuteShellTool()
+	tool.SetConfig(&config.ServerConfig{AllowedPaths: []string{inRoots, outsideRoots}})
+	ctx := WithCall(context.Background(), &Call{Config: &config.ServerConfig{AllowedPaths: []string{inRoots}}})
+
+	if _, err := tool.ExecuteContext(ctx, ExecuteShellCommandArgs{Command: []string{filepath.Join(inRoots, "hello.sh")}}); err != nil {
+		t.Errorf("Expected the executable inside the roots to run, got %v", err)
+	}
+
+	_, err := tool.ExecuteContext(ctx, ExecuteShellCommandArgs{Command: []string{filepath.Join(outsideRoots, "hello.sh")}})
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrCommandDenied {
+		t.Errorf("Expected the executable outside the roots to be denied, got %v", err)
+	}
+}
+
 // Helper function to check if running on Windows
 func isWindows() bool {
 	return false // For this example, just return false
@@ -0,0 +1,20 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package tools
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileChange returns the inode number and status change time of the file info
+// describes, which change when the file is replaced or written to even if its size
+// and modification time are left as they were
+func fileChange(info os.FileInfo) (inode uint64, ctime time.Time) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, time.Time{}
+	}
+	return uint64(stat.Ino), time.Unix(stat.Ctim.Unix())
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || openbsd || dragonfly || solaris || darwin || freebsd || netbsd)
+
+package tools
+
+import (
+	"os"
+	"time"
+)
+
+// fileChange reports nothing on platforms without inode numbers and status change
+// times, where cached hashes go by size and modification time alone
+func fileChange(info os.FileInfo) (inode uint64, ctime time.Time) {
+	return 0, time.Time{}
+}
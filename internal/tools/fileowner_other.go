@@ -0,0 +1,12 @@
+//go:build !unix
+
+package tools
+
+import (
+	"os"
+)
+
+// fileOwner reports no owner on platforms without Unix user and group IDs
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
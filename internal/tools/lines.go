@@ -0,0 +1,374 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// lineIndexInterval is how many lines apart a line index records offsets
+	lineIndexInterval = 1000
+
+	// lineIndexMinSize is the smallest file that gets a line index; smaller files
+	// are quick enough to scan from the start
+	lineIndexMinSize int64 = 1 << 20
+
+	// totalLinesScanLimit is how far past the lines shown show_file keeps reading
+	// to count the file's lines
+	totalLinesScanLimit int64 = 8 << 20
+)
+
+// maxLineIndexes is how many files' line indexes are kept
+const maxLineIndexes = 32
+
+// lineIndexes caches the line indexes of recently read large files
+var lineIndexes = &lineIndexCache{entries: make(map[string]*lineIndex)}
+
+// lineIndex is a sparse index of where lines start in one version of a file. It is
+// filled in as reads scan the file, so later reads deep into it can seek close to
+// the line they want instead of scanning from the start.
+type lineIndex struct {
+	size    int64
+	modTime time.Time
+
+	mu sync.Mutex
+	// offsets[i] is where line i*lineIndexInterval+1 starts
+	offsets []int64
+	// total is the number of lines, or 0 until a read has reached the end
+	total int
+}
+
+// record notes that line starts at offset
+func (x *lineIndex) record(line int, offset int64) {
+	if x == nil || (line-1)%lineIndexInterval != 0 {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if (line-1)/lineIndexInterval == len(x.offsets) {
+		x.offsets = append(x.offsets, offset)
+	}
+}
+
+// finish notes that the file has total lines
+func (x *lineIndex) finish(total int) {
+	if x == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.total = total
+}
+
+// totalLines returns the number of lines in the file, or 0 if it is not known yet
+func (x *lineIndex) totalLines() int {
+	if x == nil {
+		return 0
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.total
+}
+
+// beforeLine returns the indexed line closest to line without passing it, and
+// where it starts
+func (x *lineIndex) beforeLine(line int) (int, int64) {
+	if x == nil {
+		return 1, 0
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i := min((line-1)/lineIndexInterval, len(x.offsets)-1)
+	return i*lineIndexInterval + 1, x.offsets[i]
+}
+
+// beforeOffset returns the indexed line that starts closest to offset without
+// passing it, and where it starts
+func (x *lineIndex) beforeOffset(offset int64) (int, int64) {
+	if x == nil {
+		return 1, 0
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i := len(x.offsets) - 1
+	for i > 0 && x.offsets[i] > offset {
+		i--
+	}
+	return i*lineIndexInterval + 1, x.offsets[i]
+}
+
+// lineIndexCache holds line indexes by path, discarding the oldest beyond
+// maxLineIndexes
+type lineIndexCache struct {
+	mu      sync.Mutex
+	entries map[string]*lineIndex
+	order   []string
+}
+
+// get returns the line index for the file at path, or nil if it is too small to
+// need one. An index built for a different size or modification time is replaced.
+func (c *lineIndexCache) get(path string, info os.FileInfo) *lineIndex {
+	if info.Size() < lineIndexMinSize {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existing, ok := c.entries[path]
+	if ok && existing.size == info.Size() && existing.modTime.Equal(info.ModTime()) {
+		return existing
+	}
+
+	x := &lineIndex{size: info.Size(), modTime: info.ModTime(), offsets: []int64{0}}
+	if !ok {
+		c.order = append(c.order, path)
+		if len(c.order) > maxLineIndexes {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.entries[path] = x
+	return x
+}
+
+// lineReader reads a file a line at a time, keeping track of line numbers and byte
+// offsets and filling in the file's line index as it goes. Lines are numbered from 1
+// and separated by "\n", so a file ending in a newline has an empty last line, as
+// with strings.Split.
+type lineReader struct {
+	file  lineSource
+	size  int64
+	index *lineIndex
+	r     *bufio.Reader
+
+	// line is the number of the line that starts at offset
+	line   int
+	offset int64
+	// done is set once the last line has been read
+	done bool
+	// read counts the bytes read from the file
+	read int64
+}
+
+// lineSource is what a lineReader reads: a file, or a file converted to UTF-8 in
+// memory
+type lineSource interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// newLineReader returns a lineReader at the start of file
+func newLineReader(file lineSource, size int64, index *lineIndex) *lineReader {
+	return &lineReader{
+		file:  file,
+		size:  size,
+		index: index,
+		r:     bufio.NewReaderSize(file, 64<<10),
+		line:  1,
+	}
+}
+
+// jump moves the reader to the start of line, which starts at offset
+func (lr *lineReader) jump(line int, offset int64) error {
+	if _, err := lr.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	lr.r.Reset(lr.file)
+	lr.line, lr.offset, lr.done = line, offset, false
+	return nil
+}
+
+// next reads the current line into dst, without its newline, and moves on to the
+// following line. dst may be nil to skip the line. It returns io.EOF once the last
+// line has been read.
+func (lr *lineReader) next(dst *bytes.Buffer) error {
+	if lr.done {
+		return io.EOF
+	}
+
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		lr.read += int64(len(chunk))
+		lr.offset += int64(len(chunk))
+
+		switch {
+		case err == nil:
+			if dst != nil {
+				dst.Write(chunk[:len(chunk)-1])
+			}
+			lr.line++
+			lr.index.record(lr.line, lr.offset)
+			return nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			if dst != nil {
+				dst.Write(chunk)
+			}
+		case errors.Is(err, io.EOF):
+			if dst != nil {
+				dst.Write(chunk)
+			}
+			lr.done = true
+			lr.index.finish(lr.line)
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// seekLine moves the reader to the start of line. It returns false if the file
+// has fewer lines, leaving the reader at the end.
+func (lr *lineReader) seekLine(line int) (bool, error) {
+	if total := lr.index.totalLines(); total > 0 && line > total {
+		lr.line, lr.done = total, true
+		return false, nil
+	}
+
+	if indexed, offset := lr.index.beforeLine(line); line < lr.line || lr.done || indexed > lr.line {
+		if err := lr.jump(indexed, offset); err != nil {
+			return false, err
+		}
+	}
+
+	for lr.line < line {
+		if err := lr.next(nil); err != nil {
+			return false, err
+		}
+		if lr.done {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// seekOffset moves the reader to the start of the line containing offset
+func (lr *lineReader) seekOffset(offset int64) error {
+	if err := lr.jump(lr.index.beforeOffset(offset)); err != nil {
+		return err
+	}
+
+	// Scan whole lines up to offset, then go back to the start of the last one
+	line, start := lr.line, lr.offset
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		lr.read += int64(len(chunk))
+		lr.offset += int64(len(chunk))
+
+		if err == nil && lr.offset <= offset {
+			lr.line++
+			line, start = lr.line, lr.offset
+			lr.index.record(line, start)
+			continue
+		}
+		if errors.Is(err, bufio.ErrBufferFull) && lr.offset <= offset {
+			continue
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		break
+	}
+
+	return lr.jump(line, start)
+}
+
+// offsetFromEnd returns where the nth line from the end starts, reading the file
+// backwards from the end. The last line is 1; a final newline ends the last line
+// rather than starting another. If the file has fewer than n lines, it returns the
+// start of the file. It also returns how many lines that leaves to the end.
+func (lr *lineReader) offsetFromEnd(n int) (int64, int, error) {
+	if lr.size == 0 {
+		return 0, 0, nil
+	}
+
+	end := lr.size
+	last := make([]byte, 1)
+	if _, err := lr.file.ReadAt(last, end-1); err != nil {
+		return 0, 0, err
+	}
+	lr.read++
+	if last[0] == '\n' {
+		end--
+	}
+
+	found := 1
+	buf := make([]byte, 64<<10)
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := lr.file.ReadAt(chunk, start); err != nil {
+			return 0, 0, err
+		}
+		lr.read += int64(len(chunk))
+
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			if found == n {
+				return start + int64(i) + 1, found, nil
+			}
+			found++
+			chunk = chunk[:i]
+		}
+		end = start
+	}
+	return 0, found, nil
+}
+
+// totalLines returns the number of lines in the file. If that is not known yet, it
+// reads on to the end of the file when that is within limit bytes, and otherwise
+// returns 0.
+func (lr *lineReader) totalLines(limit int64) (int, error) {
+	if lr.done {
+		return lr.line, nil
+	}
+	if total := lr.index.totalLines(); total > 0 {
+		return total, nil
+	}
+	if lr.size-lr.offset > limit {
+		return 0, nil
+	}
+
+	for !lr.done {
+		if err := lr.next(nil); err != nil {
+			return 0, err
+		}
+	}
+	return lr.line, nil
+}
+
+// numberLines prefixes each line of content with its line number, counting from
+// first, in a right-aligned gutter like cat -n. If marked is not empty, the gutter
+// starts with a column holding > for the lines in it.
+func numberLines(content string, first int, marked map[int]bool) string {
+	var out strings.Builder
+	for i, line := range strings.Split(content, "\n") {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		if len(marked) > 0 {
+			if marked[first+i] {
+				out.WriteByte('>')
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		fmt.Fprintf(&out, "%6d\t%s", first+i, line)
+	}
+	return out.String()
+}
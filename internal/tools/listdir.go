@@ -0,0 +1,373 @@
+package tools
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/archive"
+	"mcp-server/internal/config"
+	"mcp-server/internal/gitignore"
+	"mcp-server/internal/utils"
+)
+
+// ListDirectoryArgs defines the arguments for the list_directory tool
+type ListDirectoryArgs struct {
+	Path           string   `json:"path" jsonschema:"required,minLength=1,description=Directory to list; archive.zip!/ or archive.tar.gz!/dir lists inside a zip or tar archive"`
+	Depth          int      `json:"depth" jsonschema:"minimum=1,maximum=20,description=How many levels to list: 1 (the default) for the directory's own entries, more to walk into subdirectories"`
+	Pattern        string   `json:"pattern" jsonschema:"description=Glob pattern entries must match; matched against the name, or against the path relative to the directory if it contains a /"`
+	Exclude        []string `json:"exclude" jsonschema:"maxItems=100,description=Glob patterns of entries to leave out, matched like pattern; excluded directories are not walked into"`
+	IncludeIgnored bool     `json:"include_ignored" jsonschema:"description=Also list entries that .gitignore files exclude, and .git directories"`
+	SortBy         string   `json:"sort_by" jsonschema:"enum=name,enum=size,enum=mtime,description=Order of the entries: 'name' (the default; directories before what is in them), 'size' (largest first) or 'mtime' (newest first)"`
+	Reverse        bool     `json:"reverse" jsonschema:"description=Reverse the order"`
+	Offset         int      `json:"offset" jsonschema:"minimum=0,description=Number of entries to skip, for paging"`
+	Limit          int      `json:"limit" jsonschema:"minimum=1,maximum=1000,description=Most entries to return (defaults to 200)"`
+}
+
+// Paths returns the directory to be listed, or the archive it is in, for the
+// server's path policy check
+func (a ListDirectoryArgs) Paths() []PathArg {
+	return []PathArg{{Path: policyPath(a.Path), What: "directory"}}
+}
+
+// DirectoryEntry is one entry in a directory listing
+type DirectoryEntry struct {
+	// Path is relative to the listed directory, with / between names
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ListDirectoryResult defines the result of the list_directory tool
+type ListDirectoryResult struct {
+	Success bool             `json:"success"`
+	Entries []DirectoryEntry `json:"entries"`
+	// Total is the number of entries in every page of the listing
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	// NextOffset is the offset of the next page, when HasMore is set
+	NextOffset int  `json:"next_offset,omitempty"`
+	HasMore    bool `json:"has_more"`
+	// Truncated is set when the walk stopped early because the tree was too large
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// File types in listings and stat results
+const (
+	typeFile      = "file"
+	typeDirectory = "directory"
+	typeSymlink   = "symlink"
+	typeOther     = "other"
+)
+
+var (
+	// listDirectoryLimit is the default page size of list_directory
+	listDirectoryLimit = 200
+
+	// maxListEntries is the most entries list_directory collects before it stops
+	// walking and reports the listing as truncated
+	maxListEntries = 100000
+)
+
+// ListDirectoryTool implements the list_directory tool
+type ListDirectoryTool struct {
+	config *config.ServerConfig
+}
+
+// NewListDirectoryTool creates a new ListDirectoryTool instance
+func NewListDirectoryTool() *ListDirectoryTool {
+	return &ListDirectoryTool{}
+}
+
+// SetConfig sets the server configuration
+func (t *ListDirectoryTool) SetConfig(cfg *config.ServerConfig) {
+	t.config = cfg
+}
+
+// Name returns the tool name
+func (t *ListDirectoryTool) Name() string {
+	return "list_directory"
+}
+
+// Description returns the tool description
+func (t *ListDirectoryTool) Description() string {
+	return "List the files and directories in a directory, optionally recursively, filtered by glob patterns and .gitignore, sorted and paged"
+}
+
+// Title returns the tool's display name
+func (t *ListDirectoryTool) Title() string {
+	return "List Directory"
+}
+
+// Annotations describes the tool's behaviour
+func (t *ListDirectoryTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
+// Execute lists a directory with the provided arguments
+func (t *ListDirectoryTool) Execute(args ListDirectoryArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext lists a directory, leaving out entries the session's path policy
+// denies
+func (t *ListDirectoryTool) ExecuteContext(ctx context.Context, args ListDirectoryArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range append([]string{args.Pattern}, args.Exclude...) {
+		if err := checkGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	depth := args.Depth
+	if depth == 0 {
+		depth = 1
+	}
+	limit := args.Limit
+	if limit == 0 {
+		limit = listDirectoryLimit
+	}
+
+	var entries []DirectoryEntry
+	var truncated bool
+	var err error
+	if archivePath, member, ok := archive.Split(args.Path); ok {
+		entries, truncated, err = listArchive(args, depth, archivePath, member)
+	} else {
+		entries, truncated, err = listTree(ctx, policyFor(ctx, t.config), args, depth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sortEntries(entries, args.SortBy, args.Reverse)
+
+	result := ListDirectoryResult{
+		Success:   true,
+		Entries:   []DirectoryEntry{},
+		Total:     len(entries),
+		Offset:    args.Offset,
+		Truncated: truncated,
+	}
+	if args.Offset < len(entries) {
+		end := min(args.Offset+limit, len(entries))
+		result.Entries = entries[args.Offset:end]
+		if end < len(entries) {
+			result.HasMore = true
+			result.NextOffset = end
+		}
+	}
+
+	return utils.CreateSuccessResponse(result), nil
+}
+
+// listTree walks a directory on disk to depth, leaving out entries the path policy
+// denies, .gitignore excludes or args filters out. truncated is set if it stopped
+// at maxListEntries.
+func listTree(ctx context.Context, cfg *config.ServerConfig, args ListDirectoryArgs, depth int) (entries []DirectoryEntry, truncated bool, err error) {
+	info, err := os.Stat(args.Path)
+	if err != nil {
+		return nil, false, fileError(err, args.Path, "checking directory")
+	}
+	if !info.IsDir() {
+		return nil, false, utils.NewToolError(utils.ErrInvalidArgs, "%s is not a directory", args.Path)
+	}
+
+	var ignore *gitignore.Matcher
+	if !args.IncludeIgnored {
+		ignore = gitignore.New(args.Path)
+	}
+
+	err = filepath.WalkDir(args.Path, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if path == args.Path {
+			return err
+		}
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the listing
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(args.Path, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		isDir := entry.IsDir()
+
+		skip := func() error {
+			if isDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !allowedByPolicy(cfg, path) {
+			return skip()
+		}
+		if ignore != nil && ((isDir && entry.Name() == ".git") || ignore.Ignored(path, isDir)) {
+			return skip()
+		}
+		for _, pattern := range args.Exclude {
+			if matchPattern(pattern, rel) {
+				return skip()
+			}
+		}
+
+		if args.Pattern == "" || matchPattern(args.Pattern, rel) {
+			if len(entries) == maxListEntries {
+				truncated = true
+				return fs.SkipAll
+			}
+			entries = append(entries, directoryEntry(rel, entry))
+		}
+
+		if isDir {
+			if strings.Count(rel, "/")+1 >= depth {
+				return fs.SkipDir
+			}
+			if ignore != nil {
+				ignore.Load(path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, false, utils.NewToolError(utils.ErrAborted, "Listing aborted: %v", ctxErr)
+		}
+		return nil, false, fileError(err, args.Path, "listing directory")
+	}
+	return entries, truncated, nil
+}
+
+// listArchive lists the members of an archive below dir, a directory in it or ""
+// for its top level, the way listTree lists a directory on disk. .gitignore files
+// don't apply inside archives.
+func listArchive(args ListDirectoryArgs, depth int, archivePath, dir string) (entries []DirectoryEntry, truncated bool, err error) {
+	members, err := archive.List(archivePath)
+	if err != nil {
+		return nil, false, archiveError(err, args.Path, archivePath)
+	}
+
+	prefix := ""
+	if dir != "" {
+		found := false
+		for _, m := range members {
+			if m.Name == dir {
+				if !m.IsDir() {
+					return nil, false, utils.NewToolError(utils.ErrInvalidArgs, "%s is not a directory", args.Path)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false, fileError(fs.ErrNotExist, args.Path, "listing directory")
+		}
+		prefix = dir + "/"
+	}
+
+	for _, m := range members {
+		rel, ok := strings.CutPrefix(m.Name, prefix)
+		if !ok || strings.Count(rel, "/") >= depth || excluded(args.Exclude, rel) {
+			continue
+		}
+		if args.Pattern != "" && !matchPattern(args.Pattern, rel) {
+			continue
+		}
+		if len(entries) == maxListEntries {
+			return entries, true, nil
+		}
+		entries = append(entries, DirectoryEntry{Path: rel, Type: entryType(m.Mode), Size: m.Size, ModTime: m.ModTime})
+	}
+	return entries, false, nil
+}
+
+// excluded reports whether rel, or a directory it is in, matches one of patterns
+func excluded(patterns []string, rel string) bool {
+	names := strings.Split(rel, "/")
+	for i := range names {
+		for _, pattern := range patterns {
+			if matchPattern(pattern, strings.Join(names[:i+1], "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// directoryEntry describes entry, at rel in the listing
+func directoryEntry(rel string, entry fs.DirEntry) DirectoryEntry {
+	result := DirectoryEntry{Path: rel, Type: entryType(entry.Type())}
+	if info, err := entry.Info(); err == nil {
+		result.Size = info.Size()
+		result.ModTime = info.ModTime()
+	}
+	return result
+}
+
+// entryType names the type of a file from its mode
+func entryType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return typeFile
+	case mode.IsDir():
+		return typeDirectory
+	case mode&fs.ModeSymlink != 0:
+		return typeSymlink
+	}
+	return typeOther
+}
+
+// sortEntries puts entries in the order sortBy names. Walk order already sorts them
+// by name, with each directory followed by its contents.
+func sortEntries(entries []DirectoryEntry, sortBy string, reverse bool) {
+	switch sortBy {
+	case "size":
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Size > entries[j].Size })
+	case "mtime":
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].ModTime.After(entries[j].ModTime) })
+	}
+	if reverse {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+}
+
+// matchPattern matches a list_directory glob against rel, an entry's path: the
+// whole path if the pattern has a /, and otherwise just the entry's name
+func matchPattern(pattern, rel string) bool {
+	names := strings.Split(rel, "/")
+	if !strings.Contains(pattern, "/") {
+		names = names[len(names)-1:]
+	}
+	return matchGlob(strings.Split(pattern, "/"), names, false)
+}
+
+// allowedByPolicy reports whether cfg allows path, without recording the decision;
+// listings leave denied entries out rather than refusing the call
+func allowedByPolicy(cfg *config.ServerConfig, path string) bool {
+	if cfg == nil {
+		return true
+	}
+	decision, err := cfg.CheckPath(path)
+	return err == nil && decision.Allowed
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-server/internal/config"
+)
+
+// entryPaths returns the paths of entries, joined with commas
+func entryPaths(entries []DirectoryEntry) string {
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.Path
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestListDirectoryTool(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":        "*.log\nbuild/\n",
+		"main.go":           "package main",
+		"big.txt":           strings.Repeat("x", 100),
+		"debug.log":         "ignored",
+		"build/out.bin":     "ignored",
+		"pkg/a.go":          "package pkg",
+		"pkg/sub/b.go":      "package sub",
+		"secret/key.pem":    "denied",
+		".git/HEAD":         "ref: refs/heads/main",
+		"pkg/sub/notes.txt": "notes",
+	})
+	cfg := &config.ServerConfig{AllowedPaths: []string{root}, DenyListPaths: []string{"secret"}}
+
+	tests := []struct {
+		name     string
+		args     ListDirectoryArgs
+		expected string
+	}{
+		{"top level", ListDirectoryArgs{}, ".gitignore,big.txt,main.go,pkg"},
+		{"recursive", ListDirectoryArgs{Depth: 3}, ".gitignore,big.txt,main.go,pkg,pkg/a.go,pkg/sub,pkg/sub/b.go,pkg/sub/notes.txt"},
+		{"pattern", ListDirectoryArgs{Depth: 3, Pattern: "*.go"}, "main.go,pkg/a.go,pkg/sub/b.go"},
+		{"path pattern", ListDirectoryArgs{Depth: 3, Pattern: "pkg/**/*.go"}, "pkg/a.go,pkg/sub/b.go"},
+		{"exclude", ListDirectoryArgs{Depth: 3, Exclude: []string{"sub", "*.txt"}}, ".gitignore,main.go,pkg,pkg/a.go"},
+		{"include ignored", ListDirectoryArgs{IncludeIgnored: true}, ".git,.gitignore,big.txt,build,debug.log,main.go,pkg"},
+		{"sort by size", ListDirectoryArgs{Exclude: []string{"pkg"}, SortBy: "size"}, "big.txt,.gitignore,main.go"},
+		{"reverse", ListDirectoryArgs{Exclude: []string{"pkg"}, Reverse: true}, "main.go,big.txt,.gitignore"},
+		{"wildcards skip dotfiles", ListDirectoryArgs{Pattern: "*.*"}, "big.txt,main.go"},
+		{"page", ListDirectoryArgs{Offset: 1, Limit: 2}, "big.txt,main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Path = root
+			result := runTool[ListDirectoryResult](t, withPolicy(cfg, NewListDirectoryTool().ExecuteContext), tt.args)
+			if paths := entryPaths(result.Entries); paths != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestListDirectoryTool_Paging(t *testing.T) {
+	root := writeTree(t, map[string]string{"a": "", "b": "", "c": ""})
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "b"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	first := runTool[ListDirectoryResult](t, NewListDirectoryTool().Execute, ListDirectoryArgs{Path: root, SortBy: "mtime", Limit: 2})
+	if !first.HasMore || first.NextOffset != 2 || first.Total != 3 {
+		t.Fatalf("Expected a first page of 2 of 3 entries, got %+v", first)
+	}
+	if first.Entries[0].Type != typeFile || first.Entries[1].Path == "b" {
+		t.Errorf("Expected the oldest file last, got %+v", first.Entries)
+	}
+
+	last := runTool[ListDirectoryResult](t, NewListDirectoryTool().Execute, ListDirectoryArgs{Path: root, SortBy: "mtime", Offset: first.NextOffset})
+	if last.HasMore || entryPaths(last.Entries) != "b" {
+		t.Errorf("Expected a last page holding b, got %+v", last)
+	}
+}
+
+func TestListDirectoryTool_Archive(t *testing.T) {
+	path := writeTestZip(t, map[string]string{
+		"README":         "read me",
+		"src/main.go":    "package main",
+		"src/pkg/lib.go": "package pkg",
+	})
+
+	tests := []struct {
+		name     string
+		args     ListDirectoryArgs
+		expected string
+	}{
+		{"top level", ListDirectoryArgs{Path: path + "!/"}, "README,src"},
+		{"recursive", ListDirectoryArgs{Path: path + "!/", Depth: 3}, "README,src,src/main.go,src/pkg,src/pkg/lib.go"},
+		{"subdirectory", ListDirectoryArgs{Path: path + "!/src", Depth: 2}, "main.go,pkg,pkg/lib.go"},
+		{"exclude", ListDirectoryArgs{Path: path + "!/", Depth: 3, Exclude: []string{"pkg"}}, "README,src,src/main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runTool[ListDirectoryResult](t, NewListDirectoryTool().Execute, tt.args)
+			if paths := entryPaths(result.Entries); paths != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, paths)
+			}
+		})
+	}
+
+	if result := runTool[ListDirectoryResult](t, NewListDirectoryTool().Execute, ListDirectoryArgs{Path: path + "!/src"}); result.Entries[0].Type != typeFile || result.Entries[0].Size != 12 {
+		t.Errorf("Expected main.go to be a 12-byte file, got %+v", result.Entries[0])
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package tools
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without process groups; cancellation
+// only kills the command itself
+func setProcessGroup(cmd *exec.Cmd) {
+}
@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/charset"
+	"mcp-server/internal/config"
+	"mcp-server/internal/dataquery"
+	"mcp-server/internal/utils"
+)
+
+// QueryDataFileArgs defines the arguments for the query_data_file tool
+type QueryDataFileArgs struct {
+	Path       string `json:"path" jsonschema:"required,minLength=1,description=JSON or YAML or TOML file to query; archive.zip!/config.json queries a member of a zip or tar archive"`
+	Query      string `json:"query" jsonschema:"required,minLength=1,description=JSONPath such as $.packages['node_modules/left-pad'].version or $..containers[?(@.name == 'app')].image; or jq-style paths and selects such as .items[] | select(.kind == 'Service') | .metadata.name"`
+	Format     string `json:"format" jsonschema:"enum=json,enum=yaml,enum=toml,description=Format of the file; by default it is taken from the file's extension or content"`
+	MaxResults int    `json:"max_results" jsonschema:"minimum=1,maximum=1000,description=Most matches to return (defaults to 100)"`
+}
+
+// Paths returns the file to be queried, or the archive it is in, for the server's
+// path policy check
+func (a QueryDataFileArgs) Paths() []PathArg {
+	return []PathArg{{Path: policyPath(a.Path), What: "file path"}}
+}
+
+// DataMatch is one node a query selected
+type DataMatch struct {
+	// Path is the node's JSONPath from the root of its document
+	Path string `json:"path"`
+	// Document numbers the YAML documents in files with more than one, from 1
+	Document int             `json:"document,omitempty"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// QueryDataFileResult defines the result of the query_data_file tool
+type QueryDataFileResult struct {
+	Success bool        `json:"success"`
+	Format  string      `json:"format"`
+	Matches []DataMatch `json:"matches"`
+	// MatchCount counts every match, including those left out of Matches
+	MatchCount int `json:"match_count"`
+	// Truncated is set when max_results or the output limit left matches out
+	Truncated bool `json:"truncated"`
+
+	// FileVersion is the version of the file that was queried
+	FileVersion
+}
+
+var (
+	// queryDataMaxResults is the default number of matches query_data_file returns
+	queryDataMaxResults = 100
+
+	// queryDataMaxBytes is the most encoded values query_data_file returns
+	queryDataMaxBytes = 256 << 10
+
+	// maxDataFileSize is the largest file query_data_file parses
+	maxDataFileSize int64 = 16 << 20
+)
+
+// QueryDataFileTool implements the query_data_file tool
+type QueryDataFileTool struct {
+	config *config.ServerConfig
+}
+
+// NewQueryDataFileTool creates a new QueryDataFileTool instance
+func NewQueryDataFileTool() *QueryDataFileTool {
+	return &QueryDataFileTool{}
+}
+
+// SetConfig sets the server configuration
+func (t *QueryDataFileTool) SetConfig(cfg *config.ServerConfig) {
+	t.config = cfg
+}
+
+// Name returns the tool name
+func (t *QueryDataFileTool) Name() string {
+	return "query_data_file"
+}
+
+// Description returns the tool description
+func (t *QueryDataFileTool) Description() string {
+	return "Query a JSON, YAML or TOML file with a JSONPath or jq-style expression, returning only the matching values and their paths"
+}
+
+// Title returns the tool's display name
+func (t *QueryDataFileTool) Title() string {
+	return "Query Data File"
+}
+
+// Annotations describes the tool's behaviour
+func (t *QueryDataFileTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
+// Execute queries a data file with the provided arguments
+func (t *QueryDataFileTool) Execute(args QueryDataFileArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext queries a data file, reporting what it read on the call in ctx
+func (t *QueryDataFileTool) ExecuteContext(ctx context.Context, args QueryDataFileArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
+	}
+
+	query, err := dataquery.Compile(args.Query)
+	if err != nil {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Invalid value for query: %v", err).
+			WithDetails(map[string]interface{}{"field": "query"})
+	}
+
+	file, err := openFileSource(args.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if file.size > maxDataFileSize {
+		return nil, utils.NewToolError(utils.ErrTooLarge, "%s is too large to query (limit %d bytes)", args.Path, maxDataFileSize).
+			WithDetails(map[string]interface{}{"size": file.size, "limit": maxDataFileSize})
+	}
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, file.size))
+	CallFromContext(ctx).AddBytesRead(int64(len(data)))
+	if err != nil {
+		return nil, fileError(err, args.Path, "reading file")
+	}
+	version := file.contentVersion(data)
+
+	sniffed := detectFileType(data[:min(len(data), binarySniffSize)], len(data) > binarySniffSize)
+	if sniffed.Binary {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "%s is a binary file (%s), not JSON, YAML or TOML", args.Path, sniffed.Kind).
+			WithDetails(map[string]interface{}{"binary": true, "kind": sniffed.Kind, "mime_type": sniffed.MimeType})
+	}
+	if !sniffed.Encoding.IsDefault() {
+		data = charset.Decode(data, sniffed.Encoding)
+	}
+
+	format := args.Format
+	if format == "" {
+		format = dataquery.DetectFormat(args.Path, data)
+	}
+	if format == "" {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Cannot tell whether %s is JSON, YAML or TOML; pass format", args.Path)
+	}
+	docs, err := dataquery.Parse(data, format)
+	if err != nil {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "%s is not valid %s: %v", args.Path, format, err).
+			WithDetails(map[string]interface{}{"format": format})
+	}
+
+	maxResults := args.MaxResults
+	if maxResults == 0 {
+		maxResults = queryDataMaxResults
+	}
+
+	result := QueryDataFileResult{
+		Success:     true,
+		Format:      format,
+		Matches:     []DataMatch{},
+		FileVersion: version,
+	}
+	size := 0
+	for i, doc := range docs {
+		for _, m := range query.Eval(doc) {
+			result.MatchCount++
+			if result.Truncated {
+				continue
+			}
+
+			value, err := json.Marshal(m.Value)
+			if err != nil {
+				return nil, utils.NewToolError(utils.ErrInternal, "Encoding the value at %s: %v", m.Path, err)
+			}
+			if len(result.Matches) == maxResults || size+len(value) > queryDataMaxBytes {
+				result.Truncated = true
+				continue
+			}
+			size += len(value)
+
+			match := DataMatch{Path: m.Path.String(), Value: value}
+			if len(docs) > 1 {
+				match.Document = i + 1
+			}
+			result.Matches = append(result.Matches, match)
+		}
+	}
+
+	return utils.CreateSuccessResponse(result), nil
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcp-server/internal/utils"
+)
+
+func TestQueryDataFileTool_Formats(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"package-lock.json": `{"packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`,
+		"deploy.yaml":       "kind: Deployment\nmetadata: {name: web}\n---\nkind: Service\nmetadata: {name: web-svc}\n",
+		"Cargo.toml":        "[package]\nname = \"tool\"\nversion = \"0.1.0\"\n",
+		"config":            "[server]\nport = 8080\n",
+	})
+
+	tests := []struct {
+		name     string
+		args     QueryDataFileArgs
+		format   string
+		expected []DataMatch
+	}{
+		{
+			name:     "json",
+			args:     QueryDataFileArgs{Path: "package-lock.json", Query: "$.packages['node_modules/left-pad'].version"},
+			format:   "json",
+			expected: []DataMatch{{Path: "$.packages['node_modules/left-pad'].version", Value: json.RawMessage(`"1.3.0"`)}},
+		},
+		{
+			name:   "yaml documents",
+			args:   QueryDataFileArgs{Path: "deploy.yaml", Query: ".metadata.name"},
+			format: "yaml",
+			expected: []DataMatch{
+				{Path: "$.metadata.name", Document: 1, Value: json.RawMessage(`"web"`)},
+				{Path: "$.metadata.name", Document: 2, Value: json.RawMessage(`"web-svc"`)},
+			},
+		},
+		{
+			name:     "toml",
+			args:     QueryDataFileArgs{Path: "Cargo.toml", Query: "$.package"},
+			format:   "toml",
+			expected: []DataMatch{{Path: "$.package", Value: json.RawMessage(`{"name":"tool","version":"0.1.0"}`)}},
+		},
+		{
+			name:     "format given",
+			args:     QueryDataFileArgs{Path: "config", Query: "$.server.port", Format: "toml"},
+			format:   "toml",
+			expected: []DataMatch{{Path: "$.server.port", Value: json.RawMessage(`8080`)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Path = filepath.Join(root, tt.args.Path)
+			result := runTool[QueryDataFileResult](t, NewQueryDataFileTool().Execute, tt.args)
+
+			if result.Format != tt.format || result.MatchCount != len(tt.expected) || result.SHA256 == "" {
+				t.Fatalf("Unexpected result: %+v", result)
+			}
+			for i, match := range result.Matches {
+				expected := tt.expected[i]
+				if match.Path != expected.Path || match.Document != expected.Document || string(match.Value) != string(expected.Value) {
+					t.Errorf("Expected match %d to be %s %s, got %s %s", i, expected.Path, expected.Value, match.Path, match.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryDataFileTool_Truncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "numbers.json")
+	if err := os.WriteFile(path, []byte(`[1, 2, 3, 4, 5]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := runTool[QueryDataFileResult](t, NewQueryDataFileTool().Execute, QueryDataFileArgs{Path: path, Query: "$[*]", MaxResults: 2})
+	if len(result.Matches) != 2 || result.MatchCount != 5 || !result.Truncated {
+		t.Errorf("Expected 2 of 5 matches, got %+v", result)
+	}
+}
+
+func TestQueryDataFileTool_Errors(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"bad.json": `{"a": `,
+		"notes":    "just text",
+	})
+
+	tests := []struct {
+		name string
+		args QueryDataFileArgs
+		code utils.ErrorCode
+	}{
+		{"invalid query", QueryDataFileArgs{Path: "bad.json", Query: "$.["}, utils.ErrInvalidArgs},
+		{"invalid document", QueryDataFileArgs{Path: "bad.json", Query: "$.a"}, utils.ErrInvalidArgs},
+		{"unknown format", QueryDataFileArgs{Path: "notes", Query: "$"}, utils.ErrInvalidArgs},
+		{"missing file", QueryDataFileArgs{Path: "missing.json", Query: "$"}, utils.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Path = filepath.Join(root, tt.args.Path)
+			_, err := NewQueryDataFileTool().Execute(tt.args)
+			var toolErr *utils.ToolError
+			if !errors.As(err, &toolErr) || toolErr.Code != tt.code {
+				t.Errorf("Expected a %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package tools
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// ReadFilesArgs defines the arguments for the read_files tool
+type ReadFilesArgs struct {
+	Files       []ReadFileSpec `json:"files" jsonschema:"required,minItems=1,maxItems=100,description=Files to read: paths or glob patterns, each with an optional line range"`
+	LineNumbers bool           `json:"line_numbers" jsonschema:"description=Prefix each line with its line number"`
+	MaxBytes    int            `json:"max_bytes" jsonschema:"minimum=1,maximum=4194304,description=Most content to return across all files, in bytes (defaults to 262144); files past it are cut short or skipped"`
+}
+
+// ReadFileSpec is one entry in the read_files list
+type ReadFileSpec struct {
+	Path      string `json:"path" jsonschema:"required,minLength=1,description=File path or glob pattern; * and ? match within a name and ** matches any number of directories"`
+	StartLine int    `json:"start_line" jsonschema:"description=Line number to start from (1-based indexing); negative numbers count from the end"`
+	NumLines  *int   `json:"num_lines" jsonschema:"minimum=0,description=Number of lines to read (defaults to all lines)"`
+}
+
+// ReadFilesResult defines the result of the read_files tool
+type ReadFilesResult struct {
+	Success bool             `json:"success"`
+	Files   []ReadFileResult `json:"files"`
+	// FilesRead and FilesFailed count the entries in Files with and without an error
+	FilesRead   int `json:"files_read"`
+	FilesFailed int `json:"files_failed"`
+	// Truncated is set when the output budget cut a file short or skipped it
+	Truncated bool `json:"truncated"`
+	// MoreFiles is set when the patterns matched more files than one call reads
+	MoreFiles bool `json:"more_files,omitempty"`
+}
+
+// ReadFileResult is what read_files read from one file, or why it could not
+type ReadFileResult struct {
+	Path       string `json:"path"`
+	Content    string `json:"content,omitempty"`
+	LinesShown int    `json:"lines_shown"`
+	StartLine  int    `json:"start_line,omitempty"`
+	EndLine    int    `json:"end_line,omitempty"`
+	TotalLines int    `json:"total_lines,omitempty"`
+	// Encoding is set for files converted to UTF-8 from another encoding
+	Encoding string `json:"encoding,omitempty"`
+	// Truncated is set when the output budget cut the content short
+	Truncated bool             `json:"truncated,omitempty"`
+	Error     *utils.ToolError `json:"error,omitempty"`
+
+	// FileVersion is the version of the file that was read; it is left out of
+	// results for files that could not be read
+	*FileVersion
+}
+
+var (
+	// readFilesMaxBytes is the default output budget of a read_files call
+	readFilesMaxBytes = 256 << 10
+
+	// maxReadFiles is the most files one read_files call reads
+	maxReadFiles = 100
+
+	// readFilesWorkers is how many files are read at once
+	readFilesWorkers = 8
+)
+
+// ReadFilesTool implements the read_files tool
+type ReadFilesTool struct {
+	config *config.ServerConfig
+}
+
+// NewReadFilesTool creates a new ReadFilesTool instance
+func NewReadFilesTool() *ReadFilesTool {
+	return &ReadFilesTool{}
+}
+
+// SetConfig sets the server configuration
+func (t *ReadFilesTool) SetConfig(cfg *config.ServerConfig) {
+	t.config = cfg
+}
+
+// Name returns the tool name
+func (t *ReadFilesTool) Name() string {
+	return "read_files"
+}
+
+// Description returns the tool description
+func (t *ReadFilesTool) Description() string {
+	return "Read several text files at once, given as paths or glob patterns with optional line ranges; a file that cannot be read gets an error of its own without failing the rest"
+}
+
+// Title returns the tool's display name
+func (t *ReadFilesTool) Title() string {
+	return "Read Files"
+}
+
+// Annotations describes the tool's behaviour
+func (t *ReadFilesTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
+// Execute reads files with the provided arguments
+func (t *ReadFilesTool) Execute(args ReadFilesArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reads files concurrently. ReadFilesArgs has no Paths method: each
+// file is checked against the policy here, so that one denied file is reported in
+// its own entry rather than refusing the whole call.
+func (t *ReadFilesTool) ExecuteContext(ctx context.Context, args ReadFilesArgs) (*mcp.ToolResponse, error) {
+	cfg := policyFor(ctx, t.config)
+
+	budget := args.MaxBytes
+	if budget == 0 {
+		budget = readFilesMaxBytes
+	}
+
+	// Expand the patterns, reporting those that match nothing in their own entry
+	type job struct {
+		path string
+		spec ReadFileSpec
+		err  error
+	}
+	var jobs []job
+	seen := make(map[ReadFileSpec]bool)
+	moreFiles := false
+	for _, spec := range args.Files {
+		if !hasGlobMeta(spec.Path) {
+			jobs = append(jobs, job{path: spec.Path, spec: spec})
+			continue
+		}
+
+		matches, more, err := expandGlob(cfg, spec.Path, maxReadFiles-len(jobs))
+		if err != nil {
+			jobs = append(jobs, job{path: spec.Path, spec: spec, err: err})
+			continue
+		}
+		moreFiles = moreFiles || more
+		for _, match := range matches {
+			key := spec
+			key.Path = match
+			if !seen[key] {
+				seen[key] = true
+				jobs = append(jobs, job{path: match, spec: spec})
+			}
+		}
+	}
+	if len(jobs) > maxReadFiles {
+		jobs, moreFiles = jobs[:maxReadFiles], true
+	}
+
+	// Read concurrently, each file at most the whole budget
+	results := make([]ReadFileResult, len(jobs))
+	workers := make(chan struct{}, readFilesWorkers)
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		if j.err != nil {
+			results[i] = ReadFileResult{Path: j.path, Error: utils.AsToolError(j.err)}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, j job) {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			results[i] = readOneFile(ctx, cfg, j.path, j.spec, args.LineNumbers, budget)
+		}(i, j)
+	}
+	wg.Wait()
+
+	// Spend the budget in the order the files were asked for, cutting content at the
+	// last whole line that fits
+	result := ReadFilesResult{Success: true, Files: results, MoreFiles: moreFiles}
+	remaining := budget
+	for i := range results {
+		file := &results[i]
+		if file.Error != nil {
+			result.FilesFailed++
+			continue
+		}
+		result.FilesRead++
+
+		if len(file.Content) > remaining || (remaining == 0 && file.LinesShown > 0) {
+			kept := ""
+			if len(file.Content) > remaining {
+				if cut := strings.LastIndexByte(file.Content[:remaining+1], '\n'); cut >= 0 {
+					kept = file.Content[:cut]
+				}
+			}
+			file.Content = kept
+			file.LinesShown = 0
+			if kept != "" {
+				file.LinesShown = strings.Count(kept, "\n") + 1
+			}
+			file.EndLine = file.StartLine + file.LinesShown
+			file.Truncated = true
+			result.Truncated = true
+		}
+		remaining -= len(file.Content)
+	}
+
+	return utils.CreateSuccessResponse(result), nil
+}
+
+// readOneFile reads what spec asks for from the file at path, reading no more than
+// about limit bytes of content
+func readOneFile(ctx context.Context, cfg *config.ServerConfig, path string, spec ReadFileSpec, lineNumbers bool, limit int) ReadFileResult {
+	result := ReadFileResult{Path: path}
+	fail := func(err error) ReadFileResult {
+		result.Error = utils.AsToolError(err)
+		return result
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(utils.NewToolError(utils.ErrAborted, "Read aborted: %v", err))
+	}
+	if err := RequirePath(ctx, cfg, policyPath(path), "file path"); err != nil {
+		return fail(err)
+	}
+
+	file, err := openFileSource(path)
+	if err != nil {
+		return fail(err)
+	}
+	defer file.Close()
+
+	sniffed, n, err := sniffFile(file)
+	CallFromContext(ctx).AddBytesRead(n)
+	if err != nil {
+		return fail(fileError(err, path, "reading file"))
+	}
+	if sniffed.Binary {
+		return fail(utils.NewToolError(utils.ErrInvalidArgs, "%s is a binary file (%s); use show_file with format hex or base64 to show it", path, sniffed.Kind).
+			WithDetails(map[string]interface{}{"binary": true, "kind": sniffed.Kind, "mime_type": sniffed.MimeType, "size": file.size}))
+	}
+
+	textArgs := ShowFileArgs{FilePath: path, StartLine: spec.StartLine, NumLines: spec.NumLines, LineNumbers: lineNumbers}
+	text, err := readText(ctx, textArgs, file, sniffed, limit)
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Content = text.Content
+	result.LinesShown = text.LinesShown
+	result.StartLine = text.StartLine
+	result.EndLine = text.EndLine
+	result.TotalLines = text.TotalLines
+	version, err := file.rangedVersion()
+	if err != nil {
+		return fail(err)
+	}
+	result.FileVersion = &version
+	if !text.Encoding.IsDefault() {
+		result.Encoding = text.Encoding.Name
+	}
+	return result
+}
+
+// hasGlobMeta reports whether pattern has any glob metacharacters
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// checkGlob returns an INVALID_ARGS error if pattern is malformed
+func checkGlob(pattern string) error {
+	if _, err := path.Match(filepath.ToSlash(pattern), ""); err != nil {
+		return utils.NewToolError(utils.ErrInvalidArgs, "Invalid glob pattern %q: %v", pattern, err)
+	}
+	return nil
+}
+
+// expandGlob returns up to limit files matching pattern, in lexical order, that the
+// policy allows; more is set if there were others. Like a shell, wildcards don't
+// match names starting with a dot unless the pattern's name does too.
+func expandGlob(cfg *config.ServerConfig, pattern string, limit int) (matches []string, more bool, err error) {
+	if err := checkGlob(pattern); err != nil {
+		return nil, false, err
+	}
+
+	// Walk from the longest leading directory without wildcards
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	static := 0
+	for static < len(segments)-1 && !hasGlobMeta(segments[static]) {
+		static++
+	}
+	root := strings.Join(segments[:static], "/")
+	if root == "" && strings.HasPrefix(pattern, "/") {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+	rest := segments[static:]
+
+	walkErr := filepath.WalkDir(filepath.FromSlash(root), func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the pattern
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		names := strings.Split(filepath.ToSlash(rel), "/")
+
+		if entry.IsDir() {
+			if !matchGlob(rest, names, true) || (!allowedByPolicy(cfg, p) && !aboveAllowed(cfg, p)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !matchGlob(rest, names, false) || !allowedByPolicy(cfg, p) {
+			return nil
+		}
+		if len(matches) == limit {
+			more = true
+			return filepath.SkipAll
+		}
+		matches = append(matches, p)
+		return nil
+	})
+	if walkErr != nil {
+		return nil, false, fileError(walkErr, root, "listing files")
+	}
+
+	if len(matches) == 0 && !more {
+		return nil, false, utils.NewToolError(utils.ErrNotFound, "No files match %s", pattern).
+			WithDetails(map[string]interface{}{"path": pattern})
+	}
+	return matches, more, nil
+}
+
+// aboveAllowed reports whether dir is an ancestor of one of the policy's allowed
+// roots, so walking through it can still reach allowed files
+func aboveAllowed(cfg *config.ServerConfig, dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, root := range cfg.AllowedPaths {
+		// Allowed paths may be relative, and Rel fails on a mix of the two
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(abs, root); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches the names of a path against the segments of a pattern, where
+// a "**" segment matches any number of names. With prefix set, it reports whether
+// names could be the start of a match, to decide whether to walk into a directory.
+func matchGlob(pattern, names []string, prefix bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if i > 0 && strings.HasPrefix(names[i-1], ".") {
+					break
+				}
+				if matchGlob(pattern[1:], names[i:], prefix) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return prefix
+		}
+		if strings.HasPrefix(names[0], ".") && !strings.HasPrefix(pattern[0], ".") {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], names[0]); !ok {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
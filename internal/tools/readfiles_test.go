@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// writeTree creates files, given by slash-separated path, under a temporary directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestReadFilesTool_PerFileResults(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.txt":      "a1\na2\na3",
+		"b.txt":      numberedLines(10),
+		"secret.txt": "hunter2",
+		"image.png":  "\x89PNG\r\n\x1a\n\x00\x00",
+	})
+	cfg := &config.ServerConfig{AllowedPaths: []string{root}, DenyListPaths: []string{"secret.txt"}}
+
+	result := runTool[ReadFilesResult](t, withPolicy(cfg, NewReadFilesTool().ExecuteContext), ReadFilesArgs{Files: []ReadFileSpec{
+		{Path: filepath.Join(root, "a.txt")},
+		{Path: filepath.Join(root, "b.txt"), StartLine: 3, NumLines: intPtr(2)},
+		{Path: filepath.Join(root, "secret.txt")},
+		{Path: filepath.Join(root, "missing.txt")},
+		{Path: filepath.Join(root, "image.png")},
+	}})
+
+	if result.FilesRead != 2 || result.FilesFailed != 3 || len(result.Files) != 5 {
+		t.Fatalf("Expected 2 files read and 3 failed, got %+v", result)
+	}
+	if result.Files[0].Content != "a1\na2\na3" || result.Files[0].LinesShown != 3 {
+		t.Errorf("Unexpected result for a.txt: %+v", result.Files[0])
+	}
+	if version := result.Files[0].FileVersion; version == nil || version.SHA256 != sha256Hex("a1\na2\na3") {
+		t.Errorf("Expected the version of a.txt, got %+v", version)
+	}
+	if b := result.Files[1]; b.Content != "line 3\nline 4" || b.StartLine != 3 || b.EndLine != 5 {
+		t.Errorf("Unexpected result for b.txt: %+v", b)
+	}
+
+	codes := []utils.ErrorCode{utils.ErrPathDenied, utils.ErrNotFound, utils.ErrInvalidArgs}
+	for i, code := range codes {
+		file := result.Files[2+i]
+		if file.Error == nil || file.Error.Code != code || file.Content != "" || file.FileVersion != nil {
+			t.Errorf("Expected %s for %s, got %+v", code, file.Path, file)
+		}
+	}
+}
+
+func TestReadFilesTool_Globs(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":            "package main",
+		"README.md":          "# readme",
+		"pkg/a.go":           "package pkg",
+		"pkg/sub/b.go":       "package sub",
+		"pkg/.hidden/c.go":   "package hidden",
+		"vendor/dep/dep.go":  "package dep",
+		"pkg/sub/b_test.txt": "not go",
+	})
+	cfg := &config.ServerConfig{AllowedPaths: []string{root}, DenyListPaths: []string{"vendor"}}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+		code     utils.ErrorCode
+	}{
+		{"*.go", []string{"main.go"}, ""},
+		{"pkg/*.go", []string{"pkg/a.go"}, ""},
+		{"**/*.go", []string{"main.go", "pkg/a.go", "pkg/sub/b.go"}, ""},
+		{"pkg/**/b.?o", []string{"pkg/sub/b.go"}, ""},
+		{"pkg/.hidden/*.go", []string{"pkg/.hidden/c.go"}, ""},
+		{"*.rs", nil, utils.ErrNotFound},
+		{"[", nil, utils.ErrInvalidArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			result := runTool[ReadFilesResult](t, withPolicy(cfg, NewReadFilesTool().ExecuteContext), ReadFilesArgs{Files: []ReadFileSpec{{Path: filepath.Join(root, tt.pattern)}}})
+
+			if tt.code != "" {
+				if len(result.Files) != 1 || result.Files[0].Error == nil || result.Files[0].Error.Code != tt.code {
+					t.Fatalf("Expected a single %s entry, got %+v", tt.code, result.Files)
+				}
+				return
+			}
+
+			var got []string
+			for _, file := range result.Files {
+				if file.Error != nil {
+					t.Errorf("Unexpected error for %s: %v", file.Path, file.Error)
+				}
+				rel, _ := filepath.Rel(root, file.Path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAboveAllowed(t *testing.T) {
+	root := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relative, err := filepath.Rel(cwd, filepath.Join(root, "pkg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		allowed  string
+		dir      string
+		expected bool
+	}{
+		{"absolute root", filepath.Join(root, "pkg"), root, true},
+		{"relative root", relative, root, true},
+		{"the root itself", relative, filepath.Join(root, "pkg"), false},
+		{"beside the root", relative, filepath.Join(root, "other"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.ServerConfig{AllowedPaths: []string{tt.allowed}}
+			if got := aboveAllowed(cfg, tt.dir); got != tt.expected {
+				t.Errorf("Expected aboveAllowed(%s) with root %s to be %v", tt.dir, tt.allowed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadFilesTool_Budget(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.txt": "aaaa\nbbbb\ncccc",
+		"b.txt": "dddd\neeee",
+		"c.txt": "ffff",
+	})
+
+	files := []ReadFileSpec{
+		{Path: filepath.Join(root, "a.txt")},
+		{Path: filepath.Join(root, "b.txt")},
+		{Path: filepath.Join(root, "c.txt")},
+	}
+	result := runTool[ReadFilesResult](t, NewReadFilesTool().Execute, ReadFilesArgs{Files: files, MaxBytes: 20})
+
+	if !result.Truncated {
+		t.Error("Expected the result to be truncated")
+	}
+	if a := result.Files[0]; a.Content != "aaaa\nbbbb\ncccc" || a.Truncated {
+		t.Errorf("Expected a.txt in full, got %+v", a)
+	}
+	if b := result.Files[1]; b.Content != "dddd" || b.LinesShown != 1 || b.EndLine != 2 || !b.Truncated {
+		t.Errorf("Expected the first line of b.txt, got %+v", b)
+	}
+	if c := result.Files[2]; c.Content != "" || c.LinesShown != 0 || !c.Truncated {
+		t.Errorf("Expected c.txt to be skipped, got %+v", c)
+	}
+}
+
+func TestReadFilesTool_LineNumbers(t *testing.T) {
+	path := writeTestFile(t, numberedLines(12))
+
+	result := runTool[ReadFilesResult](t, NewReadFilesTool().Execute, ReadFilesArgs{
+		Files:       []ReadFileSpec{{Path: path, StartLine: 9, NumLines: intPtr(2)}},
+		LineNumbers: true,
+	})
+
+	if expected := "     9\tline 9\n    10\tline 10"; result.Files[0].Content != expected {
+		t.Errorf("Expected %q, got %q", expected, result.Files[0].Content)
+	}
+}
+
+func TestReadFilesTool_ArchiveMember(t *testing.T) {
+	path := writeTestZip(t, map[string]string{"conf/app.yaml": "name: app\n"})
+	cfg := &config.ServerConfig{AllowedPaths: []string{filepath.Dir(path)}}
+
+	result := runTool[ReadFilesResult](t, withPolicy(cfg, NewReadFilesTool().ExecuteContext), ReadFilesArgs{Files: []ReadFileSpec{{Path: path + "!/conf/app.yaml"}}})
+	if file := result.Files[0]; file.Error != nil || file.Content != "name: app\n" {
+		t.Errorf("Expected the member's content, got %+v", file)
+	}
+}
@@ -2,21 +2,29 @@ package tools
 
 import (
 	"bufio"
-	"fmt"
+	"context"
+	"errors"
+	"log/slog"
 	"os"
 	"regexp"
 
 	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/charset"
 	"mcp-server/internal/config"
 	"mcp-server/internal/utils"
 )
 
 // SearchInFileArgs defines the arguments for the search_in_file tool
 type SearchInFileArgs struct {
-	FilePath      string `json:"file_path" jsonschema:"required,description=Path to the file to search"`
-	Pattern       string `json:"pattern" jsonschema:"required,description=Regular expression pattern to search for"`
+	FilePath      string `json:"file_path" jsonschema:"required,minLength=1,description=Path to the file to search"`
+	Pattern       string `json:"pattern" jsonschema:"required,minLength=1,description=Regular expression pattern to search for"`
 	CaseSensitive bool   `json:"case_sensitive" jsonschema:"description=Whether the search should be case-sensitive"`
-	MaxMatches    int    `json:"max_matches" jsonschema:"description=Maximum number of matches to return (use -1 for all matches)"`
+	MaxMatches    int    `json:"max_matches" jsonschema:"minimum=-1,description=Maximum number of matches to return (0 or -1 for all matches)"`
+}
+
+// Paths returns the file to be searched, for the server's path policy check
+func (a SearchInFileArgs) Paths() []PathArg {
+	return []PathArg{{Path: a.FilePath, What: "file path"}}
 }
 
 // MatchResult represents a single match result
@@ -28,10 +36,15 @@ type MatchResult struct {
 // SearchInFileResult defines the result of the search_in_file tool
 type SearchInFileResult struct {
 	Success    bool          `json:"success"`
-	Error      string        `json:"error,omitempty"`
 	Matches    []MatchResult `json:"matches"`
 	MatchCount int           `json:"match_count"`
 	Truncated  bool          `json:"truncated"`
+
+	// Encoding is the file's detected encoding; matches are always UTF-8
+	charset.Encoding
+
+	// FileVersion is the version of the file that was searched
+	FileVersion
 }
 
 // SearchFileTool implements the search_in_file tool
@@ -59,53 +72,42 @@ func (t *SearchFileTool) Description() string {
 	return "Search for patterns in a file using regular expressions"
 }
 
+// Title returns the tool's display name
+func (t *SearchFileTool) Title() string {
+	return "Search in File"
+}
+
+// Annotations describes the tool's behaviour
+func (t *SearchFileTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
 // Execute searches in a file with the provided arguments
 func (t *SearchFileTool) Execute(args SearchInFileArgs) (*mcp.ToolResponse, error) {
-	// Check if path is allowed by configuration
-	if t.config != nil {
-		allowed, err := t.config.IsPathAllowed(args.FilePath)
-		if err != nil || !allowed {
-			errorMsg := "Access to this file path is not allowed by server configuration"
-			if err != nil {
-				errorMsg = fmt.Sprintf("%s: %v", errorMsg, err)
-			}
-			result := SearchInFileResult{
-				Success:    false,
-				Error:      errorMsg,
-				Matches:    []MatchResult{},
-				MatchCount: 0,
-			}
-			return utils.CreateSuccessResponse(result), nil
-		}
-	}
+	return t.ExecuteContext(context.Background(), args)
+}
 
-	// Check if file exists
-	_, err := os.Stat(args.FilePath)
-	if os.IsNotExist(err) {
-		result := SearchInFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("File %s does not exist", args.FilePath),
-			Matches:    []MatchResult{},
-			MatchCount: 0,
-		}
-		return utils.CreateSuccessResponse(result), nil
-	} else if err != nil {
-		return utils.CreateErrorResponse(fmt.Sprintf("Error checking file: %v", err)), nil
+// ExecuteContext searches in a file, reporting what it read on the call in ctx
+func (t *SearchFileTool) ExecuteContext(ctx context.Context, args SearchInFileArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
 	}
 
 	// Open the file
 	file, err := os.Open(args.FilePath)
 	if err != nil {
-		result := SearchInFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("Error opening file: %v", err),
-			Matches:    []MatchResult{},
-			MatchCount: 0,
-		}
-		return utils.CreateSuccessResponse(result), nil
+		return nil, fileError(err, args.FilePath, "opening file")
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fileError(err, args.FilePath, "checking file")
+	}
+
 	// Prepare regex options
 	var regexPattern string
 	if args.CaseSensitive {
@@ -117,23 +119,31 @@ func (t *SearchFileTool) Execute(args SearchInFileArgs) (*mcp.ToolResponse, erro
 	// Compile regex
 	regex, err := regexp.Compile(regexPattern)
 	if err != nil {
-		result := SearchInFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("Invalid regular expression: %v", err),
-			Matches:    []MatchResult{},
-			MatchCount: 0,
-		}
-		return utils.CreateSuccessResponse(result), nil
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Invalid regular expression: %v", err)
+	}
+
+	// Search files in other encodings as UTF-8
+	sniffed, _, err := sniffFile(file)
+	if err != nil {
+		return nil, fileError(err, args.FilePath, "reading file")
+	}
+	encoding := charset.Default
+	if !sniffed.Binary {
+		encoding = sniffed.Encoding
 	}
 
 	// Search file
 	matches := []MatchResult{}
-	scanner := bufio.NewScanner(file)
+	// The search usually reads the whole file, so hash it on the way
+	content := newHashingReader(file)
+	scanner := bufio.NewScanner(charset.NewReader(content, encoding))
 	lineNum := 0
+	call := CallFromContext(ctx)
 
 	for scanner.Scan() {
 		lineNum++
 		line := scanner.Text()
+		call.AddBytesRead(int64(len(line) + 1))
 
 		if regex.MatchString(line) {
 			matches = append(matches, MatchResult{
@@ -149,21 +159,27 @@ func (t *SearchFileTool) Execute(args SearchInFileArgs) (*mcp.ToolResponse, erro
 	}
 
 	if err := scanner.Err(); err != nil {
-		result := SearchInFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("Error reading file: %v", err),
-			Matches:    []MatchResult{},
-			MatchCount: 0,
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, utils.NewToolError(utils.ErrTooLarge, "Line %d is longer than %d bytes", lineNum+1, bufio.MaxScanTokenSize)
 		}
-		return utils.CreateSuccessResponse(result), nil
+		return nil, fileError(err, args.FilePath, "reading file")
+	}
+	version, err := content.version(args.FilePath, file, info)
+	if err != nil {
+		return nil, fileError(err, args.FilePath, "reading file")
 	}
 
 	// Create result
 	result := SearchInFileResult{
-		Success:    true,
-		Matches:    matches,
-		MatchCount: len(matches),
-		Truncated:  args.MaxMatches > 0 && len(matches) >= args.MaxMatches,
+		Success:     true,
+		Matches:     matches,
+		MatchCount:  len(matches),
+		Truncated:   args.MaxMatches > 0 && len(matches) >= args.MaxMatches,
+		Encoding:    encoding,
+		FileVersion: version,
+	}
+	if result.Truncated {
+		slog.WarnContext(ctx, "Search results truncated", "path", args.FilePath, "max_matches", args.MaxMatches)
 	}
 
 	return utils.CreateSuccessResponse(result), nil
@@ -1,33 +1,96 @@
 package tools
 
 import (
-	"fmt"
-	"os"
-	"strings"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
 
 	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/charset"
 	"mcp-server/internal/config"
 	"mcp-server/internal/utils"
 )
 
 // ShowFileArgs defines the arguments for the show_file tool
 type ShowFileArgs struct {
-	FilePath  string `json:"file_path" jsonschema:"required,description=Path to the file to display"`
-	StartLine int    `json:"start_line" jsonschema:"description=Line number to start from (1-based indexing)"`
-	NumLines  *int   `json:"num_lines" jsonschema:"description=Number of lines to display (defaults to all lines)"`
+	FilePath   string `json:"file_path" jsonschema:"required,minLength=1,description=Path to the file to display"`
+	StartLine  int    `json:"start_line" jsonschema:"description=Line number to start from (1-based indexing); negative numbers count from the end as tail does, -1 being the last line"`
+	NumLines   *int   `json:"num_lines" jsonschema:"minimum=0,description=Number of lines to display (defaults to all lines)"`
+	ByteOffset *int64 `json:"byte_offset" jsonschema:"minimum=0,description=Byte offset to start from instead of start_line; display starts at the beginning of the line containing it"`
+	Tail       *int   `json:"tail" jsonschema:"minimum=1,description=Show the last N lines of the file instead of a range"`
+	Format     string `json:"format" jsonschema:"enum=text,enum=hex,enum=base64,description=How to show the file: 'text' (the default; binary files are refused), 'hex' (an xxd-style dump) or 'base64'"`
+	Length     *int64 `json:"length" jsonschema:"minimum=1,maximum=10485760,description=Number of bytes to show in hex or base64 format, from byte_offset (defaults to 4096 for hex and the rest of the file for base64)"`
+
+	LineNumbers  bool  `json:"line_numbers" jsonschema:"description=Prefix each line with its line number, like cat -n"`
+	Highlight    []int `json:"highlight" jsonschema:"maxItems=1000,description=Line numbers to mark with > in the gutter, such as the matches of an earlier search; implies line_numbers"`
+	AroundLine   *int  `json:"around_line" jsonschema:"minimum=1,description=Show this line with context_lines lines either side of it, instead of a range; the line is marked as with highlight"`
+	ContextLines *int  `json:"context_lines" jsonschema:"minimum=0,maximum=1000,description=Number of lines to show either side of around_line (defaults to 5)"`
+}
+
+// Paths returns the file to be shown, or the archive it is in, for the server's
+// path policy check
+func (a ShowFileArgs) Paths() []PathArg {
+	return []PathArg{{Path: policyPath(a.FilePath), What: "file path"}}
 }
 
 // ShowFileResult defines the result of the show_file tool
 type ShowFileResult struct {
 	Success    bool   `json:"success"`
-	Error      string `json:"error,omitempty"`
 	Content    string `json:"content"`
 	LinesShown int    `json:"lines_shown"`
-	TotalLines int    `json:"total_lines"`
 	StartLine  int    `json:"start_line"`
 	EndLine    int    `json:"end_line"`
+
+	// TotalLines is omitted when counting them would mean reading far past the
+	// lines shown
+	TotalLines int `json:"total_lines,omitempty"`
+
+	// StartOffset and EndOffset are the byte offsets of the start of the first line
+	// shown and of the line after the last; pass EndOffset as byte_offset to read on
+	StartOffset int64 `json:"start_offset"`
+	EndOffset   int64 `json:"end_offset"`
+
+	// Highlighted lists the marked lines that were shown
+	Highlighted []int `json:"highlighted,omitempty"`
+
+	// Encoding is the file's detected encoding; content is always UTF-8, and for
+	// files converted from another encoding the offsets are into the UTF-8 text
+	charset.Encoding
+
+	// FileVersion is the version of the file that was read
+	FileVersion
+}
+
+// ShowFileBytesResult is the result of the show_file tool for the hex and base64
+// formats, and for images
+type ShowFileBytesResult struct {
+	Success  bool   `json:"success"`
+	Format   string `json:"format"`
+	Content  string `json:"content,omitempty"`
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+	MimeType string `json:"mime_type"`
+
+	// FileVersion is the version of the file that was read
+	FileVersion
 }
 
+var (
+	// hexDumpLength is how many bytes a hex dump shows by default
+	hexDumpLength int64 = 4 << 10
+
+	// maxBytesLength is the most a hex or base64 read, or an image, may return
+	maxBytesLength int64 = 10 << 20
+
+	// maxConvertSize is the largest file show_file converts to UTF-8
+	maxConvertSize int64 = 64 << 20
+
+	// defaultContextLines is how many lines are shown either side of around_line
+	defaultContextLines = 5
+)
+
 // ShowFileTool implements the show_file tool
 type ShowFileTool struct {
 	config *config.ServerConfig
@@ -53,115 +116,327 @@ func (t *ShowFileTool) Description() string {
 	return "Show contents of a file with options to display specific line ranges"
 }
 
+// Title returns the tool's display name
+func (t *ShowFileTool) Title() string {
+	return "Show File"
+}
+
+// Annotations describes the tool's behaviour
+func (t *ShowFileTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
 // Execute shows file contents with the provided arguments
 func (t *ShowFileTool) Execute(args ShowFileArgs) (*mcp.ToolResponse, error) {
-	// Check if path is allowed by configuration
-	if t.config != nil {
-		allowed, err := t.config.IsPathAllowed(args.FilePath)
-		if err != nil || !allowed {
-			errorMsg := "Access to this file path is not allowed by server configuration"
-			if err != nil {
-				errorMsg = fmt.Sprintf("%s: %v", errorMsg, err)
-			}
-			result := ShowFileResult{
-				Success:    false,
-				Error:      errorMsg,
-				Content:    "",
-				LinesShown: 0,
-				TotalLines: 0,
-			}
-			return utils.CreateSuccessResponse(result), nil
-		}
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext shows file contents, reporting what it read on the call in ctx
+func (t *ShowFileTool) ExecuteContext(ctx context.Context, args ShowFileArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
 	}
 
 	// Check if file exists
-	fileInfo, err := os.Stat(args.FilePath)
+	file, err := openFileSource(args.FilePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			result := ShowFileResult{
-				Success:    false,
-				Error:      fmt.Sprintf("File %s does not exist", args.FilePath),
-				Content:    "",
-				LinesShown: 0,
-				TotalLines: 0,
-			}
-			return utils.CreateSuccessResponse(result), nil
+		return nil, err
+	}
+	defer file.Close()
+
+	switch args.Format {
+	case "hex", "base64":
+		if args.StartLine != 0 || args.NumLines != nil || args.Tail != nil || args.AroundLine != nil {
+			return nil, utils.NewToolError(utils.ErrInvalidArgs, "start_line, num_lines, tail and around_line cannot be used with format %s; use byte_offset and length", args.Format)
+		}
+		if args.LineNumbers || len(args.Highlight) > 0 {
+			return nil, utils.NewToolError(utils.ErrInvalidArgs, "line_numbers and highlight cannot be used with format %s", args.Format)
 		}
-		return utils.CreateErrorResponse(fmt.Sprintf("Error checking file: %v", err)), nil
+		return t.showBytes(ctx, args, file)
+	case "", "text":
+	default:
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Invalid format: must be text, hex or base64, got %q", args.Format).
+			WithDetails(map[string]interface{}{"field": "format"})
+	}
+
+	if args.Length != nil {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "length can only be used with format hex or base64")
+	}
+	if args.Tail != nil && (args.StartLine != 0 || args.ByteOffset != nil || args.NumLines != nil) {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "tail cannot be used with start_line, byte_offset or num_lines")
+	}
+	if args.ByteOffset != nil && args.StartLine != 0 {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "start_line and byte_offset cannot be used together")
+	}
+	if args.AroundLine != nil && (args.StartLine != 0 || args.ByteOffset != nil || args.NumLines != nil || args.Tail != nil) {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "around_line cannot be used with start_line, byte_offset, num_lines or tail")
+	}
+	if args.ContextLines != nil && args.AroundLine == nil {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "context_lines can only be used with around_line")
 	}
 
-	// Don't read directories
-	if fileInfo.IsDir() {
-		result := ShowFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("%s is a directory, not a file", args.FilePath),
-			Content:    "",
-			LinesShown: 0,
-			TotalLines: 0,
+	// Binary files would come out as garbage, so they are refused unless they are
+	// images the client can show
+	sniffed, n, err := sniffFile(file)
+	CallFromContext(ctx).AddBytesRead(n)
+	if err != nil {
+		return nil, fileError(err, args.FilePath, "reading file")
+	}
+	if sniffed.Binary {
+		if sniffed.Image && file.size <= maxBytesLength {
+			return t.showImage(ctx, args, file, sniffed)
 		}
-		return utils.CreateSuccessResponse(result), nil
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "%s is a binary file (%s); use format hex or base64 to show it", args.FilePath, sniffed.Kind).
+			WithDetails(map[string]interface{}{"binary": true, "kind": sniffed.Kind, "mime_type": sniffed.MimeType, "size": file.size})
 	}
 
-	// Read file content
-	content, err := os.ReadFile(args.FilePath)
+	result, err := readText(ctx, args, file, sniffed, 0)
 	if err != nil {
-		result := ShowFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("Error reading file: %v", err),
-			Content:    "",
-			LinesShown: 0,
-			TotalLines: 0,
+		return nil, err
+	}
+
+	return utils.CreateSuccessResponse(result), nil
+}
+
+// readText reads the lines args asks for from file, a text file in the encoding
+// sniffed. If limit is not zero, it stops after the first line that takes the
+// content past limit bytes.
+func readText(ctx context.Context, args ShowFileArgs, file *fileSource, sniffed fileType, limit int) (ShowFileResult, error) {
+	// Stream to the requested lines rather than reading the whole file. Files in
+	// other encodings are converted to UTF-8 in memory first.
+	var lines *lineReader
+	if sniffed.Encoding.IsDefault() {
+		lines = newLineReader(file.lineSource, file.size, file.index)
+	} else {
+		if file.size > maxConvertSize {
+			return ShowFileResult{}, utils.NewToolError(utils.ErrTooLarge, "%s is %s and too large to convert to UTF-8 (limit %d bytes); use format hex to show it", args.FilePath, sniffed.Encoding.Name, maxConvertSize).
+				WithDetails(map[string]interface{}{"size": file.size, "limit": maxConvertSize, "encoding": sniffed.Encoding.Name})
+		}
+		data, err := io.ReadAll(file)
+		CallFromContext(ctx).AddBytesRead(int64(len(data)))
+		if err != nil {
+			return ShowFileResult{}, fileError(err, args.FilePath, "reading file")
 		}
-		return utils.CreateSuccessResponse(result), nil
+		text := charset.Decode(data, sniffed.Encoding)
+		lines = newLineReader(bytes.NewReader(text), int64(len(text)), nil)
+	}
+	if sniffed.Encoding.IsDefault() {
+		defer func() { CallFromContext(ctx).AddBytesRead(lines.read) }()
 	}
 
-	// Split into lines
-	lines := strings.Split(string(content), "\n")
-	totalLines := len(lines)
+	if err := checkByteOffset(args.ByteOffset, lines.size); err != nil {
+		return ShowFileResult{}, err
+	}
 
 	// Ensure start line is valid
 	startLine := args.StartLine
-	if startLine < 1 {
+	if startLine == 0 {
 		startLine = 1
 	}
 
-	// Check if start line is beyond file length
-	if startLine > totalLines {
-		result := ShowFileResult{
-			Success:    false,
-			Error:      fmt.Sprintf("Start line %d is beyond the file length (%d lines)", startLine, totalLines),
-			Content:    "",
-			LinesShown: 0,
-			TotalLines: totalLines,
+	numLines := args.NumLines
+	if args.Tail != nil {
+		startLine = -*args.Tail
+	}
+
+	// A window around a line starts context lines before it, or at the top of the file
+	marked := make(map[int]bool, len(args.Highlight))
+	for _, line := range args.Highlight {
+		marked[line] = true
+	}
+	if args.AroundLine != nil {
+		contextLines := defaultContextLines
+		if args.ContextLines != nil {
+			contextLines = *args.ContextLines
 		}
-		return utils.CreateSuccessResponse(result), nil
+		startLine = max(*args.AroundLine-contextLines, 1)
+		window := *args.AroundLine - startLine + contextLines + 1
+		numLines = &window
+		marked[*args.AroundLine] = true
 	}
 
-	// Convert to 0-based index
-	startIndex := startLine - 1
+	if startLine < 0 || args.ByteOffset != nil {
+		// Lines counted from the end are found by reading backwards from it, then
+		// numbered by counting forwards to them
+		var offset int64
+		if args.ByteOffset != nil {
+			offset = *args.ByteOffset
+		} else {
+			var found int
+			var err error
+			if offset, found, err = lines.offsetFromEnd(-startLine); err != nil {
+				return ShowFileResult{}, fileError(err, args.FilePath, "reading file")
+			}
+			// Counted from the end, the empty line after a final newline is not a
+			// line, so a tail, or a range that runs to the end, stops before it
+			if numLines == nil || *numLines > found {
+				numLines = &found
+			}
+		}
+		if err := lines.seekOffset(offset); err != nil {
+			return ShowFileResult{}, fileError(err, args.FilePath, "reading file")
+		}
+		startLine = lines.line
+	} else {
+		found, err := lines.seekLine(startLine)
+		if err != nil {
+			return ShowFileResult{}, fileError(err, args.FilePath, "reading file")
+		}
+		// Check if start line is beyond file length
+		if !found {
+			totalLines := lines.line
+			return ShowFileResult{}, utils.NewToolError(utils.ErrInvalidArgs, "Start line %d is beyond the file length (%d lines)", startLine, totalLines).
+				WithDetails(map[string]interface{}{"total_lines": totalLines})
+		}
+	}
+	startOffset := lines.offset
 
-	// Determine end index
-	endIndex := totalLines
-	if args.NumLines != nil {
-		endIndex = startIndex + *args.NumLines
-		if endIndex > totalLines {
-			endIndex = totalLines
+	// Read the requested lines
+	var content bytes.Buffer
+	shown := 0
+	for !lines.done && (numLines == nil || shown < *numLines) && (limit == 0 || content.Len() <= limit) {
+		if shown > 0 {
+			content.WriteByte('\n')
+		}
+		if err := lines.next(&content); err != nil {
+			return ShowFileResult{}, fileError(err, args.FilePath, "reading file")
 		}
+		shown++
 	}
+	endOffset := lines.offset
 
-	// Extract requested lines
-	selectedLines := lines[startIndex:endIndex]
-	selectedContent := strings.Join(selectedLines, "\n")
+	if args.AroundLine != nil && *args.AroundLine >= startLine+shown {
+		totalLines := startLine + shown - 1
+		return ShowFileResult{}, utils.NewToolError(utils.ErrInvalidArgs, "Line %d is beyond the file length (%d lines)", *args.AroundLine, totalLines).
+			WithDetails(map[string]interface{}{"total_lines": totalLines})
+	}
+
+	totalLines, err := lines.totalLines(totalLinesScanLimit)
+	if err != nil {
+		return ShowFileResult{}, fileError(err, args.FilePath, "reading file")
+	}
+	version, err := file.rangedVersion()
+	if err != nil {
+		return ShowFileResult{}, err
+	}
 
-	// Create result
 	result := ShowFileResult{
-		Success:    true,
-		Content:    selectedContent,
-		LinesShown: len(selectedLines),
-		TotalLines: totalLines,
-		StartLine:  startLine,
-		EndLine:    startIndex + len(selectedLines) + 1,
+		Success:     true,
+		Content:     content.String(),
+		LinesShown:  shown,
+		TotalLines:  totalLines,
+		StartLine:   startLine,
+		EndLine:     startLine + shown,
+		StartOffset: startOffset,
+		EndOffset:   endOffset,
+		Encoding:    sniffed.Encoding,
+		FileVersion: version,
+	}
+
+	// Marked lines are shown in a gutter of their own, next to the line numbers
+	if len(marked) > 0 {
+		for line := startLine; line < startLine+shown; line++ {
+			if marked[line] {
+				result.Highlighted = append(result.Highlighted, line)
+			}
+		}
+	}
+	if (args.LineNumbers || len(marked) > 0) && shown > 0 {
+		result.Content = numberLines(result.Content, startLine, marked)
+	}
+
+	return result, nil
+}
+
+// showBytes shows part of a file as a hex dump or base64
+func (t *ShowFileTool) showBytes(ctx context.Context, args ShowFileArgs, file *fileSource) (*mcp.ToolResponse, error) {
+	if err := checkByteOffset(args.ByteOffset, file.size); err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	if args.ByteOffset != nil {
+		offset = *args.ByteOffset
+	}
+	remaining := file.size - offset
+
+	var length int64
+	switch {
+	case args.Length != nil:
+		length = min(*args.Length, remaining)
+	case args.Format == "hex":
+		length = min(hexDumpLength, remaining)
+	default:
+		length = remaining
+	}
+	if length > maxBytesLength {
+		return nil, utils.NewToolError(utils.ErrTooLarge, "%d bytes is too much to show at once (limit %d); pass length to show part of the file", length, maxBytesLength).
+			WithDetails(map[string]interface{}{"size": file.size, "limit": maxBytesLength})
+	}
+
+	data := make([]byte, length)
+	n, err := file.ReadAt(data, offset)
+	CallFromContext(ctx).AddBytesRead(int64(n))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fileError(err, args.FilePath, "reading file")
+	}
+	data = data[:n]
+
+	sniffed, _, err := sniffFile(file)
+	if err != nil {
+		return nil, fileError(err, args.FilePath, "reading file")
+	}
+
+	var version FileVersion
+	if int64(len(data)) == file.size {
+		version = file.contentVersion(data)
+	} else if version, err = file.rangedVersion(); err != nil {
+		return nil, err
+	}
+
+	result := ShowFileBytesResult{
+		Success:     true,
+		Format:      args.Format,
+		Offset:      offset,
+		Length:      int64(len(data)),
+		MimeType:    sniffed.MimeType,
+		FileVersion: version,
+	}
+	if args.Format == "hex" {
+		result.Content = hexDump(data, offset)
+	} else {
+		result.Content = base64.StdEncoding.EncodeToString(data)
 	}
 
 	return utils.CreateSuccessResponse(result), nil
 }
+
+// showImage returns an image as MCP image content, after a summary of it
+func (t *ShowFileTool) showImage(ctx context.Context, args ShowFileArgs, file *fileSource, sniffed fileType) (*mcp.ToolResponse, error) {
+	data, err := io.ReadAll(file)
+	CallFromContext(ctx).AddBytesRead(int64(len(data)))
+	if err != nil {
+		return nil, fileError(err, args.FilePath, "reading file")
+	}
+
+	summary := utils.CreateSuccessResponse(ShowFileBytesResult{
+		Success:     true,
+		Format:      "image",
+		Length:      int64(len(data)),
+		MimeType:    sniffed.MimeType,
+		FileVersion: file.contentVersion(data),
+	})
+	return mcp.NewToolResponse(append(summary.Content, mcp.NewImageContent(base64.StdEncoding.EncodeToString(data), sniffed.MimeType))...), nil
+}
+
+// checkByteOffset checks that a byte_offset argument is within size bytes
+func checkByteOffset(offset *int64, size int64) error {
+	if offset != nil && *offset > size {
+		return utils.NewToolError(utils.ErrInvalidArgs, "Byte offset %d is beyond the file size (%d bytes)", *offset, size).
+			WithDetails(map[string]interface{}{"size": size})
+	}
+	return nil
+}
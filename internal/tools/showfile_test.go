@@ -0,0 +1,494 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-server/internal/charset"
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// writeTestFile writes content to a file in a temporary directory
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// numberedLines returns n lines reading "line 1", "line 2", ...
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func intPtr(n int) *int { return &n }
+
+func int64Ptr(n int64) *int64 { return &n }
+
+func TestShowFileTool_LineRanges(t *testing.T) {
+	path := writeTestFile(t, "a\nb\nc\n")
+
+	tests := []struct {
+		name     string
+		args     ShowFileArgs
+		expected ShowFileResult
+	}{
+		{
+			name:     "whole file",
+			args:     ShowFileArgs{},
+			expected: ShowFileResult{Content: "a\nb\nc\n", LinesShown: 4, StartLine: 1, EndLine: 5, TotalLines: 4, EndOffset: 6},
+		},
+		{
+			name:     "range",
+			args:     ShowFileArgs{StartLine: 2, NumLines: intPtr(1)},
+			expected: ShowFileResult{Content: "b", LinesShown: 1, StartLine: 2, EndLine: 3, TotalLines: 4, StartOffset: 2, EndOffset: 4},
+		},
+		{
+			name:     "no lines",
+			args:     ShowFileArgs{StartLine: 3, NumLines: intPtr(0)},
+			expected: ShowFileResult{LinesShown: 0, StartLine: 3, EndLine: 3, TotalLines: 4, StartOffset: 4, EndOffset: 4},
+		},
+		{
+			name:     "range past the end",
+			args:     ShowFileArgs{StartLine: 3, NumLines: intPtr(10)},
+			expected: ShowFileResult{Content: "c\n", LinesShown: 2, StartLine: 3, EndLine: 5, TotalLines: 4, StartOffset: 4, EndOffset: 6},
+		},
+		{
+			name:     "byte offset inside a line",
+			args:     ShowFileArgs{ByteOffset: int64Ptr(3), NumLines: intPtr(1)},
+			expected: ShowFileResult{Content: "b", LinesShown: 1, StartLine: 2, EndLine: 3, TotalLines: 4, StartOffset: 2, EndOffset: 4},
+		},
+		{
+			name:     "byte offset at a line start",
+			args:     ShowFileArgs{ByteOffset: int64Ptr(4)},
+			expected: ShowFileResult{Content: "c\n", LinesShown: 2, StartLine: 3, EndLine: 5, TotalLines: 4, StartOffset: 4, EndOffset: 6},
+		},
+		{
+			name:     "negative start line",
+			args:     ShowFileArgs{StartLine: -1},
+			expected: ShowFileResult{Content: "c", LinesShown: 1, StartLine: 3, EndLine: 4, TotalLines: 4, StartOffset: 4, EndOffset: 6},
+		},
+		{
+			name:     "negative start line with a range past the end",
+			args:     ShowFileArgs{StartLine: -2, NumLines: intPtr(10)},
+			expected: ShowFileResult{Content: "b\nc", LinesShown: 2, StartLine: 2, EndLine: 4, TotalLines: 4, StartOffset: 2, EndOffset: 6},
+		},
+		{
+			name:     "negative start line with a range",
+			args:     ShowFileArgs{StartLine: -3, NumLines: intPtr(2)},
+			expected: ShowFileResult{Content: "a\nb", LinesShown: 2, StartLine: 1, EndLine: 3, TotalLines: 4, EndOffset: 4},
+		},
+		{
+			name:     "tail",
+			args:     ShowFileArgs{Tail: intPtr(2)},
+			expected: ShowFileResult{Content: "b\nc", LinesShown: 2, StartLine: 2, EndLine: 4, TotalLines: 4, StartOffset: 2, EndOffset: 6},
+		},
+		{
+			name:     "tail longer than the file",
+			args:     ShowFileArgs{Tail: intPtr(10)},
+			expected: ShowFileResult{Content: "a\nb\nc", LinesShown: 3, StartLine: 1, EndLine: 4, TotalLines: 4, EndOffset: 6},
+		},
+		{
+			name:     "line numbers",
+			args:     ShowFileArgs{StartLine: 2, NumLines: intPtr(1), LineNumbers: true},
+			expected: ShowFileResult{Content: "     2\tb", LinesShown: 1, StartLine: 2, EndLine: 3, TotalLines: 4, StartOffset: 2, EndOffset: 4},
+		},
+		{
+			name:     "highlight",
+			args:     ShowFileArgs{NumLines: intPtr(2), Highlight: []int{2, 7}},
+			expected: ShowFileResult{Content: "      1\ta\n>     2\tb", LinesShown: 2, StartLine: 1, EndLine: 3, TotalLines: 4, EndOffset: 4, Highlighted: []int{2}},
+		},
+		{
+			name:     "around line",
+			args:     ShowFileArgs{AroundLine: intPtr(2), ContextLines: intPtr(1)},
+			expected: ShowFileResult{Content: "      1\ta\n>     2\tb\n      3\tc", LinesShown: 3, StartLine: 1, EndLine: 4, TotalLines: 4, EndOffset: 6, Highlighted: []int{2}},
+		},
+		{
+			name:     "around line near the end",
+			args:     ShowFileArgs{AroundLine: intPtr(3)},
+			expected: ShowFileResult{Content: "      1\ta\n      2\tb\n>     3\tc\n      4\t", LinesShown: 4, StartLine: 1, EndLine: 5, TotalLines: 4, EndOffset: 6, Highlighted: []int{3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.FilePath = path
+			tt.expected.Success = true
+			tt.expected.Encoding = charset.Default
+
+			// The file's version is checked by TestShowFileTool_Version
+			result := runTool[ShowFileResult](t, NewShowFileTool().Execute, tt.args)
+			result.FileVersion = FileVersion{}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+// sha256Hex returns the hex SHA-256 of content
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestShowFileTool_Version(t *testing.T) {
+	path := writeTestFile(t, "a\nb\n")
+
+	result := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, NumLines: intPtr(1)})
+	if result.SHA256 != sha256Hex("a\nb\n") || result.Size != 4 || result.ModTime.IsZero() {
+		t.Errorf("Expected the version of the whole file, got %+v", result.FileVersion)
+	}
+
+	if err := os.WriteFile(path, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path}); result.SHA256 != sha256Hex("a\nb\nc\n") {
+		t.Errorf("Expected the hash of the changed file, got %s", result.SHA256)
+	}
+
+	old := maxRangedHashSize
+	maxRangedHashSize = 4
+	defer func() { maxRangedHashSize = old }()
+	if err := os.WriteFile(path, []byte("a\nb\nc\nd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result = runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, NumLines: intPtr(1)})
+	if result.SHA256 != "" || !result.HashSkipped || result.Size != 8 {
+		t.Errorf("Expected the hash of a large file to be skipped, got %+v", result.FileVersion)
+	}
+
+	// Reading the whole file hashes it, and later ranged reads reuse the hash
+	search := runTool[SearchInFileResult](t, NewSearchFileTool().Execute, SearchInFileArgs{FilePath: path, Pattern: "d"})
+	if search.SHA256 != sha256Hex("a\nb\nc\nd\n") {
+		t.Errorf("Expected search_in_file to hash the file it read, got %+v", search.FileVersion)
+	}
+	if stat := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: path}); stat.SHA256 != sha256Hex("a\nb\nc\nd\n") {
+		t.Errorf("Expected stat_file to hash a large file, got %q", stat.SHA256)
+	}
+	result = runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, NumLines: intPtr(1)})
+	if result.SHA256 != sha256Hex("a\nb\nc\nd\n") || result.HashSkipped {
+		t.Errorf("Expected the cached hash, got %+v", result.FileVersion)
+	}
+}
+
+func TestShowFileTool_DirectCallsCheckConfig(t *testing.T) {
+	path := writeTestFile(t, "secret\n")
+	tool := NewShowFileTool()
+	tool.SetConfig(&config.ServerConfig{AllowedPaths: []string{t.TempDir()}})
+
+	_, err := tool.Execute(ShowFileArgs{FilePath: path})
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrPathDenied {
+		t.Errorf("Expected a direct call outside the allowed paths to be denied, got %v", err)
+	}
+
+	// The server checks the paths of its calls before they reach the tool
+	ctx := WithCall(context.Background(), &Call{})
+	if _, err := tool.ExecuteContext(ctx, ShowFileArgs{FilePath: path}); err != nil {
+		t.Errorf("Expected a call made by the server not to be checked again, got %v", err)
+	}
+}
+
+func TestShowFileTool_InvalidRanges(t *testing.T) {
+	path := writeTestFile(t, "a\nb")
+
+	tests := []struct {
+		name string
+		args ShowFileArgs
+	}{
+		{"start line beyond the end", ShowFileArgs{StartLine: 3}},
+		{"byte offset beyond the end", ShowFileArgs{ByteOffset: int64Ptr(4)}},
+		{"start line and byte offset", ShowFileArgs{StartLine: 1, ByteOffset: int64Ptr(0)}},
+		{"tail and start line", ShowFileArgs{StartLine: 1, Tail: intPtr(1)}},
+		{"tail and num lines", ShowFileArgs{Tail: intPtr(1), NumLines: intPtr(1)}},
+		{"around line beyond the end", ShowFileArgs{AroundLine: intPtr(3)}},
+		{"around line and start line", ShowFileArgs{AroundLine: intPtr(1), StartLine: 1}},
+		{"context lines alone", ShowFileArgs{ContextLines: intPtr(2)}},
+		{"line numbers in hex", ShowFileArgs{Format: "hex", LineNumbers: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.FilePath = path
+			_, err := NewShowFileTool().Execute(tt.args)
+			var toolErr *utils.ToolError
+			if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrInvalidArgs {
+				t.Errorf("Expected an INVALID_ARGS error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestShowFileTool_NegativeStartLineMatchesTail(t *testing.T) {
+	for _, content := range []string{"a\nb\nc\n", "a\nb\nc", "a\nb\nc\n\n"} {
+		path := writeTestFile(t, content)
+		for n := 1; n <= 5; n++ {
+			fromEnd := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, StartLine: -n})
+			tail := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, Tail: intPtr(n)})
+			if fromEnd.Content != tail.Content || fromEnd.StartLine != tail.StartLine || fromEnd.LinesShown != tail.LinesShown {
+				t.Errorf("%q: expected start_line -%d to show what tail %d does (%q from line %d), got %q from line %d",
+					content, n, n, tail.Content, tail.StartLine, fromEnd.Content, fromEnd.StartLine)
+			}
+		}
+	}
+}
+
+func TestShowFileTool_LongLines(t *testing.T) {
+	long := strings.Repeat("x", 200<<10)
+	path := writeTestFile(t, "short\n"+long+"\nend")
+
+	result := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, StartLine: 2, NumLines: intPtr(1)})
+	if result.Content != long {
+		t.Errorf("Expected the long line in full, got %d bytes", len(result.Content))
+	}
+
+	result = runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, ByteOffset: int64Ptr(100 << 10)})
+	if result.StartLine != 2 || result.Content != long+"\nend" {
+		t.Errorf("Expected to start at the long line, got line %d", result.StartLine)
+	}
+
+	result = runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, Tail: intPtr(2)})
+	if result.StartLine != 2 || result.Content != long+"\nend" {
+		t.Errorf("Expected the tail to start at the long line, got line %d", result.StartLine)
+	}
+}
+
+func TestShowFileTool_LineIndex(t *testing.T) {
+	defer func(interval int, minSize, scanLimit int64) {
+		lineIndexInterval, lineIndexMinSize, totalLinesScanLimit = interval, minSize, scanLimit
+	}(lineIndexInterval, lineIndexMinSize, totalLinesScanLimit)
+	lineIndexInterval, lineIndexMinSize, totalLinesScanLimit = 10, 0, 0
+
+	path := writeTestFile(t, numberedLines(1000))
+
+	// Reading stops after the lines asked for, so the total is not known yet
+	result := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, StartLine: 500, NumLines: intPtr(2)})
+	if result.Content != "line 500\nline 501" || result.TotalLines != 0 {
+		t.Errorf("Expected lines 500-501 without a total, got %+v", result)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := lineIndexes.get(path, info)
+	if len(index.offsets) != 51 {
+		t.Errorf("Expected the scan to index 51 lines, got %d", len(index.offsets))
+	}
+
+	// Reading to the end records the total, which later reads report
+	result = runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, StartLine: 999})
+	if result.Content != "line 999\nline 1000\n" || result.TotalLines != 1001 {
+		t.Errorf("Expected the last lines with the total, got %+v", result)
+	}
+
+	if indexed := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, StartLine: 731, NumLines: intPtr(1)}); indexed.Content != "line 731" || indexed.TotalLines != 1001 {
+		t.Errorf("Expected line 731 from the index with the total, got %+v", indexed)
+	}
+	if indexed := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, ByteOffset: &result.StartOffset}); indexed.StartLine != 999 {
+		t.Errorf("Expected byte offset %d to be line 999, got %d", result.StartOffset, indexed.StartLine)
+	}
+
+	if tail := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, Tail: intPtr(3)}); tail.StartLine != 998 || tail.Content != "line 998\nline 999\nline 1000" {
+		t.Errorf("Expected the last three lines numbered from 998, got %+v", tail)
+	}
+
+	// Changing the file discards its index
+	if err := os.WriteFile(path, []byte(numberedLines(20)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), info.ModTime().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewShowFileTool().Execute(ShowFileArgs{FilePath: path, StartLine: 731}); err == nil {
+		t.Error("Expected reading past the end of the rewritten file to fail")
+	}
+}
+
+func TestShowFileTool_Binary(t *testing.T) {
+	path := writeTestFile(t, "\x7fELF\x02\x01\x01\x00hello, world!\x00\xff")
+
+	_, err := NewShowFileTool().Execute(ShowFileArgs{FilePath: path})
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrInvalidArgs || toolErr.Details.(map[string]interface{})["kind"] != "ELF binary" {
+		t.Fatalf("Expected the binary to be refused, got %v", err)
+	}
+
+	resp, err := NewShowFileTool().Execute(ShowFileArgs{FilePath: path, Format: "hex"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var result ShowFileBytesResult
+	if err := json.Unmarshal([]byte(resp.Content[0].TextContent.Text), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	expected := "00000000: 7f45 4c46 0201 0100 6865 6c6c 6f2c 2077  .ELF....hello, w\n" +
+		"00000010: 6f72 6c64 2100 ff                        orld!..\n"
+	if result.Content != expected || result.Length != 23 || result.MimeType != "application/x-executable" {
+		t.Errorf("Expected the hex dump\n%s got %+v", expected, result)
+	}
+
+	resp, err = NewShowFileTool().Execute(ShowFileArgs{FilePath: path, Format: "base64", ByteOffset: int64Ptr(8), Length: int64Ptr(5)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(resp.Content[0].TextContent.Text), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Content != "aGVsbG8=" || result.Offset != 8 || result.Length != 5 {
+		t.Errorf("Expected hello in base64, got %+v", result)
+	}
+}
+
+func TestShowFileTool_Image(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	path := writeTestFile(t, png)
+
+	resp, err := NewShowFileTool().Execute(ShowFileArgs{FilePath: path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(resp.Content) != 2 || resp.Content[1].ImageContent == nil {
+		t.Fatalf("Expected a summary and image content, got %+v", resp.Content)
+	}
+	image := resp.Content[1].ImageContent
+	if image.MimeType != "image/png" || image.Data != base64.StdEncoding.EncodeToString([]byte(png)) {
+		t.Errorf("Expected the PNG as image content, got %+v", image)
+	}
+}
+
+func TestDetectFileType(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		binary bool
+	}{
+		{"text", "package main\n", false},
+		{"utf-8", "naïve café ☕\n", false},
+		{"nul byte", "abc\x00def", true},
+		{"invalid utf-8", "\x01\xfd\xfc\xfb abc", true},
+		{"utf-16", "\xff\xfeh\x00i\x00", false},
+		{"a little latin-1", "caf\xe9 au lait, cr\xe8me br\xfbl\xe9e and a long enough sentence", false},
+		{"magic number", "%PDF-1.7\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFileType([]byte(tt.sample), false); got.Binary != tt.binary {
+				t.Errorf("Expected binary=%v, got %+v", tt.binary, got)
+			}
+		})
+	}
+
+	// A character cut off by the end of the sample is not held against it
+	sample := []byte(strings.Repeat("€", 10))
+	if got := detectFileType(sample[:len(sample)-1], true); got.Binary {
+		t.Errorf("Expected a truncated sample to be text, got %+v", got)
+	}
+}
+
+func TestShowFileTool_ConvertsEncoding(t *testing.T) {
+	path := writeTestFile(t, "\xff\xfeh\x00\xe9\x00\n\x00w\x00o\x00r\x00l\x00d\x00")
+
+	result := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path, StartLine: 2})
+	if result.Content != "world" || result.StartLine != 2 || result.Encoding != (charset.Encoding{Name: charset.UTF16LE, BOM: true}) {
+		t.Errorf("Expected the second line converted from UTF-16, got %+v", result)
+	}
+
+	resp, err := NewSearchFileTool().Execute(SearchInFileArgs{FilePath: path, Pattern: "hé"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var search SearchInFileResult
+	if err := json.Unmarshal([]byte(resp.Content[0].TextContent.Text), &search); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if search.MatchCount != 1 || search.Matches[0].Content != "hé" || search.Encoding.Name != charset.UTF16LE {
+		t.Errorf("Expected to find the UTF-16 line, got %+v", search)
+	}
+}
+
+// writeTestZip writes a zip archive of files, given by name, to a temporary
+// directory
+func writeTestZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestShowFileTool_ArchiveMembers(t *testing.T) {
+	path := writeTestZip(t, map[string]string{
+		"docs/README.md": "# Title\nbody\n",
+		"big.txt":        strings.Repeat("x", 100),
+	})
+
+	result := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path + "!/docs/README.md", StartLine: 2, NumLines: intPtr(1)})
+	if result.Content != "body" || result.StartLine != 2 || result.TotalLines != 3 {
+		t.Errorf("Expected the second line of the member, got %+v", result)
+	}
+
+	old := maxArchiveMemberSize
+	maxArchiveMemberSize = 50
+	defer func() { maxArchiveMemberSize = old }()
+
+	tests := []struct {
+		member string
+		code   utils.ErrorCode
+	}{
+		{"missing.txt", utils.ErrNotFound},
+		{"docs", utils.ErrInvalidArgs},
+		{"big.txt", utils.ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.member, func(t *testing.T) {
+			_, err := NewShowFileTool().Execute(ShowFileArgs{FilePath: path + "!/" + tt.member})
+			var toolErr *utils.ToolError
+			if !errors.As(err, &toolErr) || toolErr.Code != tt.code {
+				t.Errorf("Expected a %s error, got %v", tt.code, err)
+			}
+		})
+	}
+
+	if paths := (ShowFileArgs{FilePath: path + "!/docs/README.md"}).Paths(); paths[0].Path != path {
+		t.Errorf("Expected the policy to check the archive, got %s", paths[0].Path)
+	}
+}
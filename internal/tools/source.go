@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+
+	"mcp-server/internal/archive"
+	"mcp-server/internal/utils"
+)
+
+// maxArchiveMemberSize is the largest archive member the read tools read into memory
+var maxArchiveMemberSize int64 = 64 << 20
+
+// fileSource is a file the read tools show: a file on disk, or a member of a zip or
+// tar archive read into memory
+type fileSource struct {
+	lineSource
+	size int64
+	// index is the file's line index; archive members and small files have none
+	index *lineIndex
+	// path and info describe a file on disk, whose version is worked out when a
+	// tool knows how much of it it read; info is nil for an archive member
+	path string
+	info os.FileInfo
+	// version is an archive member's version; its ModTime is the archive's
+	version FileVersion
+	close   func() error
+}
+
+// openFileSource opens the file at path, which may be a virtual path into an
+// archive such as release.tar.gz!/bin/tool
+func openFileSource(path string) (*fileSource, error) {
+	if archivePath, member, ok := archive.Split(path); ok {
+		info, err := os.Stat(archivePath)
+		if err != nil {
+			return nil, fileError(err, archivePath, "checking archive")
+		}
+		data, err := archive.ReadMember(archivePath, member, maxArchiveMemberSize)
+		if err != nil {
+			return nil, archiveError(err, path, archivePath)
+		}
+		hash := sha256.Sum256(data)
+		version := FileVersion{SHA256: hex.EncodeToString(hash[:]), Size: int64(len(data)), ModTime: info.ModTime()}
+		return &fileSource{lineSource: bytes.NewReader(data), size: int64(len(data)), version: version}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fileError(err, path, "checking file")
+	}
+
+	// Don't read directories
+	if info.IsDir() {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "%s is a directory, not a file", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fileError(err, path, "reading file")
+	}
+	return &fileSource{lineSource: file, size: info.Size(), index: lineIndexes.get(path, info), path: path, info: info, close: file.Close}, nil
+}
+
+// rangedVersion returns the version of the source for a call that read only part of it
+func (s *fileSource) rangedVersion() (FileVersion, error) {
+	if s.info == nil {
+		return s.version, nil
+	}
+	version, err := rangedVersion(s.path, s, s.info)
+	if err != nil {
+		return FileVersion{}, fileError(err, s.path, "reading file")
+	}
+	return version, nil
+}
+
+// contentVersion returns the version of the source for a call that read all of it
+// as data
+func (s *fileSource) contentVersion(data []byte) FileVersion {
+	if s.info == nil {
+		return s.version
+	}
+	return ContentVersion(s.path, s.info, data)
+}
+
+// Close closes the file, if the source is one
+func (s *fileSource) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// policyPath returns the path the path policy is checked against for path: the
+// archive, for a member of one
+func policyPath(path string) string {
+	if archivePath, _, ok := archive.Split(path); ok {
+		return archivePath
+	}
+	return path
+}
+
+// archiveError converts an error reading path, in the archive at archivePath, into
+// a ToolError
+func archiveError(err error, path, archivePath string) *utils.ToolError {
+	switch {
+	case errors.Is(err, archive.ErrTooLarge):
+		return utils.NewToolError(utils.ErrTooLarge, "%s is too large to read from the archive (limit %d bytes)", path, maxArchiveMemberSize).
+			WithDetails(map[string]interface{}{"path": path, "limit": maxArchiveMemberSize})
+	case errors.Is(err, archive.ErrIsDir):
+		return utils.NewToolError(utils.ErrInvalidArgs, "%s is a directory, not a file", path)
+	case errors.Is(err, archive.ErrUnsupported):
+		return utils.NewToolError(utils.ErrInvalidArgs, "%s is not a zip or tar archive", archivePath).
+			WithDetails(map[string]interface{}{"path": archivePath})
+	}
+	return fileError(err, path, "reading archive")
+}
@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/charset"
+	"mcp-server/internal/config"
+	"mcp-server/internal/utils"
+)
+
+// StatFileArgs defines the arguments for the stat_file tool
+type StatFileArgs struct {
+	Path string `json:"path" jsonschema:"required,minLength=1,description=Path of the file or directory to describe"`
+}
+
+// Paths returns the file to be described, for the server's path policy check
+func (a StatFileArgs) Paths() []PathArg {
+	return []PathArg{{Path: a.Path, What: "file path"}}
+}
+
+// StatFileResult defines the result of the stat_file tool. For a symlink, Type and
+// SymlinkTarget describe the link and everything else the file it points to.
+type StatFileResult struct {
+	Success bool   `json:"success"`
+	Type    string `json:"type"`
+	Size    int64  `json:"size"`
+	// Mode is as ls shows it, e.g. -rw-r--r--, and Permissions in octal, e.g. 0644
+	Mode        string    `json:"mode"`
+	Permissions string    `json:"permissions"`
+	Owner       string    `json:"owner,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	UID         *int      `json:"uid,omitempty"`
+	GID         *int      `json:"gid,omitempty"`
+	ModTime     time.Time `json:"mod_time"`
+
+	SymlinkTarget string `json:"symlink_target,omitempty"`
+	// BrokenSymlink is set when the target of a symlink does not exist
+	BrokenSymlink bool `json:"broken_symlink,omitempty"`
+
+	MimeType string `json:"mime_type,omitempty"`
+	Binary   bool   `json:"binary,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	// SHA256 is the hash read results give for the same version of a regular file,
+	// for use as write_file's expected_hash; it is omitted for large files
+	SHA256 string `json:"sha256,omitempty"`
+	// TotalLines counts lines as show_file does; it is omitted for binary files and
+	// for text files too large to count
+	TotalLines int `json:"total_lines,omitempty"`
+}
+
+// statLineCountLimit is the largest file stat_file counts the lines of
+var statLineCountLimit int64 = 64 << 20
+
+// StatFileTool implements the stat_file tool
+type StatFileTool struct {
+	config *config.ServerConfig
+}
+
+// NewStatFileTool creates a new StatFileTool instance
+func NewStatFileTool() *StatFileTool {
+	return &StatFileTool{}
+}
+
+// SetConfig sets the server configuration
+func (t *StatFileTool) SetConfig(cfg *config.ServerConfig) {
+	t.config = cfg
+}
+
+// Name returns the tool name
+func (t *StatFileTool) Name() string {
+	return "stat_file"
+}
+
+// Description returns the tool description
+func (t *StatFileTool) Description() string {
+	return "Describe a file or directory: its type, size, mode, owner, modification time, symlink target, MIME type and line count"
+}
+
+// Title returns the tool's display name
+func (t *StatFileTool) Title() string {
+	return "Stat File"
+}
+
+// Annotations describes the tool's behaviour
+func (t *StatFileTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
+// Execute describes a file with the provided arguments
+func (t *StatFileTool) Execute(args StatFileArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext describes a file, reporting what it read on the call in ctx
+func (t *StatFileTool) ExecuteContext(ctx context.Context, args StatFileArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
+	}
+
+	linkInfo, err := os.Lstat(args.Path)
+	if err != nil {
+		return nil, fileError(err, args.Path, "checking file")
+	}
+
+	result := StatFileResult{Success: true, Type: entryType(linkInfo.Mode())}
+
+	info := linkInfo
+	if linkInfo.Mode()&os.ModeSymlink != 0 {
+		if result.SymlinkTarget, err = os.Readlink(args.Path); err != nil {
+			return nil, fileError(err, args.Path, "reading symlink")
+		}
+		if info, err = os.Stat(args.Path); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, fileError(err, args.Path, "checking symlink target")
+			}
+			info = linkInfo
+			result.BrokenSymlink = true
+		}
+	}
+
+	result.Size = info.Size()
+	result.Mode = info.Mode().String()
+	result.Permissions = fmt.Sprintf("%04o", info.Mode().Perm())
+	result.ModTime = info.ModTime()
+	if uid, gid, ok := fileOwner(info); ok {
+		result.UID, result.GID = &uid, &gid
+		if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+			result.Owner = u.Username
+		}
+		if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+			result.Group = g.Name
+		}
+	}
+
+	switch {
+	case result.BrokenSymlink:
+	case info.IsDir():
+		result.MimeType = "inode/directory"
+	case info.Mode().IsRegular():
+		if err := describeContent(ctx, args.Path, info, &result); err != nil {
+			return nil, err
+		}
+	}
+
+	return utils.CreateSuccessResponse(result), nil
+}
+
+// describeContent fills in the MIME type, encoding, hash and line count of a regular
+// file
+func describeContent(ctx context.Context, path string, info os.FileInfo, result *StatFileResult) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileError(err, path, "reading file")
+	}
+	defer file.Close()
+
+	version, err := fileVersion(path, file, info)
+	if err != nil {
+		return fileError(err, path, "reading file")
+	}
+	result.SHA256 = version.SHA256
+
+	sniffed, n, err := sniffFile(file)
+	CallFromContext(ctx).AddBytesRead(n)
+	if err != nil {
+		return fileError(err, path, "reading file")
+	}
+	result.MimeType = sniffed.MimeType
+	result.Binary = sniffed.Binary
+	if sniffed.Binary {
+		return nil
+	}
+	result.Encoding = sniffed.Encoding.Name
+	if info.Size() > statLineCountLimit {
+		// The line index may already know from an earlier read
+		result.TotalLines = lineIndexes.get(path, info).totalLines()
+		return nil
+	}
+
+	if sniffed.Encoding.IsDefault() {
+		lines := newLineReader(file, info.Size(), lineIndexes.get(path, info))
+		result.TotalLines, err = lines.totalLines(statLineCountLimit)
+		CallFromContext(ctx).AddBytesRead(lines.read)
+	} else {
+		result.TotalLines, err = countLines(charset.NewReader(file, sniffed.Encoding))
+		if read, seekErr := file.Seek(0, io.SeekCurrent); seekErr == nil {
+			CallFromContext(ctx).AddBytesRead(read)
+		}
+	}
+	if err != nil {
+		return fileError(err, path, "reading file")
+	}
+	return nil
+}
+
+// countLines counts the lines of text from r the way show_file does: one more than
+// the number of newlines
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 64<<10)
+	lines := 1
+	for {
+		n, err := r.Read(buf)
+		lines += bytes.Count(buf[:n], []byte{'\n'})
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
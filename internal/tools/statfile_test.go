@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatFileTool(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"text.txt":   "a\nb\nc\n",
+		"latin1.txt": "caf\xe9\ncr\xe8me",
+		"image.png":  "\x89PNG\r\n\x1a\n\x00\x00",
+	})
+	if err := os.Chmod(filepath.Join(root, "text.txt"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("text.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("missing", filepath.Join(root, "broken")); err != nil {
+		t.Fatal(err)
+	}
+
+	text := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: filepath.Join(root, "text.txt")})
+	if text.Type != typeFile || text.Size != 6 || text.Permissions != "0640" || text.Mode != "-rw-r-----" {
+		t.Errorf("Unexpected result for a text file: %+v", text)
+	}
+	if text.MimeType != "text/plain" || text.Encoding != "utf-8" || text.TotalLines != 4 {
+		t.Errorf("Expected a 4-line UTF-8 text file, got %+v", text)
+	}
+	if text.UID == nil || *text.UID != os.Getuid() {
+		t.Errorf("Expected uid %d, got %v", os.Getuid(), text.UID)
+	}
+
+	if latin1 := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: filepath.Join(root, "latin1.txt")}); latin1.Encoding != "iso-8859-1" || latin1.TotalLines != 2 {
+		t.Errorf("Expected a 2-line Latin-1 file, got %+v", latin1)
+	}
+	if image := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: filepath.Join(root, "image.png")}); !image.Binary || image.MimeType != "image/png" || image.TotalLines != 0 {
+		t.Errorf("Expected a PNG image, got %+v", image)
+	}
+	if dir := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: root}); dir.Type != typeDirectory || dir.MimeType != "inode/directory" {
+		t.Errorf("Expected a directory, got %+v", dir)
+	}
+
+	link := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: filepath.Join(root, "link")})
+	if link.Type != typeSymlink || link.SymlinkTarget != "text.txt" || link.Size != 6 || link.TotalLines != 4 {
+		t.Errorf("Expected a symlink to text.txt, got %+v", link)
+	}
+	if broken := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: filepath.Join(root, "broken")}); !broken.BrokenSymlink || broken.SymlinkTarget != "missing" {
+		t.Errorf("Expected a broken symlink, got %+v", broken)
+	}
+}
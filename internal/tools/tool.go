@@ -12,10 +12,40 @@ type Tool interface {
 	// Description returns a detailed description of the tool
 	Description() string
 
+	// Title returns a short human-readable name for the tool, for display in clients
+	Title() string
+
+	// Annotations describes how the tool behaves, so clients can decide which calls
+	// need the user's confirmation
+	Annotations() Annotations
+
 	// Execute is implemented by each tool to run its specific functionality
 	// The actual signature will differ for each tool based on its argument type,
 	// but reflection is used to call it correctly
 	// Execute(args SomeArgsType) (*mcp.ToolResponse, error)
+	//
+	// Tools that can be interrupted may additionally implement
+	// ExecuteContext(ctx context.Context, args SomeArgsType) (*mcp.ToolResponse, error)
+	// which the server calls instead of Execute. The context is cancelled when the
+	// server shuts down and the call does not finish within the drain deadline.
+}
+
+// Annotations are hints about a tool's behaviour, advertised to clients in tools/list.
+// They describe the tool honestly but are not a security boundary.
+type Annotations struct {
+	// ReadOnly means the tool does not modify its environment
+	ReadOnly bool `json:"readOnlyHint"`
+
+	// Destructive means the tool may overwrite or delete data, rather than only add to it.
+	// It is meaningless for read-only tools.
+	Destructive bool `json:"destructiveHint"`
+
+	// Idempotent means calling the tool again with the same arguments has no further effect
+	Idempotent bool `json:"idempotentHint"`
+
+	// OpenWorld means the tool may interact with things outside the server's control,
+	// such as arbitrary programs or the network
+	OpenWorld bool `json:"openWorldHint"`
 }
 
 // ConfigAware is an interface that tools can implement to receive server configuration
@@ -23,3 +53,25 @@ type ConfigAware interface {
 	// SetConfig sets the server configuration for the tool
 	SetConfig(cfg *config.ServerConfig)
 }
+
+// BudgetExempt is implemented by tools whose calls don't count against session
+// budgets and are allowed even when a budget is exhausted, such as the usage tool
+type BudgetExempt interface {
+	BudgetExempt() bool
+}
+
+// PathArgs is implemented by argument structs that name files or directories.
+// The server checks every path returned by Paths against the path policy before
+// the tool runs. Tools check them against their own configuration only when they
+// are run directly, without a Call in the context.
+type PathArgs interface {
+	Paths() []PathArg
+}
+
+// PathArg is a path named by a tool's arguments
+type PathArg struct {
+	Path string
+
+	// What describes the path in error messages, e.g. "file path" or "working directory"
+	What string
+}
@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/config"
+)
+
+// runTool runs a tool's Execute, or another function of the same shape, and
+// decodes its result as R
+func runTool[R, A any](t *testing.T, execute func(A) (*mcp.ToolResponse, error), args A) R {
+	t.Helper()
+
+	resp, err := execute(args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var result R
+	if err := json.Unmarshal([]byte(resp.Content[0].TextContent.Text), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return result
+}
+
+// withPolicy adapts a tool's ExecuteContext for runTool, running it as a call whose
+// session has the path policy cfg
+func withPolicy[A any](cfg *config.ServerConfig, execute func(context.Context, A) (*mcp.ToolResponse, error)) func(A) (*mcp.ToolResponse, error) {
+	return func(args A) (*mcp.ToolResponse, error) {
+		return execute(WithCall(context.Background(), &Call{Config: cfg}), args)
+	}
+}
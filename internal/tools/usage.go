@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/budget"
+	"mcp-server/internal/utils"
+)
+
+// UsageArgs defines the arguments for the usage tool
+type UsageArgs struct{}
+
+// UsageResult defines the result of the usage tool
+type UsageResult struct {
+	Success bool `json:"success"`
+	budget.Report
+	// Panics counts the panics each tool has had since the server started, in any session
+	Panics map[string]uint64 `json:"panics,omitempty"`
+}
+
+// UsageTool implements the usage tool
+type UsageTool struct {
+	tracker *budget.Tracker
+	panics  func() map[string]uint64
+}
+
+// NewUsageTool creates a new UsageTool reporting from tracker
+func NewUsageTool(tracker *budget.Tracker) *UsageTool {
+	return &UsageTool{tracker: tracker}
+}
+
+// SetPanicCounts sets where the tool reads the server's panic counts from
+func (t *UsageTool) SetPanicCounts(panics func() map[string]uint64) {
+	t.panics = panics
+}
+
+// Name returns the tool name
+func (t *UsageTool) Name() string {
+	return "usage"
+}
+
+// Description returns the tool description
+func (t *UsageTool) Description() string {
+	return "Report what this session has used and what remains of its budgets, in total and per tool, and which tools have panicked"
+}
+
+// Title returns the tool's display name
+func (t *UsageTool) Title() string {
+	return "Usage"
+}
+
+// Annotations describes the tool's behaviour
+func (t *UsageTool) Annotations() Annotations {
+	return Annotations{
+		ReadOnly:   true,
+		Idempotent: true,
+	}
+}
+
+// BudgetExempt lets the usage tool run even when a budget is exhausted
+func (t *UsageTool) BudgetExempt() bool {
+	return true
+}
+
+// Execute fails, since usage is kept per session and a direct call has none
+func (t *UsageTool) Execute(args UsageArgs) (*mcp.ToolResponse, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reports the usage of the session making the call
+func (t *UsageTool) ExecuteContext(ctx context.Context, args UsageArgs) (*mcp.ToolResponse, error) {
+	if t.tracker == nil {
+		return nil, utils.NewToolError(utils.ErrInternal, "Usage tracking is not enabled")
+	}
+
+	call := CallFromContext(ctx)
+	if call == nil {
+		return nil, utils.NewToolError(utils.ErrUnavailable, "Usage is only reported to calls made in a session")
+	}
+
+	result := UsageResult{
+		Success: true,
+		Report:  t.tracker.Report(call.Session),
+	}
+	if t.panics != nil {
+		result.Panics = t.panics()
+	}
+
+	return utils.CreateSuccessResponse(result), nil
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mcp-server/internal/budget"
+	"mcp-server/internal/utils"
+)
+
+func TestUsageTool_OutsideSession(t *testing.T) {
+	_, err := NewUsageTool(budget.NewTracker(nil)).ExecuteContext(context.Background(), UsageArgs{})
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrUnavailable {
+		t.Errorf("Expected an UNAVAILABLE error, got %v", err)
+	}
+}
+
+func TestUsageTool_ReportsPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		panics   func() map[string]uint64
+		expected map[string]uint64
+	}{
+		{"no source", nil, nil},
+		{"no panics", func() map[string]uint64 { return map[string]uint64{} }, nil},
+		{"panics", func() map[string]uint64 { return map[string]uint64{"show_file": 2} }, map[string]uint64{"show_file": 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := NewUsageTool(budget.NewTracker(nil))
+			tool.SetPanicCounts(tt.panics)
+
+			result := runTool[UsageResult](t, withPolicy(nil, tool.ExecuteContext), UsageArgs{})
+			if len(result.Panics) != len(tt.expected) || result.Panics["show_file"] != tt.expected["show_file"] {
+				t.Errorf("Expected panics %v, got %v", tt.expected, result.Panics)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileVersion identifies the version of a file a tool read or wrote. Passing its
+// SHA256 to write_file as expected_hash makes the write fail with CONFLICT if the
+// file has changed since.
+type FileVersion struct {
+	// SHA256 is the hex SHA-256 of the file's bytes as stored, before any
+	// conversion to UTF-8
+	SHA256 string `json:"sha256,omitempty"`
+
+	// HashSkipped is set when SHA256 is left out because the call read only part of
+	// a file larger than maxRangedHashSize; stat_file hashes a file of any size
+	HashSkipped bool `json:"hash_skipped,omitempty"`
+
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+var (
+	// maxRangedHashSize is the largest file a tool that reads only part of it hashes
+	// in full as well; larger files are hashed only when read whole
+	maxRangedHashSize int64 = 1 << 20
+
+	// maxSnapshotSize is the largest file whose content is kept when it is hashed,
+	// so a write that conflicts with it can show what changed since it was read
+	maxSnapshotSize int64 = 256 << 10
+)
+
+const (
+	// maxFileVersions is how many files' hashes are kept
+	maxFileVersions = 256
+
+	// maxSnapshots is how many versions' contents are kept
+	maxSnapshots = 32
+)
+
+// versions caches the hashes of recently read files, and the contents of the
+// smaller ones
+var versions = &versionCache{
+	hashes:    make(map[string]cachedVersion),
+	snapshots: make(map[string][]byte),
+}
+
+// fileVersion returns the version of file, open at path and described by info. The
+// file is hashed unless it was hashed before and has not changed since.
+func fileVersion(path string, file io.ReaderAt, info os.FileInfo) (FileVersion, error) {
+	if cached, ok := versions.get(path, info); ok {
+		return cached, nil
+	}
+	return hashVersion(path, file, info)
+}
+
+// rangedVersion returns the version of file, open at path and described by info,
+// for a call that reads only part of it. Files larger than maxRangedHashSize are
+// not read in full just to hash them, so their hash is skipped unless it is cached.
+func rangedVersion(path string, file io.ReaderAt, info os.FileInfo) (FileVersion, error) {
+	if cached, ok := versions.get(path, info); ok {
+		return cached, nil
+	}
+	if info.Size() > maxRangedHashSize {
+		return FileVersion{HashSkipped: true, Size: info.Size(), ModTime: info.ModTime()}, nil
+	}
+	return hashVersion(path, file, info)
+}
+
+// hashVersion hashes file, open at path and described by info, and caches its version
+func hashVersion(path string, file io.ReaderAt, info os.FileInfo) (FileVersion, error) {
+	hash, data, err := hashContent(file, info.Size())
+	if err != nil {
+		return FileVersion{}, err
+	}
+	return recordVersion(path, info, hash, data), nil
+}
+
+// ContentVersion returns the version of the file at path, described by info, whose
+// whole content is data, and caches it like the versions the read tools return
+func ContentVersion(path string, info os.FileInfo, data []byte) FileVersion {
+	sum := sha256.Sum256(data)
+	if int64(len(data)) > maxSnapshotSize {
+		data = nil
+	}
+	return recordVersion(path, info, hex.EncodeToString(sum[:]), data)
+}
+
+// recordVersion caches hash as the version of the file at path, described by info,
+// and data as its content if it is not nil
+func recordVersion(path string, info os.FileInfo, hash string, data []byte) FileVersion {
+	version := FileVersion{SHA256: hash, Size: info.Size(), ModTime: info.ModTime()}
+	versions.put(path, info, version, data)
+	return version
+}
+
+// hashContent returns the hex SHA-256 of the first size bytes of r. data is those
+// bytes if there are no more than maxSnapshotSize of them, and nil otherwise. The
+// bytes are not counted as read by the call, since the caller never sees them.
+func hashContent(r io.ReaderAt, size int64) (hash string, data []byte, err error) {
+	h := sha256.New()
+	section := io.NewSectionReader(r, 0, size)
+
+	if size <= maxSnapshotSize {
+		data, err = io.ReadAll(section)
+		h.Write(data)
+	} else {
+		_, err = io.Copy(h, section)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), data, nil
+}
+
+// hashingReader hashes what is read through it, so a tool that reads a whole file
+// anyway gets its hash without reading it twice
+type hashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, h: sha256.New()}
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	return n, err
+}
+
+// version returns the version of file, open at path and described by info. If
+// everything in it was read through r, that is hashed; otherwise it is as
+// rangedVersion returns.
+func (r *hashingReader) version(path string, file io.ReaderAt, info os.FileInfo) (FileVersion, error) {
+	if r.n != info.Size() {
+		return rangedVersion(path, file, info)
+	}
+	return recordVersion(path, info, hex.EncodeToString(r.h.Sum(nil)), nil), nil
+}
+
+// versionCache holds file versions by path and snapshots of file contents by hash,
+// discarding the oldest of each beyond maxFileVersions and maxSnapshots
+type versionCache struct {
+	mu            sync.Mutex
+	hashes        map[string]cachedVersion
+	hashOrder     []string
+	snapshots     map[string][]byte
+	snapshotOrder []string
+}
+
+// cachedVersion is a cached file version with what else identifies the file it was
+// taken from. A file rewritten at the same size within the granularity of its
+// modification time keeps both, but gets a new status change time, and a file
+// replaced by a rename gets a new inode.
+type cachedVersion struct {
+	FileVersion
+	inode uint64
+	ctime time.Time
+}
+
+// get returns the cached version of the file at path, if it was hashed when the file
+// was as info describes it
+func (c *versionCache) get(path string, info os.FileInfo) (FileVersion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.hashes[path]
+	inode, ctime := fileChange(info)
+	if !ok || cached.Size != info.Size() || !cached.ModTime.Equal(info.ModTime()) || cached.inode != inode || !cached.ctime.Equal(ctime) {
+		return FileVersion{}, false
+	}
+	return cached.FileVersion, true
+}
+
+// put caches the version of the file at path, described by info, and data as its
+// content if it is not nil
+func (c *versionCache) put(path string, info os.FileInfo, version FileVersion, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.hashes[path]; !ok {
+		c.hashOrder = append(c.hashOrder, path)
+		if len(c.hashOrder) > maxFileVersions {
+			delete(c.hashes, c.hashOrder[0])
+			c.hashOrder = c.hashOrder[1:]
+		}
+	}
+	inode, ctime := fileChange(info)
+	c.hashes[path] = cachedVersion{FileVersion: version, inode: inode, ctime: ctime}
+
+	if _, ok := c.snapshots[version.SHA256]; data == nil || ok {
+		return
+	}
+	c.snapshotOrder = append(c.snapshotOrder, version.SHA256)
+	if len(c.snapshotOrder) > maxSnapshots {
+		delete(c.snapshots, c.snapshotOrder[0])
+		c.snapshotOrder = c.snapshotOrder[1:]
+	}
+	c.snapshots[version.SHA256] = data
+}
+
+// snapshot returns the content of the file version with the given hash, if it is kept
+func (c *versionCache) snapshot(hash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, ok := c.snapshots[hash]
+	return data, ok
+}
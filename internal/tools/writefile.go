@@ -1,28 +1,57 @@
 package tools
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	mcp "github.com/metoro-io/mcp-golang"
+	"mcp-server/internal/charset"
 	"mcp-server/internal/config"
+	"mcp-server/internal/diff"
 	"mcp-server/internal/utils"
 )
 
 // WriteFileArgs defines the arguments for the write_file tool
 type WriteFileArgs struct {
-	FilePath string `json:"file_path" jsonschema:"required,description=Path to the file to write"`
+	FilePath string `json:"file_path" jsonschema:"required,minLength=1,description=Path to the file to write"`
 	Content  string `json:"content" jsonschema:"required,description=Text content to write to the file"`
-	Mode     string `json:"mode" jsonschema:"description=Write mode to use: 'w' (overwrite) or 'a' (append)"`
+	Mode     string `json:"mode" jsonschema:"enum=w,enum=a,description=Write mode to use: 'w' (overwrite) or 'a' (append); defaults to 'w'"`
+
+	ExpectedHash string `json:"expected_hash" jsonschema:"pattern=^[0-9a-fA-F]{64}$,description=The sha256 a read of the file returned; the write fails with CONFLICT if the file has changed since"`
+}
+
+// Paths returns the file to be written and the directory it will be created in,
+// for the server's path policy check
+func (a WriteFileArgs) Paths() []PathArg {
+	return []PathArg{
+		{Path: a.FilePath, What: "file path"},
+		{Path: filepath.Dir(a.FilePath), What: "parent directory"},
+	}
 }
 
 // WriteFileResult defines the result of the write_file tool
 type WriteFileResult struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"`
+	Success bool `json:"success"`
+
+	// Encoding and LineEnding are how the content was written: those of the file
+	// being replaced or appended to, or UTF-8 and the content's own for a new file
+	charset.Encoding
+	LineEnding string `json:"line_ending"`
+
+	// FileVersion is the version of the file as written, to pass as expected_hash
+	// to the next write
+	FileVersion
 }
 
+// maxConflictDiff is the most of a diff a CONFLICT error includes
+const maxConflictDiff = 16 << 10
+
 // WriteFileTool implements the write_file tool
 type WriteFileTool struct {
 	config *config.ServerConfig
@@ -48,85 +77,292 @@ func (t *WriteFileTool) Description() string {
 	return "Write content to a file with options to append or overwrite existing content"
 }
 
+// Title returns the tool's display name
+func (t *WriteFileTool) Title() string {
+	return "Write File"
+}
+
+// Annotations describes the tool's behaviour
+func (t *WriteFileTool) Annotations() Annotations {
+	return Annotations{
+		Destructive: true,
+	}
+}
+
 // Execute writes to a file with the provided arguments
 func (t *WriteFileTool) Execute(args WriteFileArgs) (*mcp.ToolResponse, error) {
-	// Check if path is allowed by configuration
-	if t.config != nil {
-		allowed, err := t.config.IsPathAllowed(args.FilePath)
-		if err != nil || !allowed {
-			errorMsg := "Access to this file path is not allowed by server configuration"
-			if err != nil {
-				errorMsg = fmt.Sprintf("%s: %v", errorMsg, err)
-			}
-			result := WriteFileResult{
-				Success: false,
-				Error:   errorMsg,
-			}
-			return utils.CreateSuccessResponse(result), nil
-		}
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext writes to a file, leaving the original untouched if ctx is cancelled before the write completes
+func (t *WriteFileTool) ExecuteContext(ctx context.Context, args WriteFileArgs) (*mcp.ToolResponse, error) {
+	if err := requireArgPaths(ctx, t.config, args); err != nil {
+		return nil, err
 	}
 
-	// Determine file mode
-	fileMode := os.O_WRONLY | os.O_CREATE
-	if args.Mode == "a" {
-		fileMode |= os.O_APPEND
-	} else {
-		fileMode |= os.O_TRUNC
+	// Anything else used to silently truncate the file
+	if args.Mode != "" && args.Mode != "w" && args.Mode != "a" {
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Invalid value for mode: must be \"w\" or \"a\", got %q", args.Mode)
+	}
+
+	// Refuse to overwrite changes made since the caller read the file
+	if args.ExpectedHash != "" {
+		if err := checkExpectedHash(args.FilePath, strings.ToLower(args.ExpectedHash), args.Content); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create parent directories if they don't exist
 	dir := filepath.Dir(args.FilePath)
 	if dir != "" {
-		// Check if the parent directory's path is allowed too
-		if t.config != nil {
-			allowed, err := t.config.IsPathAllowed(dir)
-			if err != nil || !allowed {
-				errorMsg := "Access to the parent directory is not allowed by server configuration"
-				if err != nil {
-					errorMsg = fmt.Sprintf("%s: %v", errorMsg, err)
-				}
-				result := WriteFileResult{
-					Success: false,
-					Error:   errorMsg,
-				}
-				return utils.CreateSuccessResponse(result), nil
-			}
-		}
-
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			result := WriteFileResult{
-				Success: false,
-				Error:   fmt.Sprintf("Error creating directories: %v", err),
-			}
-			return utils.CreateSuccessResponse(result), nil
+			return nil, fileError(err, dir, "creating directories")
 		}
 	}
 
-	// Open file
-	file, err := os.OpenFile(args.FilePath, fileMode, 0644)
+	// Keep the encoding and line endings of an existing text file
+	content := args.Content
+	encoding, lineEnding, ok := textFormat(args.FilePath)
+	if !ok {
+		encoding = charset.Default
+	}
+	if lineEnding != "" {
+		content = charset.ConvertLineEndings(content, lineEnding)
+	} else {
+		lineEnding = charset.DetectLineEnding([]byte(content))
+	}
+
+	// Appended content goes after the byte order mark, not before it
+	writeEncoding := encoding
+	if args.Mode == "a" && ok {
+		writeEncoding.BOM = false
+	}
+	data, err := charset.Encode(content, writeEncoding)
 	if err != nil {
-		result := WriteFileResult{
-			Success: false,
-			Error:   fmt.Sprintf("Error opening file: %v", err),
-		}
-		return utils.CreateSuccessResponse(result), nil
+		return nil, utils.NewToolError(utils.ErrInvalidArgs, "Content cannot be written to %s: %v", args.FilePath, err).
+			WithDetails(map[string]interface{}{"encoding": encoding.Name})
 	}
-	defer file.Close()
 
 	// Write content
-	_, err = file.WriteString(args.Content)
+	if args.Mode == "a" {
+		err = appendToFile(args.FilePath, string(data))
+	} else {
+		err = replaceFile(ctx, args.FilePath, string(data))
+	}
 	if err != nil {
-		result := WriteFileResult{
-			Success: false,
-			Error:   fmt.Sprintf("Error writing to file: %v", err),
-		}
-		return utils.CreateSuccessResponse(result), nil
+		return nil, err
+	}
+	CallFromContext(ctx).AddBytesWritten(int64(len(data)))
+
+	version, err := writtenVersion(args.FilePath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create result
 	result := WriteFileResult{
-		Success: true,
+		Success:     true,
+		Encoding:    encoding,
+		LineEnding:  lineEnding,
+		FileVersion: version,
 	}
 
 	return utils.CreateSuccessResponse(result), nil
 }
+
+// checkExpectedHash returns a CONFLICT error if the file at path no longer has the
+// SHA-256 expected, with a diff of what changed if it can show one. content is what
+// is about to be written.
+func checkExpectedHash(path, expected, content string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return utils.NewToolError(utils.ErrConflict, "%s no longer exists; read it again before writing", path).
+			WithDetails(map[string]interface{}{"path": path, "expected_hash": expected})
+	}
+	if err != nil {
+		return fileError(err, path, "reading file")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fileError(err, path, "checking file")
+	}
+	if info.IsDir() {
+		return utils.NewToolError(utils.ErrInvalidArgs, "%s is a directory, not a file", path)
+	}
+
+	// Hash what is there now rather than trusting the cache, which can miss changes
+	// that leave the size and modification time as they were
+	actual, current, err := hashContent(file, info.Size())
+	if err != nil {
+		return fileError(err, path, "reading file")
+	}
+	if actual == expected {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"path":          path,
+		"expected_hash": expected,
+		"actual_hash":   actual,
+		"size":          info.Size(),
+		"mod_time":      info.ModTime(),
+	}
+	if d := conflictDiff(path, expected, current, content); d != "" {
+		details["diff"] = d
+	}
+	return utils.NewToolError(utils.ErrConflict, "%s has changed since it was read; read it again before writing", path).
+		WithDetails(details)
+}
+
+// conflictDiff shows how current, the content of the file at path, differs from
+// the version with the expected hash, if that is still kept, or else from content.
+// It is empty if either side is binary or was too large to keep.
+func conflictDiff(path, expected string, current []byte, content string) string {
+	currentText, ok := diffableText(current)
+	if !ok {
+		return ""
+	}
+
+	var d string
+	if read, found := versions.snapshot(expected); found {
+		readText, ok := diffableText(read)
+		if !ok {
+			return ""
+		}
+		d = diff.Unified(path+" (as read)", path+" (now)", readText, currentText, 3)
+	} else {
+		d = diff.Unified(path+" (now)", path+" (to be written)", currentText, charset.ConvertLineEndings(content, charset.LF), 3)
+	}
+
+	if len(d) > maxConflictDiff {
+		cut := strings.LastIndexByte(d[:maxConflictDiff], '\n') + 1
+		d = d[:cut] + "... (diff truncated)\n"
+	}
+	return d
+}
+
+// diffableText returns data as UTF-8 text with LF line endings, unless it is nil or
+// binary
+func diffableText(data []byte) (string, bool) {
+	if data == nil {
+		return "", false
+	}
+	sniffed := detectFileType(data, false)
+	if sniffed.Binary {
+		return "", false
+	}
+	return charset.ConvertLineEndings(string(charset.Decode(data, sniffed.Encoding)), charset.LF), true
+}
+
+// writtenVersion hashes the file just written at path
+func writtenVersion(path string) (FileVersion, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileVersion{}, fileError(err, path, "reading file")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return FileVersion{}, fileError(err, path, "checking file")
+	}
+	version, err := hashVersion(path, file, info)
+	if err != nil {
+		return FileVersion{}, fileError(err, path, "reading file")
+	}
+	return version, nil
+}
+
+// textFormat returns the encoding and line endings of the text file at path. ok is
+// false if there is no such file, or it is empty or binary; lineEnding is empty if
+// the file has only one line.
+func textFormat(path string) (encoding charset.Encoding, lineEnding string, ok bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return charset.Encoding{}, "", false
+	}
+	defer file.Close()
+
+	sample := make([]byte, binarySniffSize)
+	n, err := file.ReadAt(sample, 0)
+	if n == 0 || (err != nil && !errors.Is(err, io.EOF)) {
+		return charset.Encoding{}, "", false
+	}
+
+	sniffed := detectFileType(sample[:n], n == binarySniffSize)
+	if sniffed.Binary {
+		return charset.Encoding{}, "", false
+	}
+
+	text := charset.Decode(sample[:n], sniffed.Encoding)
+	if bytes.IndexByte(text, '\n') >= 0 {
+		lineEnding = charset.DetectLineEnding(text)
+	}
+	return sniffed.Encoding, lineEnding, true
+}
+
+// appendToFile appends content to a file, creating it if needed
+func appendToFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fileError(err, path, "opening file")
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		return fileError(err, path, "writing to file")
+	}
+	return nil
+}
+
+// replaceFile writes content to a temporary file next to path and renames it into place,
+// so readers never observe a half-written file and an aborted write leaves nothing behind
+func replaceFile(ctx context.Context, path, content string) error {
+	// Write through symlinks like a plain open would, instead of replacing the link
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fileError(err, path, "opening file")
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file unless it was successfully renamed
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.WriteString(content); err != nil {
+		return fileError(err, path, "writing to file")
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fileError(err, path, "writing to file")
+	}
+	if err := tmp.Close(); err != nil {
+		return fileError(err, path, "writing to file")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return utils.NewToolError(utils.ErrAborted, "Write aborted: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fileError(err, path, "writing to file")
+	}
+	committed = true
+
+	return nil
+}
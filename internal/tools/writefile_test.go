@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"mcp-server/internal/charset"
+	"mcp-server/internal/utils"
+)
+
+func TestWriteFileTool_KeepsEncodingAndLineEndings(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		args     WriteFileArgs
+		expected string
+		encoding charset.Encoding
+		ending   string
+	}{
+		{
+			name:     "latin-1 with crlf",
+			original: "caf\xe9\r\nna\xefve\r\n",
+			args:     WriteFileArgs{Content: "crème\nbrûlée\n"},
+			expected: "cr\xe8me\r\nbr\xfbl\xe9e\r\n",
+			encoding: charset.Encoding{Name: charset.Latin1},
+			ending:   charset.CRLF,
+		},
+		{
+			name:     "utf-16 with a bom, appended to",
+			original: "\xff\xfea\x00\n\x00",
+			args:     WriteFileArgs{Content: "b\n", Mode: "a"},
+			expected: "\xff\xfea\x00\n\x00b\x00\n\x00",
+			encoding: charset.Encoding{Name: charset.UTF16LE, BOM: true},
+			ending:   charset.LF,
+		},
+		{
+			name:     "utf-8 with lf",
+			original: "a\nb\n",
+			args:     WriteFileArgs{Content: "c\r\nd\r\n"},
+			expected: "c\nd\n",
+			encoding: charset.Default,
+			ending:   charset.LF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.original)
+			tt.args.FilePath = path
+
+			result := runTool[WriteFileResult](t, NewWriteFileTool().Execute, tt.args)
+			if result.Encoding != tt.encoding || result.LineEnding != tt.ending {
+				t.Errorf("Expected %v with %s line endings, got %+v", tt.encoding, tt.ending, result)
+			}
+
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(written) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, written)
+			}
+		})
+	}
+}
+
+func TestWriteFileTool_Unrepresentable(t *testing.T) {
+	path := writeTestFile(t, "caf\xe9\n")
+
+	_, err := NewWriteFileTool().Execute(WriteFileArgs{FilePath: path, Content: "5 €\n"})
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrInvalidArgs {
+		t.Fatalf("Expected an INVALID_ARGS error, got %v", err)
+	}
+
+	if written, _ := os.ReadFile(path); string(written) != "caf\xe9\n" {
+		t.Errorf("Expected the file to be left alone, got %q", written)
+	}
+}
+
+func TestWriteFileTool_ExpectedHash(t *testing.T) {
+	path := writeTestFile(t, "one\ntwo\nthree\n")
+	read := runTool[ShowFileResult](t, NewShowFileTool().Execute, ShowFileArgs{FilePath: path})
+
+	// Someone else edits the file after it was read
+	if err := os.WriteFile(path, []byte("one\n2\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// conflict runs write_file expecting hash and returns the CONFLICT error's details
+	conflict := func(hash string) map[string]interface{} {
+		t.Helper()
+		_, err := NewWriteFileTool().Execute(WriteFileArgs{FilePath: path, Content: "mine\n", ExpectedHash: hash})
+		var toolErr *utils.ToolError
+		if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrConflict {
+			t.Fatalf("Expected a CONFLICT error, got %v", err)
+		}
+		return toolErr.Details.(map[string]interface{})
+	}
+
+	details := conflict(read.SHA256)
+	if details["actual_hash"] != sha256Hex("one\n2\nthree\n") {
+		t.Errorf("Expected the current hash, got %v", details["actual_hash"])
+	}
+	if diff, _ := details["diff"].(string); !strings.Contains(diff, "-two\n+2\n") {
+		t.Errorf("Expected a diff from the version read, got %q", diff)
+	}
+
+	// A version that was never read can only be compared with the new content
+	details = conflict(sha256Hex("never read\n"))
+	if diff, _ := details["diff"].(string); !strings.Contains(diff, "+mine\n") {
+		t.Errorf("Expected a diff to the content being written, got %q", diff)
+	}
+
+	if written, _ := os.ReadFile(path); string(written) != "one\n2\nthree\n" {
+		t.Errorf("Expected the file to be left alone, got %q", written)
+	}
+
+	// Writing over the current version succeeds, and returns the next one to expect
+	result := runTool[WriteFileResult](t, NewWriteFileTool().Execute, WriteFileArgs{FilePath: path, Content: "mine\n", ExpectedHash: strings.ToUpper(sha256Hex("one\n2\nthree\n"))})
+	if result.SHA256 != sha256Hex("mine\n") || result.Size != 5 {
+		t.Errorf("Expected the version written, got %+v", result.FileVersion)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if details := conflict(result.SHA256); details["path"] != path {
+		t.Errorf("Expected a CONFLICT for the deleted file, got %v", details)
+	}
+}
+
+func TestWriteFileTool_ExpectedHashSeesSameSizeRewrite(t *testing.T) {
+	path := writeTestFile(t, "version one\n")
+	read := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: path})
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the file at the same size and put its modification time back, as a
+	// second write within the clock's granularity would leave it
+	if err := os.WriteFile(path, []byte("version two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cached hash goes by the status change time as well, where there is one
+	if inode, _ := fileChange(info); inode != 0 {
+		again := runTool[StatFileResult](t, NewStatFileTool().Execute, StatFileArgs{Path: path})
+		if again.SHA256 != sha256Hex("version two\n") {
+			t.Errorf("Expected the rewritten file to be hashed again, got %s", again.SHA256)
+		}
+	}
+
+	_, err = NewWriteFileTool().Execute(WriteFileArgs{FilePath: path, Content: "mine\n", ExpectedHash: read.SHA256})
+	var toolErr *utils.ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrConflict {
+		t.Fatalf("Expected a CONFLICT error, got %v", err)
+	}
+	if written, _ := os.ReadFile(path); string(written) != "version two\n" {
+		t.Errorf("Expected the file to be left alone, got %q", written)
+	}
+}
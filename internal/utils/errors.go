@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is a stable, machine-readable identifier for a class of tool failure
+type ErrorCode string
+
+// Error codes returned by tools
+const (
+	// ErrPathDenied means the path policy refused access to a file or directory
+	ErrPathDenied ErrorCode = "PATH_DENIED"
+
+	// ErrUnauthorized means the caller is not allowed to use the tool
+	ErrUnauthorized ErrorCode = "UNAUTHORIZED"
+
+	// ErrRateLimited means the caller has made too many calls recently
+	ErrRateLimited ErrorCode = "RATE_LIMITED"
+
+	// ErrBudgetExceeded means the session has used up one of its budgets
+	ErrBudgetExceeded ErrorCode = "BUDGET_EXCEEDED"
+
+	// ErrCommandDenied means the command is not on the allowlist
+	ErrCommandDenied ErrorCode = "COMMAND_DENIED"
+
+	// ErrNotFound means a file, directory or executable does not exist
+	ErrNotFound ErrorCode = "NOT_FOUND"
+
+	// ErrInvalidArgs means the arguments are malformed or out of range
+	ErrInvalidArgs ErrorCode = "INVALID_ARGS"
+
+	// ErrTooLarge means the input or output exceeds a size limit
+	ErrTooLarge ErrorCode = "TOO_LARGE"
+
+	// ErrConflict means a file changed since the caller read it
+	ErrConflict ErrorCode = "CONFLICT"
+
+	// ErrTimeout means the operation did not finish in the allotted time
+	ErrTimeout ErrorCode = "TIMEOUT"
+
+	// ErrAborted means the operation was cancelled, usually because the server is shutting down
+	ErrAborted ErrorCode = "ABORTED"
+
+	// ErrUnavailable means the server is not accepting new calls
+	ErrUnavailable ErrorCode = "UNAVAILABLE"
+
+	// ErrIO means reading or writing failed for a reason other than the above
+	ErrIO ErrorCode = "IO_ERROR"
+
+	// ErrInternal means the server itself failed
+	ErrInternal ErrorCode = "INTERNAL"
+)
+
+// ToolError is the error every tool returns when a call fails. The server sends it to
+// the client as a tool result with isError set, encoded as
+//
+//	{"success": false, "error": {"code": "NOT_FOUND", "message": "...", "details": {...}}}
+type ToolError struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// NewToolError creates a ToolError with a formatted message
+func NewToolError(code ErrorCode, format string, args ...interface{}) *ToolError {
+	return &ToolError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// WithDetails attaches structured data, such as partial command output, to the error
+func (e *ToolError) WithDetails(details interface{}) *ToolError {
+	e.Details = details
+	return e
+}
+
+// Error implements the error interface
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// JSON returns the wire encoding of the error
+func (e *ToolError) JSON() string {
+	payload := struct {
+		Success bool       `json:"success"`
+		Error   *ToolError `json:"error"`
+	}{
+		Success: false,
+		Error:   e,
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		// Details could not be encoded; fall back to the code and message alone
+		data, _ = json.Marshal(struct {
+			Success bool       `json:"success"`
+			Error   *ToolError `json:"error"`
+		}{Error: &ToolError{Code: e.Code, Message: e.Message}})
+	}
+	return string(data)
+}
+
+// AsToolError returns err as a *ToolError, classifying any other error as INTERNAL
+func AsToolError(err error) *ToolError {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr
+	}
+	return &ToolError{Code: ErrInternal, Message: err.Error()}
+}
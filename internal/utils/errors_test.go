@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestToolError_JSON_EscapesMessage(t *testing.T) {
+	toolErr := NewToolError(ErrNotFound, "File \"%s\" does not exist\n", `C:\tmp\a.txt`).
+		WithDetails(map[string]interface{}{"path": `C:\tmp\a.txt`})
+
+	var payload struct {
+		Success bool      `json:"success"`
+		Error   ToolError `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(toolErr.JSON()), &payload); err != nil {
+		t.Fatalf("Expected valid JSON, got %q: %v", toolErr.JSON(), err)
+	}
+
+	if payload.Success {
+		t.Error("Expected success to be false")
+	}
+	if payload.Error.Code != ErrNotFound || payload.Error.Message != toolErr.Message {
+		t.Errorf("Expected the error to round-trip, got: %+v", payload.Error)
+	}
+}
+
+func TestCreateErrorResponse_EscapesMessage(t *testing.T) {
+	resp := CreateErrorResponse("bad \"quote\"\nand newline")
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content[0].TextContent.Text), &payload); err != nil {
+		t.Fatalf("Expected valid JSON, got %q: %v", resp.Content[0].TextContent.Text, err)
+	}
+	if payload["error"] != "bad \"quote\"\nand newline" {
+		t.Errorf("Expected the message to round-trip, got: %v", payload["error"])
+	}
+}
+
+func TestAsToolError(t *testing.T) {
+	wrapped := fmt.Errorf("while reading: %w", NewToolError(ErrTooLarge, "too big"))
+	if got := AsToolError(wrapped); got.Code != ErrTooLarge {
+		t.Errorf("Expected the wrapped code to be kept, got %s", got.Code)
+	}
+
+	if got := AsToolError(errors.New("boom")); got.Code != ErrInternal || got.Message != "boom" {
+		t.Errorf("Expected a plain error to become INTERNAL, got %+v", got)
+	}
+}
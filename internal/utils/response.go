@@ -2,7 +2,6 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
 
 	mcp "github.com/metoro-io/mcp-golang"
 )
@@ -13,10 +12,14 @@ func CreateSuccessResponse(result interface{}) *mcp.ToolResponse {
 	return mcp.NewToolResponse(mcp.NewTextContent(string(resultJSON)))
 }
 
-// CreateErrorResponse creates an error response with the given message
+// CreateErrorResponse creates an error response with the given message.
+// Tools should return a *ToolError instead so the client sees isError and a code.
 func CreateErrorResponse(message string) *mcp.ToolResponse {
-	errorJSON := fmt.Sprintf(`{"success": false, "error": "%s"}`, message)
-	return mcp.NewToolResponse(mcp.NewTextContent(errorJSON))
+	errorJSON, _ := json.Marshal(map[string]interface{}{
+		"success": false,
+		"error":   message,
+	})
+	return mcp.NewToolResponse(mcp.NewTextContent(string(errorJSON)))
 }
 
 // CreateErrorResponseWithData creates an error response including the provided data
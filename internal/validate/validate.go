@@ -0,0 +1,328 @@
+// Package validate enforces the constraints declared in a tool's argument struct.
+//
+// Constraints are written as jsonschema struct tags, the same tags the MCP library
+// reads to build the input schema it advertises for the tool:
+//
+//	Mode     string `json:"mode" jsonschema:"enum=w,enum=a"`
+//	NumLines *int   `json:"num_lines" jsonschema:"minimum=0"`
+//
+// The schema is reflected here with the library's settings, so what clients are told
+// and what the server enforces cannot drift apart.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/invopop/jsonschema"
+	"mcp-server/internal/utils"
+)
+
+// reflector matches the one the MCP library uses to build tool input schemas
+var reflector = jsonschema.Reflector{
+	Anonymous:                  true,
+	AllowAdditionalProperties:  true,
+	RequiredFromJSONSchemaTags: true,
+	DoNotReference:             true,
+	ExpandedStruct:             true,
+}
+
+var (
+	schemas  sync.Map // reflect.Type -> *jsonschema.Schema
+	patterns sync.Map // string -> *regexp.Regexp
+)
+
+// Struct checks args, a tool argument struct, against the constraints in its tags.
+// Optional fields left at their zero value are not checked, since tools treat them
+// as "use the default". It returns an INVALID_ARGS *utils.ToolError naming the first
+// field that fails.
+func Struct(args interface{}) error {
+	value := reflect.ValueOf(args)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if name, reason := checkFields(schemaFor(value.Type()), value); reason != "" {
+		return utils.NewToolError(utils.ErrInvalidArgs, "Invalid value for %s: %s", name, reason).
+			WithDetails(map[string]interface{}{"field": name})
+	}
+
+	return nil
+}
+
+// Present checks that raw, the JSON object args was decoded from, has every argument
+// args' tags mark as required. Decoding leaves a missing argument at its zero value,
+// which Struct cannot tell from one the client sent. A nil raw is not checked. It
+// returns an INVALID_ARGS *utils.ToolError naming the first missing argument.
+func Present(args interface{}, raw json.RawMessage) error {
+	if raw == nil {
+		return nil
+	}
+
+	argsType := reflect.TypeOf(args)
+	for argsType != nil && argsType.Kind() == reflect.Ptr {
+		argsType = argsType.Elem()
+	}
+	if argsType == nil || argsType.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var sent map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &sent); err != nil {
+		return utils.NewToolError(utils.ErrInvalidArgs, "Arguments must be a JSON object: %v", err)
+	}
+
+	for _, name := range schemaFor(argsType).Required {
+		if _, ok := sent[name]; !ok {
+			return utils.NewToolError(utils.ErrInvalidArgs, "Missing required argument %s", name).
+				WithDetails(map[string]interface{}{"field": name})
+		}
+	}
+
+	return nil
+}
+
+// EnumValues returns the values the enum tag of an argument struct's field allows,
+// given the field's JSON name, or nil if it has none
+func EnumValues(argsType reflect.Type, name string) []string {
+	for argsType.Kind() == reflect.Ptr {
+		argsType = argsType.Elem()
+	}
+	if argsType.Kind() != reflect.Struct {
+		return nil
+	}
+
+	property, ok := schemaFor(argsType).Properties.Get(name)
+	if !ok || len(property.Enum) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(property.Enum))
+	for _, value := range property.Enum {
+		values = append(values, fmt.Sprint(value))
+	}
+	return values
+}
+
+// schemaFor reflects and caches the schema for t
+func schemaFor(t reflect.Type) *jsonschema.Schema {
+	if cached, ok := schemas.Load(t); ok {
+		return cached.(*jsonschema.Schema)
+	}
+	schema := reflector.ReflectFromType(t)
+	schemas.Store(t, schema)
+	return schema
+}
+
+// checkFields checks each field of the struct value against its property in schema,
+// returning the JSON name of the first that fails and why
+func checkFields(schema *jsonschema.Schema, value reflect.Value) (string, string) {
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+
+		property, ok := schema.Properties.Get(name)
+		if !ok {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.IsZero() && !required[name] {
+			continue
+		}
+		for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+
+		if reason := check(property, fieldValue); reason != "" {
+			return name, reason
+		}
+	}
+
+	return "", ""
+}
+
+// check returns why value violates schema, or "" if it doesn't
+func check(schema *jsonschema.Schema, value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return checkString(schema, value.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return checkNumber(schema, float64(value.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return checkNumber(schema, float64(value.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return checkNumber(schema, value.Float())
+	case reflect.Slice, reflect.Array:
+		return checkArray(schema, value)
+	case reflect.Struct:
+		// Objects nested in arrays, such as read_files' list of files
+		if schema.Properties == nil {
+			return ""
+		}
+		if name, reason := checkFields(schema, value); reason != "" {
+			return name + " " + reason
+		}
+	}
+	return ""
+}
+
+func checkString(schema *jsonschema.Schema, s string) string {
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, s) {
+		return fmt.Sprintf("must be one of %s, got %q", formatEnum(schema.Enum), s)
+	}
+
+	length := uint64(utf8.RuneCountInString(s))
+	if schema.MinLength != nil && length < *schema.MinLength {
+		if *schema.MinLength == 1 {
+			return "must not be empty"
+		}
+		return fmt.Sprintf("must be at least %d characters long", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		return fmt.Sprintf("must be at most %d characters long", *schema.MaxLength)
+	}
+
+	if schema.Pattern != "" {
+		pattern, err := compilePattern(schema.Pattern)
+		if err != nil {
+			return fmt.Sprintf("has an invalid pattern in its schema: %v", err)
+		}
+		if !pattern.MatchString(s) {
+			return fmt.Sprintf("must match the pattern %s", schema.Pattern)
+		}
+	}
+
+	return ""
+}
+
+func checkNumber(schema *jsonschema.Schema, n float64) string {
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, n) {
+		return fmt.Sprintf("must be one of %s, got %v", formatEnum(schema.Enum), n)
+	}
+
+	if bound, ok := number(schema.Minimum); ok && n < bound {
+		return fmt.Sprintf("must be at least %s, got %v", schema.Minimum, n)
+	}
+	if bound, ok := number(schema.Maximum); ok && n > bound {
+		return fmt.Sprintf("must be at most %s, got %v", schema.Maximum, n)
+	}
+	if bound, ok := number(schema.ExclusiveMinimum); ok && n <= bound {
+		return fmt.Sprintf("must be greater than %s, got %v", schema.ExclusiveMinimum, n)
+	}
+	if bound, ok := number(schema.ExclusiveMaximum); ok && n >= bound {
+		return fmt.Sprintf("must be less than %s, got %v", schema.ExclusiveMaximum, n)
+	}
+
+	return ""
+}
+
+func checkArray(schema *jsonschema.Schema, value reflect.Value) string {
+	length := uint64(value.Len())
+	if schema.MinItems != nil && length < *schema.MinItems {
+		if *schema.MinItems == 1 {
+			return "must not be empty"
+		}
+		return fmt.Sprintf("must have at least %d items", *schema.MinItems)
+	}
+	if schema.MaxItems != nil && length > *schema.MaxItems {
+		return fmt.Sprintf("must have at most %d items", *schema.MaxItems)
+	}
+
+	if schema.Items != nil {
+		for i := 0; i < value.Len(); i++ {
+			if reason := check(schema.Items, value.Index(i)); reason != "" {
+				return fmt.Sprintf("item %d %s", i, reason)
+			}
+		}
+	}
+
+	return ""
+}
+
+// inEnum reports whether v is one of the enum values, which the reflector stores as
+// strings or json.Numbers
+func inEnum(enum []any, v interface{}) bool {
+	for _, allowed := range enum {
+		switch allowed := allowed.(type) {
+		case json.Number:
+			if n, ok := v.(float64); ok {
+				if f, err := allowed.Float64(); err == nil && f == n {
+					return true
+				}
+			}
+		default:
+			if fmt.Sprint(allowed) == fmt.Sprint(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []any) string {
+	values := make([]string, len(enum))
+	for i, v := range enum {
+		if s, ok := v.(string); ok {
+			values[i] = fmt.Sprintf("%q", s)
+		} else {
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// number parses an optional schema bound
+func number(n json.Number) (float64, bool) {
+	if n == "" {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := patterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// jsonName returns the JSON property name of an exported field, or "" if it isn't encoded
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
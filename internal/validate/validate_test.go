@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"mcp-server/internal/tools"
+	"mcp-server/internal/utils"
+)
+
+type patternArgs struct {
+	Name  string   `json:"name" jsonschema:"required,pattern=^[a-z]+$"`
+	Tags  []string `json:"tags" jsonschema:"maxItems=2"`
+	Ratio *float64 `json:"ratio" jsonschema:"exclusiveMinimum=0,maximum=1"`
+}
+
+func intPtr(n int) *int { return &n }
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  interface{}
+		field string
+	}{
+		{"valid mode", tools.WriteFileArgs{FilePath: "a.txt", Mode: "a"}, ""},
+		{"default mode", tools.WriteFileArgs{FilePath: "a.txt"}, ""},
+		{"unknown mode", tools.WriteFileArgs{FilePath: "a.txt", Mode: "x"}, "mode"},
+		{"empty required path", tools.WriteFileArgs{Mode: "w"}, "file_path"},
+		{"zero num_lines", tools.ShowFileArgs{FilePath: "a.txt", NumLines: intPtr(0)}, ""},
+		{"negative num_lines", tools.ShowFileArgs{FilePath: "a.txt", NumLines: intPtr(-1)}, "num_lines"},
+		{"all matches", tools.SearchInFileArgs{FilePath: "a.txt", Pattern: "x", MaxMatches: -1}, ""},
+		{"below minimum", tools.SearchInFileArgs{FilePath: "a.txt", Pattern: "x", MaxMatches: -2}, "max_matches"},
+		{"empty command", tools.ExecuteShellCommandArgs{Command: []string{}}, "command"},
+		{"timeout too long", tools.ExecuteShellCommandArgs{Command: []string{"ls"}, Timeout: 100000}, "timeout"},
+		{"pattern", patternArgs{Name: "abc"}, ""},
+		{"pattern mismatch", patternArgs{Name: "ABC"}, "name"},
+		{"too many items", patternArgs{Name: "abc", Tags: []string{"a", "b", "c"}}, "tags"},
+		{"exclusive minimum", patternArgs{Name: "abc", Ratio: floatPtr(0)}, "ratio"},
+		{"nested item", tools.ReadFilesArgs{Files: []tools.ReadFileSpec{{Path: "a.txt", NumLines: intPtr(1)}}}, ""},
+		{"nested item empty path", tools.ReadFilesArgs{Files: []tools.ReadFileSpec{{Path: "a.txt"}, {}}}, "files"},
+		{"nested item bad num_lines", tools.ReadFilesArgs{Files: []tools.ReadFileSpec{{Path: "a.txt", NumLines: intPtr(-1)}}}, "files"},
+		{"pointer to struct", &patternArgs{Name: "abc", Ratio: floatPtr(0.5)}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.args)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return
+			}
+
+			var toolErr *utils.ToolError
+			if !errors.As(err, &toolErr) {
+				t.Fatalf("Expected a *utils.ToolError, got: %v", err)
+			}
+			if toolErr.Code != utils.ErrInvalidArgs {
+				t.Errorf("Expected code %s, got %s", utils.ErrInvalidArgs, toolErr.Code)
+			}
+			if !strings.Contains(toolErr.Message, tt.field) {
+				t.Errorf("Expected the error to name %s, got: %s", tt.field, toolErr.Message)
+			}
+		})
+	}
+}
+
+func TestPresent(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  interface{}
+		raw   string
+		field string
+	}{
+		{"all required", tools.WriteFileArgs{FilePath: "a.txt"}, `{"file_path": "a.txt", "content": ""}`, ""},
+		{"missing content", tools.WriteFileArgs{FilePath: "a.txt"}, `{"file_path": "a.txt"}`, "content"},
+		{"missing path", tools.WriteFileArgs{}, `{"content": "x"}`, "file_path"},
+		{"null counts as sent", patternArgs{}, `{"name": null}`, ""},
+		{"not an object", patternArgs{}, `[]`, "object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Present(tt.args, []byte(tt.raw))
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return
+			}
+
+			var toolErr *utils.ToolError
+			if !errors.As(err, &toolErr) || toolErr.Code != utils.ErrInvalidArgs {
+				t.Fatalf("Expected an INVALID_ARGS error, got: %v", err)
+			}
+			if !strings.Contains(toolErr.Message, tt.field) {
+				t.Errorf("Expected the error to name %s, got: %s", tt.field, toolErr.Message)
+			}
+		})
+	}
+
+	if err := Present(tools.WriteFileArgs{}, nil); err != nil {
+		t.Errorf("Expected arguments without their JSON not to be checked, got: %v", err)
+	}
+}